@@ -1,56 +1,39 @@
 package ansible
 
 import (
+	"bytes"
 	"context"
-	"errors"
 	"fmt"
-	"log"
-	"os"
-	"os/exec"
+	"io"
 
 	"github.com/DrC0ns0le/bind-api/rdb"
 )
 
 const playbook = "./ansible/deploy_config.yaml"
-const inventory = "./ansible/inventory.ini"
+const inventory = "./ansible/inventory.ini" // used while no server is configured
 
 // Run deploy config playbook
 func DeployConfig(ctx context.Context) (string, error) {
-
-	// check if playbook exists
-	_, err := os.Stat(playbook)
-	if os.IsNotExist(err) {
-		return "", errors.New("playbook not found")
-	}
-
-	// check if inventory.ini exists
-	_, err = os.Stat(inventory)
-	if os.IsNotExist(err) {
-		return "", errors.New("inventory not found")
+	var output bytes.Buffer
+	if _, err := Deploy(ctx, &output); err != nil {
+		return output.String(), err
 	}
+	return output.String(), nil
+}
 
-	// run playbook
-
-	output, err := exec.CommandContext(ctx, "ansible-playbook", "-i", inventory, playbook).Output()
+// Deploy deploys the configuration with Driver, writing its output to out
+// as it runs, and marks the configuration as deployed once every server
+// succeeded.
+func Deploy(ctx context.Context, out io.Writer) ([]HostResult, error) {
+	results, err := Driver.Deploy(ctx, out)
 	if err != nil {
-		log.Printf("output: %s", string(output))
-		return string(output), fmt.Errorf("failed to run ansible playbook: %w", err)
+		return results, err
 	}
 
 	// set config deploy_status to deployed
 	if err := (&rdb.Config{ConfigKey: "config_status", ConfigValue: "awaiting_deployment", Staging: false}).Update(ctx, "deployed"); err != nil {
-		return "", fmt.Errorf("failed to update deploy_status: %w", err)
+		return results, fmt.Errorf("failed to update deploy_status: %w", err)
 	}
 
-	return string(output), nil
-}
-
-// Generate inventory ini from database config_key=servers, which is a comma seperated lists of ip addresses
-func GenerateInventory() error {
-
-	// hosts := []string{"10.1.1.109", "10.2.1.2", "10.3.1.25"}
-
-	// TODO: implement ansible playbook
-
-	return nil
+	return results, nil
 }
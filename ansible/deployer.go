@@ -0,0 +1,111 @@
+package ansible
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Defaults of the steps run on every server, which servers override through
+// their bind9_path and git_repo variables.
+const (
+	DefaultBindPath = "/etc/bind"
+	DefaultGitRepo  = "git@github.com:DrC0ns0le/internal-bind-config.git"
+)
+
+// Deployer pulls the committed configuration onto the servers and restarts
+// BIND on them.
+type Deployer interface {
+	// Deploy writes its progress to out as it goes and returns the result
+	// of every server it deployed to. It fails when any server failed.
+	Deploy(ctx context.Context, out io.Writer) ([]HostResult, error)
+}
+
+// HostResult is the outcome of a deploy on one server.
+type HostResult struct {
+	Host  string `json:"host"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Driver is the Deployer used by Deploy, the playbook runner by default.
+var Driver Deployer = Playbook{}
+
+// Playbook deploys by running the deploy config playbook with
+// ansible-playbook.
+type Playbook struct{}
+
+// recapLine matches the line of a host in the PLAY RECAP of ansible-playbook.
+var recapLine = regexp.MustCompile(`^(\S+)\s*:\s*ok=\d+\s+changed=\d+\s+unreachable=(\d+)\s+failed=(\d+)`)
+
+func (Playbook) Deploy(ctx context.Context, out io.Writer) ([]HostResult, error) {
+
+	// check if playbook exists
+	_, err := os.Stat(playbook)
+	if os.IsNotExist(err) {
+		return nil, errors.New("playbook not found")
+	}
+
+	// write the inventory of the configured servers
+	inventory, err := GenerateInventory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if inventory.ini exists
+	_, err = os.Stat(inventory)
+	if os.IsNotExist(err) {
+		return nil, errors.New("inventory not found, configure the servers to deploy to")
+	}
+
+	// run playbook, keeping the output for the recap
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ansible-playbook", "-i", inventory, playbook)
+	cmd.Stdout = io.MultiWriter(out, &output)
+	cmd.Stderr = out
+	runErr := cmd.Run()
+
+	results := playbookResults(&output)
+	if runErr != nil {
+		return results, fmt.Errorf("failed to run ansible playbook: %w", runErr)
+	}
+	return results, nil
+}
+
+// playbookResults reads the result of every host from the PLAY RECAP of the
+// output of ansible-playbook.
+func playbookResults(output io.Reader) []HostResult {
+	var results []HostResult
+	recap := false
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !recap {
+			recap = strings.HasPrefix(line, "PLAY RECAP")
+			continue
+		}
+		m := recapLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		unreachable, _ := strconv.Atoi(m[2])
+		failed, _ := strconv.Atoi(m[3])
+		result := HostResult{Host: m[1], OK: unreachable == 0 && failed == 0}
+		switch {
+		case unreachable > 0:
+			result.Error = "unreachable"
+		case failed > 0:
+			result.Error = fmt.Sprintf("%d tasks failed", failed)
+		}
+		results = append(results, result)
+	}
+	return results
+}
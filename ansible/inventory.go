@@ -0,0 +1,389 @@
+package ansible
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/miekg/dns"
+)
+
+// ServersConfigKey is the config key holding the servers deployed to, one
+// per value: the address followed by its variables, such as
+// "10.1.1.109 user=deploy port=2222 group=secondary". Values holding a comma
+// separated list of addresses are read as servers without variables.
+const ServersConfigKey = "servers"
+
+// generatedInventory is written from the servers before each run. The
+// inventory mounted at inventory is used while no server is configured.
+const generatedInventory = "./ansible/inventory.generated.ini"
+
+// deployGroup is the group the playbook deploys to, servers without a group
+// of their own are listed in it directly.
+const deployGroup = "bind"
+
+var groupPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// serverVars are the variables servers may set, those the playbook uses.
+// Others, such as ansible_ssh_common_args, would let anyone managing
+// servers run commands on the API server.
+var serverVars = map[string]bool{"bind9_path": true, "git_repo": true}
+
+// Server is a host the configuration is deployed to.
+type Server struct {
+	Host  string            `json:"host"`
+	User  string            `json:"user,omitempty"`  // SSH user, ansible_user
+	Port  int               `json:"port,omitempty"`  // SSH port, ansible_port
+	Group string            `json:"group,omitempty"` // Such as primary or secondary
+	Vars  map[string]string `json:"vars,omitempty"`  // bind9_path and git_repo
+}
+
+// ParseServer parses a value of ServersConfigKey holding a single server.
+func ParseServer(value string) (Server, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return Server{}, errors.New("empty server")
+	}
+
+	server := Server{Host: fields[0]}
+	for _, field := range fields[1:] {
+		key, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return Server{}, fmt.Errorf("invalid variable %q of server %s, expected key=value", field, server.Host)
+		}
+		switch key {
+		case "user":
+			server.User = v
+		case "port":
+			port, err := strconv.Atoi(v)
+			if err != nil {
+				return Server{}, fmt.Errorf("invalid port %q of server %s", v, server.Host)
+			}
+			server.Port = port
+		case "group":
+			server.Group = v
+		default:
+			if server.Vars == nil {
+				server.Vars = make(map[string]string)
+			}
+			server.Vars[key] = v
+		}
+	}
+
+	if err := server.Validate(); err != nil {
+		return Server{}, err
+	}
+	return server, nil
+}
+
+// ParseServers parses a value of ServersConfigKey, which holds either a
+// single server or a comma separated list of addresses.
+func ParseServers(value string) ([]Server, error) {
+	if !strings.Contains(value, ",") {
+		server, err := ParseServer(value)
+		if err != nil {
+			return nil, err
+		}
+		return []Server{server}, nil
+	}
+
+	var servers []Server
+	for _, host := range strings.Split(value, ",") {
+		if strings.TrimSpace(host) == "" {
+			continue
+		}
+		server := Server{Host: strings.TrimSpace(host)}
+		if err := server.Validate(); err != nil {
+			return nil, err
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// Validate checks the host, port, group and variables of s.
+func (s Server) Validate() error {
+	if _, err := netip.ParseAddr(s.Host); err != nil {
+		if _, ok := dns.IsDomainName(s.Host); !ok || strings.ContainsAny(s.Host, " ,=[]{}%#") || checkInventoryValue(s.Host) != nil {
+			return fmt.Errorf("invalid server %q, expected an address or host name", s.Host)
+		}
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		return fmt.Errorf("invalid port %d of server %s", s.Port, s.Host)
+	}
+	if s.User != "" {
+		if strings.ContainsAny(s.User, " =") {
+			return fmt.Errorf("invalid user %q of server %s", s.User, s.Host)
+		}
+		if err := checkInventoryValue(s.User); err != nil {
+			return fmt.Errorf("invalid user %q of server %s, %w", s.User, s.Host, err)
+		}
+	}
+	if s.Group != "" && !groupPattern.MatchString(s.Group) {
+		return fmt.Errorf("invalid group %q of server %s, expected letters, digits and underscores", s.Group, s.Host)
+	}
+	for key, v := range s.Vars {
+		if !serverVars[key] {
+			return fmt.Errorf("invalid variable %q of server %s, expected %s", key, s.Host, strings.Join(sortedKeys(serverVars), " or "))
+		}
+		if v == "" || strings.Contains(v, " ") {
+			return fmt.Errorf("invalid value %q of variable %s of server %s, values may not be empty or hold spaces", v, key, s.Host)
+		}
+		if err := checkInventoryValue(v); err != nil {
+			return fmt.Errorf("invalid value %q of variable %s of server %s, %w", v, key, s.Host, err)
+		}
+	}
+	return nil
+}
+
+// checkInventoryValue checks a value written into the inventory. A control
+// character would end its line, and ansible evaluates templates in inventory
+// variables.
+func checkInventoryValue(v string) error {
+	for _, c := range v {
+		if unicode.IsControl(c) {
+			return errors.New("values may not hold control characters")
+		}
+	}
+	if strings.Contains(v, "{{") || strings.Contains(v, "{%") || strings.Contains(v, "{#") {
+		return errors.New("values may not hold templates")
+	}
+	return nil
+}
+
+// String returns s as a value of ServersConfigKey.
+func (s Server) String() string {
+	fields := []string{s.Host}
+	if s.User != "" {
+		fields = append(fields, "user="+s.User)
+	}
+	if s.Port != 0 {
+		fields = append(fields, "port="+strconv.Itoa(s.Port))
+	}
+	if s.Group != "" {
+		fields = append(fields, "group="+s.Group)
+	}
+	for _, key := range sortedKeys(s.Vars) {
+		fields = append(fields, key+"="+s.Vars[key])
+	}
+	return strings.Join(fields, " ")
+}
+
+// hostVars returns the inventory variables of s.
+func (s Server) hostVars() string {
+	fields := []string{s.Host}
+	if s.User != "" {
+		fields = append(fields, "ansible_user="+s.User)
+	}
+	if s.Port != 0 {
+		fields = append(fields, "ansible_port="+strconv.Itoa(s.Port))
+	}
+	for _, key := range sortedKeys(s.Vars) {
+		fields = append(fields, key+"="+s.Vars[key])
+	}
+	return strings.Join(fields, " ")
+}
+
+// Servers retrieves the configured servers in the order they were added.
+func Servers(ctx context.Context) ([]Server, error) {
+	configs, err := serverConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []Server
+	for _, c := range configs {
+		parsed, err := ParseServers(c.ConfigValue)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, parsed...)
+	}
+	return servers, nil
+}
+
+// SetServer adds server, replacing the server with the host replaces when it
+// is not empty. A server is replaced by a single update of the value holding
+// it, so that a failure leaves the previous server in place.
+//
+// Returns sql.ErrNoRows when there is no server with the host replaces.
+func SetServer(ctx context.Context, replaces string, server Server) error {
+	if err := server.Validate(); err != nil {
+		return err
+	}
+	if replaces == "" {
+		return (&rdb.Config{ConfigKey: ServersConfigKey, ConfigValue: server.String()}).Create(ctx)
+	}
+
+	value, hosts, i, err := findServerConfig(ctx, replaces)
+	if err != nil {
+		return err
+	}
+	c := rdb.Config{ConfigKey: ServersConfigKey, ConfigValue: value}
+	if len(hosts) == 1 {
+		return c.Update(ctx, server.String())
+	}
+
+	// lists only hold addresses, a server with variables gets a value of its
+	// own before it leaves the list: a failure in between leaves it listed
+	// twice rather than not at all
+	if server.User == "" && server.Port == 0 && server.Group == "" && len(server.Vars) == 0 {
+		hosts[i] = server.Host
+		return c.Update(ctx, strings.Join(hosts, ","))
+	}
+	if err := (&rdb.Config{ConfigKey: ServersConfigKey, ConfigValue: server.String()}).Create(ctx); err != nil {
+		return err
+	}
+	return c.Update(ctx, strings.Join(append(hosts[:i:i], hosts[i+1:]...), ","))
+}
+
+// RemoveServer removes the server with host, taking it out of the list it
+// is part of if need be.
+//
+// Returns sql.ErrNoRows when there is no such server.
+func RemoveServer(ctx context.Context, host string) error {
+	value, hosts, i, err := findServerConfig(ctx, host)
+	if err != nil {
+		return err
+	}
+	c := rdb.Config{ConfigKey: ServersConfigKey, ConfigValue: value}
+	if len(hosts) == 1 {
+		return c.DeleteValue(ctx)
+	}
+	return c.Update(ctx, strings.Join(append(hosts[:i:i], hosts[i+1:]...), ","))
+}
+
+// findServerConfig returns the value holding the server with host, the hosts
+// of the servers it holds and the index of that server among them.
+//
+// Returns sql.ErrNoRows when there is no such server.
+func findServerConfig(ctx context.Context, host string) (string, []string, int, error) {
+	configs, err := serverConfigs(ctx)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	for _, c := range configs {
+		servers, err := ParseServers(c.ConfigValue)
+		if err != nil {
+			continue
+		}
+		hosts := make([]string, len(servers))
+		for i, server := range servers {
+			hosts[i] = server.Host
+		}
+		for i, server := range servers {
+			if strings.EqualFold(server.Host, host) {
+				return c.ConfigValue, hosts, i, nil
+			}
+		}
+	}
+	return "", nil, 0, sql.ErrNoRows
+}
+
+func serverConfigs(ctx context.Context) ([]rdb.Config, error) {
+	configs, err := (&rdb.Config{ConfigKey: ServersConfigKey}).Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var live []rdb.Config
+	for _, c := range configs {
+		if !c.DeletedAt.Valid {
+			live = append(live, c)
+		}
+	}
+	return live, nil
+}
+
+// Inventory renders servers as an INI inventory. Servers are listed under
+// their group, and every group under the deploy group the playbook targets.
+func Inventory(servers []Server) string {
+	groups := make(map[string][]Server)
+	for _, server := range servers {
+		group := server.Group
+		if group == "" {
+			group = deployGroup
+		}
+		groups[group] = append(groups[group], server)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by bind-api from the servers config, do not edit\n")
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if name != deployGroup {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(&b, "\n[%s]\n", deployGroup)
+	for _, server := range groups[deployGroup] {
+		b.WriteString(server.hostVars() + "\n")
+	}
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n[%s]\n", name)
+		for _, server := range groups[name] {
+			b.WriteString(server.hostVars() + "\n")
+		}
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(&b, "\n[%s:children]\n", deployGroup)
+		for _, name := range names {
+			b.WriteString(name + "\n")
+		}
+	}
+	return b.String()
+}
+
+// GenerateInventory writes the inventory of the configured servers and
+// returns its path, or the path of the mounted inventory when no server is
+// configured.
+func GenerateInventory(ctx context.Context) (string, error) {
+	servers, err := Servers(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read servers: %w", err)
+	}
+	if len(servers) == 0 {
+		return inventory, nil
+	}
+
+	// replace the previous inventory in one go
+	tmp, err := os.CreateTemp(filepath.Dir(generatedInventory), ".inventory-*.ini")
+	if err != nil {
+		return "", fmt.Errorf("failed to write inventory: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(Inventory(servers)); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write inventory: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write inventory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), generatedInventory); err != nil {
+		return "", fmt.Errorf("failed to write inventory: %w", err)
+	}
+	return generatedInventory, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
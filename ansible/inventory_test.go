@@ -0,0 +1,81 @@
+package ansible
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServerValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		server Server
+		err    string // part of the error, empty when valid
+	}{
+		{name: "address", server: Server{Host: "10.0.0.1"}},
+		{name: "host name with variables", server: Server{Host: "ns1.example.com", User: "deploy", Port: 2222, Group: "secondary", Vars: map[string]string{"bind9_path": "/etc/bind", "git_repo": "git@example.com:dns/config.git"}}},
+		{name: "host with a space", server: Server{Host: "ns1 ansible_user=root"}, err: "expected an address or host name"},
+		{name: "host template", server: Server{Host: "{{lookup(x)}}"}, err: "expected an address or host name"},
+		{name: "host with a newline", server: Server{Host: "ns1\nns2"}, err: "expected an address or host name"},
+		{name: "port out of range", server: Server{Host: "10.0.0.1", Port: 65536}, err: "invalid port"},
+		{name: "user with a space", server: Server{Host: "10.0.0.1", User: "deploy ansible_become=yes"}, err: "invalid user"},
+		{name: "user template", server: Server{Host: "10.0.0.1", User: "{{lookup('pipe','id')}}"}, err: "may not hold templates"},
+		{name: "user statement", server: Server{Host: "10.0.0.1", User: "{%print(1)%}"}, err: "may not hold templates"},
+		{name: "user comment", server: Server{Host: "10.0.0.1", User: "{#x#}"}, err: "may not hold templates"},
+		{name: "user with a newline", server: Server{Host: "10.0.0.1", User: "a\nb"}, err: "control characters"},
+		{name: "user with a tab", server: Server{Host: "10.0.0.1", User: "a\tb"}, err: "control characters"},
+		{name: "user with a NUL", server: Server{Host: "10.0.0.1", User: "a\x00b"}, err: "control characters"},
+		{name: "invalid group", server: Server{Host: "10.0.0.1", Group: "dns-servers"}, err: "invalid group"},
+		{name: "unknown variable", server: Server{Host: "10.0.0.1", Vars: map[string]string{"ansible_ssh_common_args": "-o ProxyCommand=id"}}, err: "expected bind9_path or git_repo"},
+		{name: "empty variable", server: Server{Host: "10.0.0.1", Vars: map[string]string{"bind9_path": ""}}, err: "may not be empty"},
+		{name: "variable template", server: Server{Host: "10.0.0.1", Vars: map[string]string{"git_repo": "{{lookup('pipe','id')}}"}}, err: "may not hold templates"},
+		{name: "variable with a newline", server: Server{Host: "10.0.0.1", Vars: map[string]string{"bind9_path": "/etc/bind\n[bind]"}}, err: "control characters"},
+		{name: "variable with a carriage return", server: Server{Host: "10.0.0.1", Vars: map[string]string{"bind9_path": "/etc/bind\r"}}, err: "control characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.server.Validate()
+			if tt.err == "" && err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseServer(t *testing.T) {
+	server, err := ParseServer("10.1.1.109 user=deploy port=2222 group=secondary bind9_path=/srv/bind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.String() != "10.1.1.109 user=deploy port=2222 group=secondary bind9_path=/srv/bind" {
+		t.Errorf("ParseServer().String() = %q", server.String())
+	}
+
+	for _, value := range []string{
+		"10.1.1.109 user={{lookup('pipe','id')}}",
+		"10.1.1.109 git_repo={%raw%}",
+		"10.1.1.109 port=ssh",
+		"10.1.1.109 user",
+	} {
+		if _, err := ParseServer(value); err == nil {
+			t.Errorf("ParseServer(%q) succeeded", value)
+		}
+	}
+}
+
+func TestInventory(t *testing.T) {
+	got := Inventory([]Server{
+		{Host: "10.0.0.1", User: "deploy"},
+		{Host: "10.0.0.2", Port: 2222, Group: "secondary", Vars: map[string]string{"bind9_path": "/srv/bind"}},
+	})
+	for _, line := range []string{
+		"10.0.0.1 ansible_user=deploy\n",
+		"10.0.0.2 ansible_port=2222 bind9_path=/srv/bind\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("Inventory() has no line %q:\n%s", line, got)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package ansible
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHConfig configures the native SSH Deployer.
+type SSHConfig struct {
+	KeyFile        string        // Private key to authenticate with, without passphrase
+	KnownHostsFile string        // Keys servers are verified against, ~/.ssh/known_hosts when empty
+	User           string        // User of servers without one, root when empty
+	Parallelism    int           // Servers deployed to at a time, 1 when below
+	Timeout        time.Duration // Connection timeout, none when 0
+}
+
+// SSH deploys by running the steps of the deploy config playbook on every
+// server over SSH, without Ansible.
+type SSH struct {
+	config SSHConfig
+	signer ssh.Signer
+}
+
+// NewSSH loads the key of config. Known hosts are read again on every
+// deploy, so that servers can be added without a restart.
+func NewSSH(config SSHConfig) (*SSH, error) {
+	if config.KeyFile == "" {
+		return nil, errors.New("no SSH key configured")
+	}
+	key, err := os.ReadFile(config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read SSH key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse SSH key %s: %w", config.KeyFile, err)
+	}
+
+	if config.KnownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to find known hosts: %w", err)
+		}
+		config.KnownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	if _, err := knownhosts.New(config.KnownHostsFile); err != nil {
+		return nil, fmt.Errorf("unable to read known hosts: %w", err)
+	}
+
+	if config.User == "" {
+		config.User = "root"
+	}
+	if config.Parallelism < 1 {
+		config.Parallelism = 1
+	}
+	return &SSH{config: config, signer: signer}, nil
+}
+
+func (d *SSH) Deploy(ctx context.Context, out io.Writer) ([]HostResult, error) {
+	servers, err := Servers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read servers: %w", err)
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("no server configured")
+	}
+
+	hostKeys, err := knownhosts.New(d.config.KnownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read known hosts: %w", err)
+	}
+
+	// deploy to up to Parallelism servers at a time
+	out = &lockedWriter{w: out}
+	results := make([]HostResult, len(servers))
+	slots := make(chan struct{}, d.config.Parallelism)
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server Server) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			results[i] = HostResult{Host: server.Host, OK: true}
+			if err := d.deployTo(ctx, server, hostKeys, out); err != nil {
+				results[i].OK = false
+				results[i].Error = err.Error()
+			}
+		}(i, server)
+	}
+	wg.Wait()
+
+	failed := 0
+	fmt.Fprintln(out, "DEPLOY RECAP")
+	for _, result := range results {
+		if result.OK {
+			fmt.Fprintf(out, "%s: ok\n", result.Host)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "%s: failed: %s\n", result.Host, result.Error)
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("deploy failed on %d of %d servers", failed, len(servers))
+	}
+	return results, nil
+}
+
+// deployTo runs the deploy steps on server, writing their output to out
+// with every line prefixed by the server.
+func (d *SSH) deployTo(ctx context.Context, server Server, hostKeys ssh.HostKeyCallback, out io.Writer) error {
+	user := server.User
+	if user == "" {
+		user = d.config.User
+	}
+	port := server.Port
+	if port == 0 {
+		port = 22
+	}
+	addr := net.JoinHostPort(server.Host, strconv.Itoa(port))
+
+	dialer := net.Dialer{Timeout: d.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	// closing the connection ends the handshake or command when ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if d.config.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(d.config.Timeout))
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(d.signer)},
+		HostKeyCallback: hostKeys,
+		Timeout:         d.config.Timeout,
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(c, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	// stdout and stderr are copied at once, each needs a line of its own
+	stdout := &prefixWriter{prefix: server.Host + ": ", w: out}
+	stderr := &prefixWriter{prefix: server.Host + ": ", w: out}
+	defer stdout.Flush()
+	defer stderr.Flush()
+	session.Stdout = stdout
+	session.Stderr = stderr
+	if err := session.Run(deployCommand(server, user)); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// deployCommand returns the steps of the deploy config playbook as a shell
+// command: bring the BIND directory to the latest commit of the repository,
+// cloning it if need be, and restart BIND. Users other than root run it with
+// sudo, which must not ask for a password.
+func deployCommand(server Server, user string) string {
+	path := server.Vars["bind9_path"]
+	if path == "" {
+		path = DefaultBindPath
+	}
+	repo := server.Vars["git_repo"]
+	if repo == "" {
+		repo = DefaultGitRepo
+	}
+
+	script := fmt.Sprintf(`set -e
+if [ -d %[1]s/.git ]; then
+	git -C %[1]s remote set-url origin %[2]s
+	git -C %[1]s fetch --quiet origin
+	git -C %[1]s reset --quiet --hard '@{upstream}'
+else
+	git clone --quiet %[2]s %[1]s
+fi
+systemctl enable --quiet bind9
+systemctl restart bind9
+echo deployed`, shellQuote(path), shellQuote(repo))
+
+	if user == "root" {
+		return "sh -c " + shellQuote(script)
+	}
+	return "sudo -n sh -c " + shellQuote(script)
+}
+
+// shellQuote quotes s as a single word for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// lockedWriter serializes the writes of the servers deployed to at once.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(b)
+}
+
+// prefixWriter writes whole lines to w, each starting with prefix, so that
+// the output of servers deployed to at once can be told apart.
+type prefixWriter struct {
+	prefix string
+	w      io.Writer
+	line   bytes.Buffer
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	for _, c := range b {
+		p.line.WriteByte(c)
+		if c == '\n' {
+			if err := p.Flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(b), nil
+}
+
+// Flush writes what is left of the last line.
+func (p *prefixWriter) Flush() error {
+	if p.line.Len() == 0 {
+		return nil
+	}
+	line := p.line.Bytes()
+	if line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+	_, err := p.w.Write(append([]byte(p.prefix), line...))
+	p.line.Reset()
+	return err
+}
@@ -0,0 +1,388 @@
+package ansible
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// fleet runs in-process SSH servers that answer the deploy command, and
+// writes the key and known hosts of a client of them.
+type fleet struct {
+	t          *testing.T
+	hostKey    ssh.Signer
+	clientKey  ssh.PublicKey
+	keyFile    string
+	knownHosts string
+
+	mu       sync.Mutex
+	commands map[string]string // deploy commands by user@host
+	active   atomic.Int32
+	peak     atomic.Int32
+}
+
+func newFleet(t *testing.T) *fleet {
+	t.Helper()
+
+	dir := t.TempDir()
+	f := &fleet{t: t, hostKey: newSigner(t), commands: make(map[string]string)}
+
+	_, client, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.keyFile = filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(f.keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.clientKey = signer.PublicKey()
+
+	f.knownHosts = filepath.Join(dir, "known_hosts")
+	if err := os.WriteFile(f.knownHosts, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// serve starts a server on host, known by knownKey, or not known at all
+// when knownKey is nil. Its deploy command fails when fail is set.
+func (f *fleet) serve(host string, knownKey ssh.PublicKey, fail bool) Server {
+	t := f.t
+	t.Helper()
+
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	addr := l.Addr().String()
+
+	if knownKey != nil {
+		line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, knownKey) + "\n"
+		known, err := os.OpenFile(f.knownHosts, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		known.WriteString(line)
+		known.Close()
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(f.clientKey.Marshal()) {
+				return nil, fmt.Errorf("unknown key for %s", conn.User())
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(f.hostKey)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn, config, host, fail)
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+	return Server{Host: host, Port: p}
+}
+
+func (f *fleet) handle(conn net.Conn, config *ssh.ServerConfig, host string, fail bool) {
+	defer conn.Close()
+	c, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" || len(req.Payload) < 4 {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+				command := string(req.Payload[4:])
+
+				f.mu.Lock()
+				f.commands[c.User()+"@"+host] = command
+				f.mu.Unlock()
+
+				// hold the session long enough for the others to start
+				active := f.active.Add(1)
+				for peak := f.peak.Load(); active > peak && !f.peak.CompareAndSwap(peak, active); peak = f.peak.Load() {
+				}
+				time.Sleep(100 * time.Millisecond)
+				f.active.Add(-1)
+
+				status := uint32(0)
+				channel.Write([]byte("Already up to date.\ndeployed"))
+				if fail {
+					channel.Stderr().Write([]byte("Job for bind9.service failed.\n"))
+					status = 1
+				}
+				channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+				return
+			}
+		}()
+	}
+}
+
+// command returns the deploy command user ran on host.
+func (f *fleet) command(user, host string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	command, ok := f.commands[user+"@"+host]
+	return command, ok
+}
+
+// useServers configures servers in a memory store.
+func useServers(t *testing.T, servers ...Server) {
+	t.Helper()
+	s, err := rdb.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdb.Use(s)
+	for _, server := range servers {
+		if err := SetServer(context.Background(), "", server); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSSHDeploy(t *testing.T) {
+	f := newFleet(t)
+	known := f.hostKey.PublicKey()
+	first := f.serve("127.0.0.1", known, false)
+	failing := f.serve("127.0.0.2", known, true)
+	third := f.serve("127.0.0.3", known, false)
+	third.User = "deploy"
+	useServers(t, first, failing, third)
+
+	d, err := NewSSH(SSHConfig{KeyFile: f.keyFile, KnownHostsFile: f.knownHosts, Parallelism: 2, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	results, err := d.Deploy(context.Background(), &out)
+	if err == nil || err.Error() != "deploy failed on 1 of 3 servers" {
+		t.Errorf("Deploy() error = %v, want deploy failed on 1 of 3 servers", err)
+	}
+
+	want := []HostResult{
+		{Host: "127.0.0.1", OK: true},
+		{Host: "127.0.0.2", Error: "Process exited with status 1"},
+		{Host: "127.0.0.3", OK: true},
+	}
+	if fmt.Sprint(results) != fmt.Sprint(want) {
+		t.Errorf("Deploy() = %+v, want %+v", results, want)
+	}
+
+	output := out.String()
+	for _, line := range []string{
+		"127.0.0.1: Already up to date.\n",
+		"127.0.0.1: deployed\n",
+		"127.0.0.2: Job for bind9.service failed.\n",
+		"127.0.0.3: deployed\n",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Deploy() output has no line %q:\n%s", line, output)
+		}
+	}
+	recap := "DEPLOY RECAP\n127.0.0.1: ok\n127.0.0.2: failed: Process exited with status 1\n127.0.0.3: ok\n"
+	if !strings.HasSuffix(output, recap) {
+		t.Errorf("Deploy() output does not end with the recap %q:\n%s", recap, output)
+	}
+
+	if peak := f.peak.Load(); peak != 2 {
+		t.Errorf("deployed to %d servers at a time, want Parallelism 2", peak)
+	}
+	if cmd, _ := f.command("root", "127.0.0.1"); !strings.HasPrefix(cmd, "sh -c ") {
+		t.Errorf("ran %q as root, want it run without sudo", cmd)
+	}
+	if cmd, _ := f.command("deploy", "127.0.0.3"); !strings.HasPrefix(cmd, "sudo -n sh -c ") {
+		t.Errorf("ran %q as deploy, want it run with sudo", cmd)
+	}
+}
+
+func TestSSHDeployHostKey(t *testing.T) {
+	f := newFleet(t)
+	trusted := f.serve("127.0.0.1", f.hostKey.PublicKey(), false)
+	impostor := f.serve("127.0.0.2", newSigner(t).PublicKey(), false)
+	unknown := f.serve("127.0.0.3", nil, false)
+	useServers(t, trusted, impostor, unknown)
+
+	d, err := NewSSH(SSHConfig{KeyFile: f.keyFile, KnownHostsFile: f.knownHosts, Parallelism: 3, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := d.Deploy(context.Background(), &strings.Builder{})
+	if err == nil || err.Error() != "deploy failed on 2 of 3 servers" {
+		t.Errorf("Deploy() error = %v, want deploy failed on 2 of 3 servers", err)
+	}
+
+	tests := []struct {
+		host string
+		err  string // part of the error, empty when deployed
+	}{
+		{host: "127.0.0.1"},
+		{host: "127.0.0.2", err: "key mismatch"},
+		{host: "127.0.0.3", err: "key is unknown"},
+	}
+	for i, tt := range tests {
+		result := results[i]
+		if result.Host != tt.host {
+			t.Fatalf("result %d is for %s, want %s", i, result.Host, tt.host)
+		}
+		if tt.err == "" && !result.OK {
+			t.Errorf("%s failed: %s", tt.host, result.Error)
+		}
+		if tt.err != "" && (result.OK || !strings.Contains(result.Error, tt.err)) {
+			t.Errorf("%s error = %q, want %q", tt.host, result.Error, tt.err)
+		}
+	}
+	if _, ran := f.command("root", "127.0.0.2"); ran {
+		t.Error("ran the deploy command on a server with a mismatched key")
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out strings.Builder
+	w := &prefixWriter{prefix: "ns1: ", w: &out}
+	for _, s := range []string{"cloning", " into /etc/bind\nre", "start", "ed\n", "\n", "done"} {
+		if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", s, n, err)
+		}
+	}
+	if got := out.String(); got != "ns1: cloning into /etc/bind\nns1: restarted\nns1: \n" {
+		t.Errorf("before Flush, wrote %q", got)
+	}
+	w.Flush()
+	w.Flush()
+	if got := out.String(); got != "ns1: cloning into /etc/bind\nns1: restarted\nns1: \nns1: done\n" {
+		t.Errorf("after Flush, wrote %q", got)
+	}
+}
+
+// unquote returns the words of command as sh reads them.
+func unquote(t *testing.T, command string) []string {
+	t.Helper()
+	out, err := exec.Command("sh", "-c", `printf '%s\0' `+command).Output()
+	if err != nil {
+		t.Fatalf("sh failed on %s: %v", command, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, s := range []string{"", "/etc/bind", "it's", "''", `a\'b`, "$(touch pwned) `id` $HOME", "a b\tc\nd", "*; rm -rf /"} {
+		if got := unquote(t, shellQuote(s)); len(got) != 1 || got[0] != s {
+			t.Errorf("shellQuote(%q) reads as %q", s, got)
+		}
+	}
+}
+
+func TestDeployCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		server Server
+		user   string
+		sudo   bool
+		path   string
+		repo   string
+	}{
+		{name: "defaults", user: "root", path: DefaultBindPath, repo: DefaultGitRepo},
+		{name: "sudo", user: "deploy", sudo: true, path: DefaultBindPath, repo: DefaultGitRepo},
+		{
+			name:   "quoted variables",
+			server: Server{Vars: map[string]string{"bind9_path": "/srv/it's bind", "git_repo": "https://example.com/$(id).git"}},
+			user:   "root",
+			path:   "/srv/it's bind",
+			repo:   "https://example.com/$(id).git",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := deployCommand(tt.server, tt.user)
+			prefix := "sh -c "
+			if tt.sudo {
+				prefix = "sudo -n sh -c "
+			}
+			quoted, ok := strings.CutPrefix(command, prefix)
+			if !ok {
+				t.Fatalf("deployCommand() = %q, want it to start with %q", command, prefix)
+			}
+
+			// the script is a single word, which clones repo into path as
+			// words of their own
+			words := unquote(t, quoted)
+			if len(words) != 1 || !strings.HasPrefix(words[0], "set -e\n") {
+				t.Fatalf("deployCommand() script reads as %q, want a single word", words)
+			}
+			var clone []string
+			for _, line := range strings.Split(words[0], "\n") {
+				if rest, ok := strings.CutPrefix(line, "\tgit clone --quiet "); ok {
+					clone = unquote(t, rest)
+				}
+			}
+			if len(clone) != 2 || clone[0] != tt.repo || clone[1] != tt.path {
+				t.Errorf("deployCommand() clones %q, want %s into %s", clone, tt.repo, tt.path)
+			}
+		})
+	}
+}
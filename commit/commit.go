@@ -1,6 +1,7 @@
 package commit
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -62,8 +63,8 @@ func Init(token string) {
 	log.Println("Git init successful.")
 }
 
-// Commit all files and push to remote
-func Push() error {
+// Commit all files and push to remote, returning the hash of the commit
+func Push() (string, error) {
 
 	// open repo
 	r, err := git.PlainOpen(directory)
@@ -80,7 +81,7 @@ func Push() error {
 	// git add .
 	_, err = w.Add(".")
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// git commit -m \"message\"
@@ -93,12 +94,12 @@ func Push() error {
 		},
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	_, err = r.CommitObject(commit)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	err = r.Push(&git.PushOptions{
@@ -106,10 +107,10 @@ func Push() error {
 		Auth:       authMethod,
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return commit.String(), nil
 }
 
 // Undo all changes
@@ -165,3 +166,36 @@ func Staging() (bool, error) {
 	}
 	return false, nil
 }
+
+// ReadCommitted returns the contents of a file in the output repository as of
+// the last commit, ignoring anything rendered since.
+func ReadCommitted(name string) ([]byte, error) {
+	r, err := git.PlainOpen(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := c.File(name)
+	if err != nil {
+		if errors.Is(err, object.ErrFileNotFound) {
+			return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+		}
+		return nil, err
+	}
+
+	contents, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(contents), nil
+}
@@ -7,16 +7,53 @@ import (
 )
 
 var (
-	dbPort = flag.Int("db.port", 5432, "database port")
-	dbAddr = flag.String("db.addr", "127.0.0.1", "database address")
-	dbUser = flag.String("db.user", "postgres", "database user")
-	dbPass = flag.String("db.pass", "", "database password")
-	dbName = flag.String("db.table", "bind_dns", "database name")
+	dbDriver = flag.String("db.driver", "postgres", "database driver (postgres or memory)")
+	dbPath   = flag.String("db.path", "", "snapshot file for the memory database driver")
+	dbPort   = flag.Int("db.port", 5432, "database port")
+	dbAddr   = flag.String("db.addr", "127.0.0.1", "database address")
+	dbUser   = flag.String("db.user", "postgres", "database user")
+	dbPass   = flag.String("db.pass", "", "database password")
+	dbName   = flag.String("db.table", "bind_dns", "database name")
+
+	dbMigrate = flag.Bool("db.migrate", true, "apply pending schema migrations on startup")
 
 	listenAddr = flag.String("listen.addr", "0.0.0.0", "listen address")
 	listenPort = flag.String("listen.port", "8080", "listen port")
 
 	gitToken = flag.String("git.token", "", "git token")
+
+	authAdminToken = flag.String("auth.admin_token", "", "static admin bearer token for bootstrapping API tokens, empty to disable")
+
+	oidcIssuer      = flag.String("oidc.issuer", "", "OIDC issuer whose JWTs are accepted, empty to disable")
+	oidcAudience    = flag.String("oidc.audience", "", "expected audience of OIDC JWTs, usually the web UI client ID")
+	oidcJWKSURL     = flag.String("oidc.jwks_url", "", "JWKS URL of the OIDC provider, discovered from the issuer when empty")
+	oidcJWKSFile    = flag.String("oidc.jwks_file", "", "static JWKS file used instead of fetching the provider keys")
+	oidcJWKSRefresh = flag.Int("oidc.jwks_refresh", 3600, "seconds fetched OIDC keys are cached for")
+	oidcGroupsClaim = flag.String("oidc.groups_claim", "groups", "claim holding the groups of OIDC users, dots separate nested claims")
+	oidcGroupRoles  = flag.String("oidc.group_roles", "", "JSON object, or file holding one, mapping groups to roles")
+
+	recordMinTTL = flag.Int("record.min_ttl", 60, "lowest TTL accepted for records")
+	recordMaxTTL = flag.Int("record.max_ttl", 65535, "highest TTL accepted for records")
+
+	soaSerialScheme = flag.String("soa.serial_scheme", "date", "SOA serial scheme: date (YYYYMMDDnn), unix or counter")
+
+	checkConfCmd = flag.String("check.named_checkconf", "named-checkconf", "named-checkconf command, the built-in check is used when unavailable")
+	checkZoneCmd = flag.String("check.named_checkzone", "named-checkzone", "named-checkzone command, the built-in check is used when unavailable")
+
+	transferTSIGKeys = flag.String("transfer.tsig_keys", "", "JSON object, or file holding one, mapping names of TSIG keys zone transfers can be signed with to their algorithm and secret")
+
+	instanceID = flag.String("instance.id", "", "name of this API server among those sharing the database, the hostname when empty; it must stay the same across restarts")
+
+	scheduleInterval = flag.Int("schedule.interval", 30, "seconds between checks for due scheduled applies, 0 to not run them on this server")
+
+	deployInterval = flag.Int("deploy.interval", 10, "seconds between checks for deploy jobs queued on other servers, 0 to not run deploy jobs on this server")
+
+	deployDriver        = flag.String("deploy.driver", "ansible", "deploy driver: ansible (ansible-playbook) or ssh (native SSH)")
+	deploySSHKey        = flag.String("deploy.ssh_key", "", "private key the ssh deploy driver authenticates with")
+	deploySSHKnownHosts = flag.String("deploy.ssh_known_hosts", "", "known hosts file the ssh deploy driver verifies servers against, ~/.ssh/known_hosts when empty")
+	deploySSHUser       = flag.String("deploy.ssh_user", "root", "user the ssh deploy driver connects as to servers without one")
+	deploySSHTimeout    = flag.Int("deploy.ssh_timeout", 30, "seconds the ssh deploy driver waits to connect to a server")
+	deployParallelism   = flag.Int("deploy.parallelism", 5, "servers the ssh deploy driver deploys to at a time")
 )
 
 func getEnv(key, fallback string) string {
@@ -35,17 +72,62 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+func getEnvBool(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return fallback
+}
+
 func loadConfig() {
 	flag.Parse()
 
+	*dbDriver = getEnv("DB_DRIVER", *dbDriver)
+	*dbPath = getEnv("DB_PATH", *dbPath)
 	*dbPort = getEnvInt("DB_PORT", *dbPort)
 	*dbAddr = getEnv("DB_ADDR", *dbAddr)
 	*dbUser = getEnv("DB_USER", *dbUser)
 	*dbPass = getEnv("DB_PASS", *dbPass)
 	*dbName = getEnv("DB_NAME", *dbName)
+	*dbMigrate = getEnvBool("DB_MIGRATE", *dbMigrate)
 
 	*listenAddr = getEnv("LISTEN_ADDR", *listenAddr)
 	*listenPort = getEnv("LISTEN_PORT", *listenPort)
 
 	*gitToken = getEnv("GIT_TOKEN", *gitToken)
+
+	*authAdminToken = getEnv("AUTH_ADMIN_TOKEN", *authAdminToken)
+
+	*oidcIssuer = getEnv("OIDC_ISSUER", *oidcIssuer)
+	*oidcAudience = getEnv("OIDC_AUDIENCE", *oidcAudience)
+	*oidcJWKSURL = getEnv("OIDC_JWKS_URL", *oidcJWKSURL)
+	*oidcJWKSFile = getEnv("OIDC_JWKS_FILE", *oidcJWKSFile)
+	*oidcJWKSRefresh = getEnvInt("OIDC_JWKS_REFRESH", *oidcJWKSRefresh)
+	*oidcGroupsClaim = getEnv("OIDC_GROUPS_CLAIM", *oidcGroupsClaim)
+	*oidcGroupRoles = getEnv("OIDC_GROUP_ROLES", *oidcGroupRoles)
+
+	*recordMinTTL = getEnvInt("RECORD_MIN_TTL", *recordMinTTL)
+	*recordMaxTTL = getEnvInt("RECORD_MAX_TTL", *recordMaxTTL)
+
+	*soaSerialScheme = getEnv("SOA_SERIAL_SCHEME", *soaSerialScheme)
+
+	*checkConfCmd = getEnv("CHECK_NAMED_CHECKCONF", *checkConfCmd)
+	*checkZoneCmd = getEnv("CHECK_NAMED_CHECKZONE", *checkZoneCmd)
+
+	*transferTSIGKeys = getEnv("TRANSFER_TSIG_KEYS", *transferTSIGKeys)
+
+	*instanceID = getEnv("INSTANCE_ID", *instanceID)
+
+	*scheduleInterval = getEnvInt("SCHEDULE_INTERVAL", *scheduleInterval)
+
+	*deployInterval = getEnvInt("DEPLOY_INTERVAL", *deployInterval)
+
+	*deployDriver = getEnv("DEPLOY_DRIVER", *deployDriver)
+	*deploySSHKey = getEnv("DEPLOY_SSH_KEY", *deploySSHKey)
+	*deploySSHKnownHosts = getEnv("DEPLOY_SSH_KNOWN_HOSTS", *deploySSHKnownHosts)
+	*deploySSHUser = getEnv("DEPLOY_SSH_USER", *deploySSHUser)
+	*deploySSHTimeout = getEnvInt("DEPLOY_SSH_TIMEOUT", *deploySSHTimeout)
+	*deployParallelism = getEnvInt("DEPLOY_PARALLELISM", *deployParallelism)
 }
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	Time       time.Time       `json:"time"`
+	Actor      string          `json:"actor"`
+	ActorID    string          `json:"actor_id,omitempty"`
+	Action     string          `json:"action"`
+	ObjectUUID string          `json:"object_uuid,omitempty"`
+	ZoneUUID   string          `json:"zone_uuid,omitempty"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+}
+
+// audit appends a change made by the caller to the audit log. before and
+// after are the API representations of the object, nil when it was created
+// or deleted.
+//
+// The change has already happened, so failing to record it is only logged.
+func audit(ctx context.Context, action, zoneUUID, objectUUID string, before, after interface{}) {
+	principal, _ := policy.FromContext(ctx)
+	entry := rdb.AuditEntry{
+		Actor:      principal.Name,
+		ActorID:    principal.ID,
+		Action:     action,
+		ObjectUUID: objectUUID,
+		ZoneUUID:   zoneUUID,
+	}
+
+	var err error
+	if entry.Before, err = json.Marshal(before); err == nil {
+		entry.After, err = json.Marshal(after)
+	}
+	if err == nil {
+		err = entry.Append(ctx)
+	}
+	if err != nil {
+		log.Printf("Unable to audit %s of %s by %s: %v", action, objectUUID, principal.Name, err)
+	}
+}
+
+// GetAuditHandler lists audit entries, most recent first. They can be
+// filtered by zone_uuid, actor, action and a since/until time range, times
+// in RFC 3339 or seconds since the epoch, and are limited to limit entries,
+// 100 by default.
+func GetAuditHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := rdb.AuditFilter{
+		ZoneUUID: query.Get("zone_uuid"),
+		Actor:    query.Get("actor"),
+		Action:   query.Get("action"),
+		Limit:    100,
+	}
+
+	var err error
+	if v := query.Get("since"); v != "" && err == nil {
+		filter.Since, err = parseTime(v)
+	}
+	if v := query.Get("until"); v != "" && err == nil {
+		filter.Until, err = parseTime(v)
+	}
+	if v := query.Get("limit"); v != "" && err == nil {
+		filter.Limit, err = strconv.Atoi(v)
+	}
+	if err != nil || filter.Limit < 0 {
+		responseBody := responseBody{
+			Code:    1,
+			Message: "Invalid filter",
+			Data:    "since and until must be RFC 3339 times or seconds since the epoch, limit a positive number",
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+
+	entries, err := filter.Get(r.Context())
+	if err != nil {
+		responseBody := responseBody{
+			Code:    2,
+			Message: "Unable to retrieve audit log",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+
+	list := []AuditEntry{}
+	for _, e := range entries {
+		list = append(list, AuditEntry(e))
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Audit log retrieved successfully",
+		Data:    list,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// parseTime accepts RFC 3339 times and seconds since the epoch.
+func parseTime(v string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
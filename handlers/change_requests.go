@@ -0,0 +1,1211 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/commit"
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/DrC0ns0le/bind-api/reverse"
+	"github.com/DrC0ns0le/bind-api/validation"
+	"github.com/google/uuid"
+)
+
+type ChangeRequest struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	Owner        string     `json:"owner"`
+	OwnerID      string     `json:"owner_id,omitempty"`
+	Status       string     `json:"status"`
+	Changes      []Change   `json:"changes"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ModifiedAt   time.Time  `json:"modified_at"`
+	ApprovedBy   string     `json:"approved_by,omitempty"`
+	ApprovedByID string     `json:"approved_by_id,omitempty"`
+	ApprovedAt   *time.Time `json:"approved_at,omitempty"`
+	AppliedAt    *time.Time `json:"applied_at,omitempty"`
+	ChangesetID  int64      `json:"changeset_id,omitempty"`
+}
+
+// Change is one edit of a change request. It is replayed against the
+// endpoint of its kind and action when the request is applied, with data
+// as the request body.
+type Change struct {
+	Kind     string          `json:"kind"`   // zone, record or config
+	Action   string          `json:"action"` // create, update or delete
+	ZoneUUID string          `json:"zone_uuid,omitempty"`
+	UUID     string          `json:"uuid,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+
+	// Version of the zone or record the change was made against, the apply
+	// is refused when it changed since
+	Base int64 `json:"base,omitempty"`
+}
+
+// changeRequestFromRDB converts a database change request into its API
+// representation.
+func changeRequestFromRDB(c rdb.ChangeRequest) ChangeRequest {
+	changes := []Change{}
+	if len(c.Changes) > 0 {
+		if err := json.Unmarshal(c.Changes, &changes); err != nil {
+			log.Printf("Unable to decode changes of change request %d: %v", c.ID, err)
+		}
+	}
+	request := ChangeRequest{
+		ID:           c.ID,
+		Name:         c.Name,
+		Description:  c.Description,
+		Owner:        c.Owner,
+		OwnerID:      c.OwnerID,
+		Status:       c.Status,
+		Changes:      changes,
+		CreatedAt:    c.CreatedAt,
+		ModifiedAt:   c.ModifiedAt,
+		ApprovedBy:   c.ApprovedBy,
+		ApprovedByID: c.ApprovedByID,
+		ChangesetID:  c.ChangesetID,
+	}
+	if c.ApprovedAt.Valid {
+		request.ApprovedAt = &c.ApprovedAt.Time
+	}
+	if c.AppliedAt.Valid {
+		request.AppliedAt = &c.AppliedAt.Time
+	}
+	return request
+}
+
+// GetChangeRequestsHandler lists change requests, most recent first,
+// optionally only those with the given status.
+func GetChangeRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	requests, err := (&rdb.ChangeRequest{Status: r.URL.Query().Get("status")}).Get(r.Context())
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Unable to retrieve change requests",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	C := []ChangeRequest{}
+	for _, request := range requests {
+		C = append(C, changeRequestFromRDB(request))
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Change requests retrieved successfully",
+		Data:    C,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// GetChangeRequestHandler retrieves a change request with its changes.
+func GetChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	request, ok := findChangeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Change request retrieved successfully",
+		Data:    changeRequestFromRDB(request),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// CreateChangeRequestHandler opens an empty change request owned by the
+// caller.
+func CreateChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var requestData struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if strings.TrimSpace(requestData.Name) == "" {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Name cannot be empty",
+			Data:    nil,
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	principal, _ := policy.FromContext(r.Context())
+	request := rdb.ChangeRequest{
+		Name:        strings.TrimSpace(requestData.Name),
+		Description: requestData.Description,
+		Owner:       principal.Name,
+		OwnerID:     principal.ID,
+	}
+	if err := request.Create(r.Context()); err != nil {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Unable to create change request",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	audit(r.Context(), "change_request.create", "", strconv.FormatInt(request.ID, 10), nil, changeRequestFromRDB(request))
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Change request created successfully",
+		Data:    changeRequestFromRDB(request),
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// AddChangeHandler appends a change to a change request of the caller. The
+// change is checked the way its endpoint would, but nothing is staged.
+// Changing an approved request takes its approval away.
+//
+// A zone or record may only be changed by one open change request at a
+// time, the change is refused with a conflict otherwise.
+func AddChangeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	request, ok := findChangeRequest(w, r)
+	if !ok || !canChangeRequest(w, r, request) {
+		return
+	}
+	changes := changeRequestFromRDB(request).Changes
+
+	var change Change
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		errorMsg := responseBody{
+			Code:    5,
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if !checkChange(w, r, changes, &change) {
+		return
+	}
+
+	// Another open request touching the same zone or record is a conflict
+	if conflicts, err := changeConflicts(r.Context(), request.ID, change); err != nil || len(conflicts) > 0 {
+		var data interface{} = conflicts
+		if err != nil {
+			data = err.Error()
+		}
+		errorMsg := responseBody{
+			Code:    8,
+			Message: "Change conflicts with other change requests",
+			Data:    data,
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	before := changeRequestFromRDB(request)
+	changes = append(changes, change)
+	if !saveChanges(w, r, &request, changes) {
+		return
+	}
+	audit(r.Context(), "change_request.update", change.ZoneUUID, strconv.FormatInt(request.ID, 10), before, changeRequestFromRDB(request))
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Change added successfully",
+		Data:    changeRequestFromRDB(request),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// RemoveChangeHandler removes the change at index from a change request of
+// the caller.
+func RemoveChangeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	request, ok := findChangeRequest(w, r)
+	if !ok || !canChangeRequest(w, r, request) {
+		return
+	}
+	changes := changeRequestFromRDB(request).Changes
+
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 || index >= len(changes) {
+		errorMsg := responseBody{
+			Code:    6,
+			Message: "Change not found",
+			Data:    r.PathValue("index"),
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	before := changeRequestFromRDB(request)
+	changes = append(changes[:index], changes[index+1:]...)
+	if !saveChanges(w, r, &request, changes) {
+		return
+	}
+	audit(r.Context(), "change_request.update", "", strconv.FormatInt(request.ID, 10), before, changeRequestFromRDB(request))
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Change removed successfully",
+		Data:    changeRequestFromRDB(request),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// ApproveChangeRequestHandler approves an open change request. The approver
+// must be someone other than the owner and be allowed to make every change
+// in it.
+func ApproveChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	request, ok := findChangeRequest(w, r)
+	if !ok {
+		return
+	}
+	changes := changeRequestFromRDB(request).Changes
+
+	principal, _ := policy.FromContext(r.Context())
+	if samePrincipal(principal, request.Owner, request.OwnerID) {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Change requests must be approved by someone other than their owner",
+			Data:    nil,
+		}
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if request.Status != rdb.ChangeRequestOpen || len(changes) == 0 {
+		errorMsg := responseBody{
+			Code:    4,
+			Message: "Only open change requests with changes can be approved",
+			Data:    request.Status,
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	for _, change := range changes {
+		if !canMakeChange(w, r, change) {
+			return
+		}
+	}
+
+	request.Status = rdb.ChangeRequestApproved
+	request.ApprovedBy = principal.Name
+	request.ApprovedByID = principal.ID
+	request.ApprovedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	if !updateChangeRequest(w, r, &request) {
+		return
+	}
+	audit(r.Context(), "change_request.approve", "", strconv.FormatInt(request.ID, 10), nil, changeRequestFromRDB(request))
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Change request approved",
+		Data:    changeRequestFromRDB(request),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// ApplyChangeRequestHandler stages the changes of an approved change request
+// and applies them like POST /api/v1/staging, creating a changeset. The
+// zones the request changes must have nothing staged, changes staged for
+// other zones are neither applied nor touched.
+//
+// Nothing is left behind when any change or the apply fails.
+//
+// With an apply_at in the request body the apply is scheduled instead, see
+// scheduleApply. applyChangeRequest checks again when it is due.
+func ApplyChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	request, ok := findChangeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if request.Status != rdb.ChangeRequestApproved {
+		errorMsg := responseBody{
+			Code:    4,
+			Message: "Only approved change requests can be applied",
+			Data:    request.Status,
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	if scheduleApply(w, r, rdb.ScheduledApply{Kind: rdb.ScheduleKindChangeRequest, ChangeRequestID: request.ID}, 8) {
+		return
+	}
+
+	unlock, ok := lockPipeline(w, r, "change_request.apply", 9)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	if err := applyChangeRequest(r.Context(), &request); err != nil {
+		writeApplyError(w, err)
+		return
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Change request applied",
+		Data:    changeRequestFromRDB(request),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// applyChangeRequest stages the changes of request, which must still be
+// approved, applies them with applyStaging and marks request applied. The
+// pipeline lock must be held. Failures are returned as an *applyError.
+func applyChangeRequest(ctx context.Context, request *rdb.ChangeRequest) error {
+	if request.Status != rdb.ChangeRequestApproved {
+		return &applyError{status: http.StatusConflict, code: 4, message: "Only approved change requests can be applied", data: request.Status}
+	}
+	changes := changeRequestFromRDB(*request).Changes
+
+	// Only see and apply the staged changes of the zones the request changes
+	var zoneUUIDs []string
+	for _, change := range changes {
+		switch change.Kind {
+		case "zone":
+			zoneUUIDs = append(zoneUUIDs, change.UUID)
+		case "record":
+			zoneUUIDs = append(zoneUUIDs, change.ZoneUUID)
+		}
+	}
+	ctx = rdb.WithStagingScope(ctx, zoneUUIDs)
+
+	stagedZones, stagedRecords, err := getAllStaging(ctx)
+	if err == nil && (len(stagedZones) > 0 || len(stagedRecords) > 0) {
+		err = errors.New("apply or discard the staged changes of these zones first")
+	}
+	if err != nil {
+		return &applyError{status: http.StatusConflict, code: 5, message: "Zones of the change request have staged changes", data: err.Error()}
+	}
+
+	// Refuse changes made against a zone or record that changed since
+	var stale []Change
+	for _, change := range changes {
+		if change.Base == 0 {
+			continue
+		}
+		if changed, err := changedSince(ctx, change); err != nil || changed {
+			stale = append(stale, change)
+		}
+	}
+	if len(stale) > 0 {
+		return &applyError{status: http.StatusConflict, code: 6, message: "Zones or records changed since the change request was made", data: stale}
+	}
+
+	// Keep the configs the request changes, they are not staged
+	configs := make(map[string][]rdb.Config)
+	for _, change := range changes {
+		if change.Kind == "config" {
+			key := configKey(change)
+			if configs[key], err = (&rdb.Config{ConfigKey: key}).Find(ctx); err != nil {
+				log.Printf("Unable to keep config %s before applying change request %d: %v", key, request.ID, err)
+			}
+		}
+	}
+
+	var staged stagedChanges
+	for i, change := range changes {
+		if err := makeChange(ctx, change, &staged); err != nil {
+			undoChangeRequest(ctx, staged, configs)
+			return &applyError{
+				status:  http.StatusUnprocessableEntity,
+				code:    7,
+				message: fmt.Sprintf("Change %d of the change request failed", i),
+				data: map[string]interface{}{
+					"change": change,
+					"error":  err.Error(),
+				},
+			}
+		}
+	}
+
+	changeset, err := applyStaging(ctx)
+	if err != nil {
+		undoChangeRequest(ctx, staged, configs)
+		return err
+	}
+
+	before := changeRequestFromRDB(*request)
+	request.Status = rdb.ChangeRequestApplied
+	request.AppliedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	request.ChangesetID = changeset.ID
+	if err := request.Update(ctx); err != nil {
+		log.Printf("Unable to mark change request %d as applied: %v", request.ID, err)
+	}
+	audit(ctx, "change_request.apply", "", strconv.FormatInt(request.ID, 10), before, changeRequestFromRDB(*request))
+	return nil
+}
+
+// CloseChangeRequestHandler closes a change request without applying it.
+// Owners close their own requests, operators any request.
+func CloseChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	request, ok := findChangeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	principal, _ := policy.FromContext(r.Context())
+	if !samePrincipal(principal, request.Owner, request.OwnerID) && !principal.Has(policy.Operator) {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Only the owner or an operator can close a change request",
+			Data:    nil,
+		}
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if request.Status == rdb.ChangeRequestApplied || request.Status == rdb.ChangeRequestClosed {
+		errorMsg := responseBody{
+			Code:    4,
+			Message: "Change request is already " + request.Status,
+			Data:    request.Status,
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	before := changeRequestFromRDB(request)
+	request.Status = rdb.ChangeRequestClosed
+	if !updateChangeRequest(w, r, &request) {
+		return
+	}
+	audit(r.Context(), "change_request.close", "", strconv.FormatInt(request.ID, 10), before, changeRequestFromRDB(request))
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Change request closed",
+		Data:    changeRequestFromRDB(request),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// findChangeRequest looks up the change request of the id path value,
+// answering 400 or 404 when there is none.
+func findChangeRequest(w http.ResponseWriter, r *http.Request) (rdb.ChangeRequest, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Invalid change request id",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return rdb.ChangeRequest{}, false
+	}
+
+	request := rdb.ChangeRequest{ID: id}
+	if err := request.Find(r.Context()); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Change request not found",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMsg)
+		return rdb.ChangeRequest{}, false
+	}
+	return request, true
+}
+
+// canChangeRequest reports whether the caller may change the changes of
+// request, answering 403 or 409 when it may not.
+func canChangeRequest(w http.ResponseWriter, r *http.Request, request rdb.ChangeRequest) bool {
+	principal, _ := policy.FromContext(r.Context())
+	if !samePrincipal(principal, request.Owner, request.OwnerID) {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Only the owner can change a change request",
+			Data:    nil,
+		}
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(errorMsg)
+		return false
+	}
+	if request.Status != rdb.ChangeRequestOpen && request.Status != rdb.ChangeRequestApproved {
+		errorMsg := responseBody{
+			Code:    4,
+			Message: "Change request is already " + request.Status,
+			Data:    request.Status,
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(errorMsg)
+		return false
+	}
+	return true
+}
+
+// samePrincipal reports whether principal is the owner or approver given by
+// name and id. The id decides when there is one.
+func samePrincipal(principal policy.Principal, name, id string) bool {
+	if principal.ID != "" || id != "" {
+		return principal.ID == id
+	}
+	return principal.Name == name
+}
+
+// saveChanges stores changes in request, which loses any approval.
+func saveChanges(w http.ResponseWriter, r *http.Request, request *rdb.ChangeRequest, changes []Change) bool {
+	b, err := json.Marshal(changes)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    10,
+			Message: "Unable to save change request",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return false
+	}
+	request.Changes = b
+	request.Status = rdb.ChangeRequestOpen
+	request.ApprovedBy = ""
+	request.ApprovedByID = ""
+	request.ApprovedAt = sql.NullTime{}
+	return updateChangeRequest(w, r, request)
+}
+
+// updateChangeRequest stores request, answering 500 when it cannot.
+func updateChangeRequest(w http.ResponseWriter, r *http.Request, request *rdb.ChangeRequest) bool {
+	if err := request.Update(r.Context()); err != nil {
+		errorMsg := responseBody{
+			Code:    10,
+			Message: "Unable to save change request",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return false
+	}
+	return true
+}
+
+// checkChange validates change before it is added after changes, filling
+// in the zone of records and the version it is made against.
+func checkChange(w http.ResponseWriter, r *http.Request, changes []Change, change *Change) bool {
+	invalid := func(status int, code int, message string, data interface{}) bool {
+		errorMsg := responseBody{
+			Code:    code,
+			Message: message,
+			Data:    data,
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMsg)
+		return false
+	}
+
+	switch change.Action {
+	case "create", "update", "delete":
+	default:
+		return invalid(http.StatusBadRequest, 5, "Invalid action, expected create, update or delete", change.Action)
+	}
+	if change.Action != "delete" && len(change.Data) == 0 {
+		return invalid(http.StatusBadRequest, 5, "Missing data", nil)
+	}
+
+	switch change.Kind {
+	case "zone":
+		var data struct {
+			Name        string          `json:"name"`
+			NameServers rdb.NameServers `json:"name_servers"`
+		}
+		if len(change.Data) > 0 {
+			if err := json.Unmarshal(change.Data, &data); err != nil {
+				return invalid(http.StatusBadRequest, 9, "Invalid zone", err.Error())
+			}
+		}
+		zone := rdb.Zone{UUID: change.UUID}
+		if change.Action == "create" {
+			if data.Name == "" {
+				return invalid(http.StatusBadRequest, 9, "Invalid zone", "name cannot be empty")
+			}
+			zone = rdb.Zone{UUID: uuid.NewSHA1(dnsNamespaceUUID, []byte(data.Name)).String(), Name: data.Name, NameServers: data.NameServers}
+			change.UUID = zone.UUID
+		} else if err := zone.Find(r.Context()); err != nil {
+			return invalid(http.StatusNotFound, 6, "Zone not found", err.Error())
+		}
+		if change.Action != "delete" {
+			if data.Name != "" {
+				zone.Name = data.Name
+			}
+			if data.NameServers != nil {
+				zone.NameServers = data.NameServers
+			}
+			if errs := validation.NameServers(zone.Name, zone.NameServers); errs != nil {
+				return invalid(http.StatusBadRequest, 9, "Invalid name servers", errs)
+			}
+		}
+
+	case "record":
+		if change.ZoneUUID == "" {
+			return invalid(http.StatusBadRequest, 5, "Missing zone_uuid", nil)
+		}
+		record := rdb.Record{ZoneUUID: change.ZoneUUID, TTL: 3600}
+		if change.Action == "create" {
+			change.UUID = ""
+			if err := (&rdb.Zone{UUID: change.ZoneUUID}).Find(r.Context()); err != nil && !createsZone(changes, change.ZoneUUID) {
+				return invalid(http.StatusNotFound, 6, "Zone not found", err.Error())
+			}
+		} else {
+			record.UUID = change.UUID
+			if err := record.Find(r.Context()); err != nil || record.ZoneUUID != change.ZoneUUID {
+				return invalid(http.StatusNotFound, 6, "Record not found", change.UUID)
+			}
+		}
+		if change.Action != "delete" {
+			var data struct {
+				Type    string `json:"type"`
+				Host    string `json:"host"`
+				Content string `json:"content"`
+				TTL     uint16 `json:"ttl"`
+			}
+			if err := json.Unmarshal(change.Data, &data); err != nil {
+				return invalid(http.StatusBadRequest, 9, "Invalid record", err.Error())
+			}
+			if data.Type != "" {
+				record.Type = data.Type
+			}
+			if data.Host != "" {
+				record.Host = data.Host
+			}
+			if data.Content != "" {
+				record.Content = data.Content
+			}
+			if data.TTL != 0 {
+				record.TTL = data.TTL
+			}
+			validation.Normalize(&record)
+			if errs := validation.Record(record); errs != nil {
+				return invalid(http.StatusBadRequest, 9, "Invalid record", errs)
+			}
+		}
+
+	case "config":
+		if configKey(*change) == "" {
+			return invalid(http.StatusBadRequest, 5, "Missing config key", nil)
+		}
+		change.UUID = ""
+		change.ZoneUUID = ""
+
+	default:
+		return invalid(http.StatusBadRequest, 5, "Invalid kind, expected zone, record or config", change.Kind)
+	}
+
+	if !canMakeChange(w, r, *change) {
+		return false
+	}
+
+	change.Base = 0
+	if change.Action != "create" && change.Kind != "config" {
+		version, err := latestVersion(r.Context(), *change)
+		if err != nil {
+			return invalid(http.StatusInternalServerError, 10, "Unable to retrieve history", err.Error())
+		}
+		change.Base = version
+	}
+	return true
+}
+
+// canMakeChange reports whether the caller may make change, answering 403
+// when it may not. Configs are for operators.
+func canMakeChange(w http.ResponseWriter, r *http.Request, change Change) bool {
+	if change.Kind == "config" {
+		principal, _ := policy.FromContext(r.Context())
+		if principal.Has(policy.Operator) {
+			return true
+		}
+		errorMsg := responseBody{
+			Code:    7,
+			Message: "Changing configs requires the operator role",
+			Data:    nil,
+		}
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(errorMsg)
+		return false
+	}
+
+	zone := rdb.Zone{UUID: change.ZoneUUID}
+	if change.Kind == "zone" {
+		zone.UUID = change.UUID
+		if change.Action == "create" {
+			var data struct {
+				Name string `json:"name"`
+			}
+			json.Unmarshal(change.Data, &data)
+			zone.Name = data.Name
+		}
+	}
+	if zone.Name == "" {
+		if err := zone.Find(r.Context()); err != nil {
+			// the zone is created by the same change request, judge by name
+			zone.Name = createdZoneName(r.Context(), zone.UUID)
+		}
+	}
+	return canEdit(w, r, zone, 7)
+}
+
+// createsZone reports whether changes create the zone of uuid.
+func createsZone(changes []Change, zoneUUID string) bool {
+	for _, change := range changes {
+		if change.Kind == "zone" && change.Action == "create" && change.UUID == zoneUUID {
+			return true
+		}
+	}
+	return false
+}
+
+// createdZoneName returns the name of a zone created by an open change
+// request, which is enough to check the scope of records added to it.
+func createdZoneName(ctx context.Context, zoneUUID string) string {
+	requests, err := (&rdb.ChangeRequest{}).Get(ctx)
+	if err != nil {
+		return ""
+	}
+	for _, request := range requests {
+		for _, change := range changeRequestFromRDB(request).Changes {
+			if change.Kind == "zone" && change.Action == "create" && change.UUID == zoneUUID {
+				var data struct {
+					Name string `json:"name"`
+				}
+				json.Unmarshal(change.Data, &data)
+				return data.Name
+			}
+		}
+	}
+	return ""
+}
+
+// configKey returns the config key a config change is for.
+func configKey(change Change) string {
+	var data struct {
+		Key string `json:"key"`
+	}
+	json.Unmarshal(change.Data, &data)
+	return data.Key
+}
+
+// target identifies what change touches, empty for new records.
+func (c Change) target() string {
+	switch {
+	case c.Kind == "config":
+		return "config:" + configKey(c)
+	case c.UUID != "":
+		return c.Kind + ":" + c.UUID
+	}
+	return ""
+}
+
+// changeConflicts lists the other open or approved change requests that
+// touch what change does.
+func changeConflicts(ctx context.Context, id int64, change Change) ([]ChangeRequest, error) {
+	target := change.target()
+	conflicts := []ChangeRequest{}
+	if target == "" {
+		return conflicts, nil
+	}
+
+	requests, err := (&rdb.ChangeRequest{}).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, request := range requests {
+		if request.ID == id || (request.Status != rdb.ChangeRequestOpen && request.Status != rdb.ChangeRequestApproved) {
+			continue
+		}
+		other := changeRequestFromRDB(request)
+		for _, c := range other.Changes {
+			if c.target() == target {
+				conflicts = append(conflicts, other)
+				break
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// latestVersion returns the latest version of the zone or record change is
+// for.
+func latestVersion(ctx context.Context, change Change) (int64, error) {
+	switch change.Kind {
+	case "zone":
+		versions, err := (&rdb.Zone{UUID: change.UUID}).History(ctx)
+		if err != nil || len(versions) == 0 {
+			return 0, err
+		}
+		return versions[len(versions)-1].Version, nil
+	case "record":
+		versions, err := (&rdb.Record{UUID: change.UUID}).History(ctx)
+		if err != nil || len(versions) == 0 {
+			return 0, err
+		}
+		return versions[len(versions)-1].Version, nil
+	}
+	return 0, nil
+}
+
+// changedSince reports whether the zone or record change is for differs
+// from the version the change was made against. Versions that only moved
+// it in and out of staging do not count.
+func changedSince(ctx context.Context, change Change) (bool, error) {
+	switch change.Kind {
+	case "zone":
+		versions, err := (&rdb.Zone{UUID: change.UUID}).History(ctx)
+		if err != nil || len(versions) == 0 {
+			return true, err
+		}
+		last := versions[len(versions)-1].Zone
+		for _, v := range versions {
+			if v.Version == change.Base {
+				return !sameZone(v.Zone, last) || v.Zone.DeletedAt.Valid != last.DeletedAt.Valid, nil
+			}
+		}
+		return true, nil
+	case "record":
+		versions, err := (&rdb.Record{UUID: change.UUID}).History(ctx)
+		if err != nil || len(versions) == 0 {
+			return true, err
+		}
+		last := versions[len(versions)-1].Record
+		for _, v := range versions {
+			if v.Version == change.Base {
+				return !sameRecord(v.Record, last) || v.Record.DeletedAt.Valid != last.DeletedAt.Valid, nil
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// stagedChanges are the zones and records the changes of a change request
+// staged, all that undoChangeRequest discards when its apply fails.
+type stagedChanges struct {
+	zones   []string
+	records []rdb.Record // UUID and ZoneUUID
+}
+
+// makeChange stages change, the way its endpoint would as the principal of
+// ctx, and adds what it staged to staged. Configs are not staged, they are
+// changed right away.
+func makeChange(ctx context.Context, change Change, staged *stagedChanges) error {
+	switch change.Kind {
+	case "zone":
+		zoneUUID, err := makeZoneChange(ctx, change)
+		if zoneUUID != "" {
+			staged.zones = append(staged.zones, zoneUUID)
+		}
+		return err
+	case "record":
+		recordUUID, err := makeRecordChange(ctx, change)
+		if recordUUID != "" {
+			staged.records = append(staged.records, rdb.Record{UUID: recordUUID, ZoneUUID: change.ZoneUUID})
+		}
+		return err
+	case "config":
+		return makeConfigChange(ctx, change)
+	}
+	return fmt.Errorf("invalid kind %q", change.Kind)
+}
+
+// makeZoneChange creates, updates or deletes the zone of change like
+// CreateZoneHandler, UpdateZoneHandler and DeleteZoneHandler, returning its
+// UUID once it is staged.
+func makeZoneChange(ctx context.Context, change Change) (string, error) {
+	var data struct {
+		Name        string           `json:"name"`
+		SOA         SOA              `json:"soa"`
+		NameServers *rdb.NameServers `json:"name_servers"`
+	}
+	if len(change.Data) > 0 {
+		if err := json.Unmarshal(change.Data, &data); err != nil {
+			return "", fmt.Errorf("invalid zone: %w", err)
+		}
+	}
+
+	zone := rdb.Zone{UUID: change.UUID}
+	if change.Action == "create" {
+		if data.Name == "" {
+			return "", errors.New("name cannot be empty")
+		}
+		zone = rdb.Zone{UUID: uuid.NewSHA1(dnsNamespaceUUID, []byte(data.Name)).String(), Staging: true}
+		if reverse.IsReverse(data.Name) {
+			if _, ok := reverse.FromName(data.Name); !ok {
+				return "", fmt.Errorf("reverse zone name %s does not match a network", data.Name)
+			}
+		}
+		if err := (&rdb.Zone{UUID: zone.UUID}).Find(ctx); err == nil {
+			return "", fmt.Errorf("zone %s already exists", data.Name)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+	} else if err := zone.Find(ctx); err != nil {
+		return "", fmt.Errorf("zone %s not found: %w", zone.UUID, err)
+	}
+	before := zoneFromRDB(zone)
+
+	if change.Action == "delete" {
+		if err := zone.Delete(ctx); err != nil {
+			return "", err
+		}
+		audit(ctx, "zone.delete", zone.UUID, zone.UUID, before, nil)
+		return zone.UUID, nil
+	}
+
+	if data.Name != "" {
+		zone.Name = data.Name
+	}
+	if data.SOA.PrimaryNS != "" {
+		zone.PrimaryNS = data.SOA.PrimaryNS
+	}
+	if data.SOA.AdminEmail != "" {
+		zone.AdminEmail = data.SOA.AdminEmail
+	}
+	if data.SOA.Refresh != 0 {
+		zone.Refresh = data.SOA.Refresh
+	}
+	if data.SOA.Retry != 0 {
+		zone.Retry = data.SOA.Retry
+	}
+	if data.SOA.Expire != 0 {
+		zone.Expire = data.SOA.Expire
+	}
+	if data.SOA.Minimum != 0 {
+		zone.Minimum = data.SOA.Minimum
+	}
+	if data.SOA.TTL != 0 {
+		zone.TTL = data.SOA.TTL
+	}
+	if data.NameServers != nil {
+		zone.NameServers = *data.NameServers
+	}
+	if errs := validation.NameServers(zone.Name, zone.NameServers); errs != nil {
+		return "", fmt.Errorf("invalid name servers: %v", errs)
+	}
+
+	if change.Action == "create" {
+		if err := zone.Create(ctx); err != nil {
+			return "", err
+		}
+		audit(ctx, "zone.create", zone.UUID, zone.UUID, nil, zoneFromRDB(zone))
+		return zone.UUID, nil
+	}
+	if err := zone.Update(ctx); err != nil {
+		return "", err
+	}
+	audit(ctx, "zone.update", zone.UUID, zone.UUID, before, zoneFromRDB(zone))
+	return zone.UUID, nil
+}
+
+// makeRecordChange creates, updates or deletes the record of change like
+// CreateRecordHandler, UpdateRecordHandler and DeleteRecordHandler,
+// returning its UUID once it is staged.
+func makeRecordChange(ctx context.Context, change Change) (string, error) {
+	var data struct {
+		Type    string   `json:"type"`
+		Host    string   `json:"host"`
+		Content string   `json:"content"`
+		TTL     uint16   `json:"ttl"`
+		AddPTR  bool     `json:"add_ptr"`
+		Tags    []string `json:"tags"`
+	}
+	if len(change.Data) > 0 {
+		if err := json.Unmarshal(change.Data, &data); err != nil {
+			return "", fmt.Errorf("invalid record: %w", err)
+		}
+	}
+
+	var record rdb.Record
+	if change.Action == "create" {
+		if err := (&rdb.Zone{UUID: change.ZoneUUID}).Find(ctx); err != nil {
+			return "", fmt.Errorf("zone %s not found: %w", change.ZoneUUID, err)
+		}
+		switch {
+		case data.Type == "":
+			return "", errors.New("missing field type")
+		case data.Host == "":
+			return "", errors.New("missing field host")
+		case data.Content == "":
+			return "", errors.New("missing field content")
+		}
+		record = rdb.Record{UUID: uuid.New().String(), ZoneUUID: change.ZoneUUID, TTL: 3600, Staging: true}
+	} else {
+		record.UUID = change.UUID
+		if err := record.Find(ctx); err != nil {
+			return "", fmt.Errorf("record %s not found: %w", change.UUID, err)
+		}
+		if record.ZoneUUID != change.ZoneUUID {
+			return "", fmt.Errorf("record %s is not in zone %s", record.UUID, change.ZoneUUID)
+		}
+	}
+	before := recordFromRDB(record)
+
+	if change.Action == "delete" {
+		if err := record.Delete(ctx); err != nil {
+			return "", err
+		}
+		audit(ctx, "record.delete", record.ZoneUUID, record.UUID, before, nil)
+		return record.UUID, nil
+	}
+
+	if data.Type != "" {
+		record.Type = data.Type
+	}
+	if data.Host != "" {
+		record.Host = data.Host
+	}
+	if data.Content != "" {
+		record.Content = data.Content
+	}
+	if data.TTL != 0 {
+		record.TTL = data.TTL
+	}
+	if len(data.Tags) > 0 {
+		record.Tags = data.Tags
+	}
+	record.AddPTR = data.AddPTR
+	validation.Normalize(&record)
+	if errs := validation.Record(record); errs != nil {
+		return "", fmt.Errorf("invalid record: %v", errs)
+	}
+
+	if change.Action == "create" {
+		if err := record.Create(ctx); err != nil {
+			return "", err
+		}
+		audit(ctx, "record.create", record.ZoneUUID, record.UUID, nil, recordFromRDB(record))
+		return record.UUID, nil
+	}
+	if err := record.Update(ctx); err != nil {
+		return "", err
+	}
+	audit(ctx, "record.update", record.ZoneUUID, record.UUID, before, recordFromRDB(record))
+	return record.UUID, nil
+}
+
+// makeConfigChange creates, updates or deletes the config of change like
+// CreateConfigHandler, UpdateConfigHandler and DeleteConfigHandler.
+func makeConfigChange(ctx context.Context, change Change) error {
+	var c Config
+	if err := json.Unmarshal(change.Data, &c); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	if change.Action != "delete" {
+		if errorMsg := checkConfig(c); errorMsg != nil {
+			return fmt.Errorf("%s: %v", errorMsg.Message, errorMsg.Data)
+		}
+	}
+
+	switch change.Action {
+	case "create":
+		if err := (&rdb.Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigValue, Staging: c.Staging}).Create(ctx); err != nil {
+			return err
+		}
+		audit(ctx, "config.create", "", "", nil, Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigValue, Staging: c.Staging})
+	case "update":
+		if err := (&rdb.Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigOld, Staging: c.Staging}).Update(ctx, c.ConfigValue); err != nil {
+			return err
+		}
+		audit(ctx, "config.update", "", "",
+			Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigOld},
+			Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigValue, Staging: c.Staging})
+	case "delete":
+		if err := (&rdb.Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigValue, Staging: c.Staging}).Delete(ctx); err != nil {
+			return err
+		}
+		audit(ctx, "config.delete", "", "", Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigValue}, nil)
+	}
+	return nil
+}
+
+// undoChangeRequest discards what a failed apply staged and restores the
+// configs it changed, which are not staged, to what they were before.
+// Other staged changes are left alone.
+func undoChangeRequest(ctx context.Context, staged stagedChanges, configs map[string][]rdb.Config) {
+	// records go first, a zone created in staging still holds its new records
+	for _, record := range staged.records {
+		if _, err := (&rdb.Record{ZoneUUID: record.ZoneUUID, UUID: record.UUID}).Discard(ctx); err != nil {
+			log.Printf("Unable to discard record %s of a failed change request: %v", record.UUID, err)
+		}
+	}
+	for _, zoneUUID := range staged.zones {
+		if _, err := (&rdb.Zone{UUID: zoneUUID}).Discard(ctx); err != nil {
+			log.Printf("Unable to discard zone %s of a failed change request: %v", zoneUUID, err)
+		}
+	}
+	if err := commit.Reset(); err != nil {
+		log.Printf("Unable to reset output after a failed change request: %v", err)
+	}
+
+	for key, before := range configs {
+		if err := (&rdb.Config{ConfigKey: key}).Delete(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Unable to restore config %s: %v", key, err)
+			continue
+		}
+		for _, config := range before {
+			if err := config.Create(ctx); err != nil {
+				log.Printf("Unable to restore config %s=%s: %v", key, config.ConfigValue, err)
+			}
+		}
+	}
+}
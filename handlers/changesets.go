@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/DrC0ns0le/bind-api/render"
+)
+
+type Changeset struct {
+	ID         int64           `json:"id"`
+	CommitHash string          `json:"commit_hash"`
+	AppliedAt  time.Time       `json:"applied_at"`
+	Actor      string          `json:"actor"`
+	ActorID    string          `json:"actor_id,omitempty"`
+	Changes    json.RawMessage `json:"changes,omitempty"`
+}
+
+// changesetFromRDB converts a database changeset into its API representation.
+func changesetFromRDB(c rdb.Changeset) Changeset {
+	return Changeset{
+		ID:         c.ID,
+		CommitHash: c.CommitHash,
+		AppliedAt:  c.AppliedAt,
+		Actor:      c.Actor,
+		ActorID:    c.ActorID,
+		Changes:    c.Changes,
+	}
+}
+
+// GetChangesetsHandler lists the applied changesets, most recent first,
+// without the changes they applied.
+func GetChangesetsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	changesets, err := (&rdb.Changeset{}).Get(r.Context())
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Unable to retrieve changesets",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	C := []Changeset{}
+	for _, changeset := range changesets {
+		changeset.Changes = nil
+		C = append(C, changesetFromRDB(changeset))
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Changesets retrieved successfully",
+		Data:    C,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// GetChangesetHandler retrieves a changeset with the changes it applied.
+func GetChangesetHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	changeset, ok := findChangeset(w, r)
+	if !ok {
+		return
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Changeset retrieved successfully",
+		Data:    changesetFromRDB(changeset),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// RollbackChangesetHandler stages the changes that bring zones and records
+// back to how the changeset left them and renders the result. Nothing is
+// pushed, the rollback is applied like any other staged change.
+//
+// Staging must be empty, so that the rollback is all that gets applied.
+func RollbackChangesetHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	changeset, ok := findChangeset(w, r)
+	if !ok {
+		return
+	}
+
+	// the rollback is rendered into the output directory
+	unlock, ok := lockPipeline(w, r, "changeset.rollback", 7)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	stagedZones, stagedRecords, err := getAllStaging(r.Context())
+	if err == nil && (len(stagedZones) > 0 || len(stagedRecords) > 0) {
+		err = errors.New("apply or discard the staged changes first")
+	}
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Staging is not empty",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	if err := stageRollback(r, changeset); err != nil {
+		errorMsg := responseBody{
+			Code:    4,
+			Message: "Unable to stage rollback, discard what was staged and try again",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	Z, R, err := getAllStaging(r.Context())
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    5,
+			Message: "Unable to retrieve changes in staging",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	staged := map[string]interface{}{
+		"changeset": changeset.ID,
+		"zones":     Z,
+		"records":   R,
+	}
+	audit(r.Context(), "changeset.rollback", "", strconv.FormatInt(changeset.ID, 10), nil, staged)
+
+	if _, err := render.RenderZonesTemplate(r.Context()); err != nil {
+		errorMsg := responseBody{
+			Code:    6,
+			Message: "Rollback staged, but zone rendering failed",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Rollback to changeset " + strconv.FormatInt(changeset.ID, 10) + " staged",
+		Data:    staged,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// findChangeset looks up the changeset of the id path value, answering 400
+// or 404 when there is none.
+func findChangeset(w http.ResponseWriter, r *http.Request) (rdb.Changeset, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Invalid changeset id",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return rdb.Changeset{}, false
+	}
+
+	changeset := rdb.Changeset{ID: id}
+	if err := changeset.Find(r.Context()); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Changeset not found",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMsg)
+		return rdb.Changeset{}, false
+	}
+	return changeset, true
+}
+
+// stageRollback stages the inverse of everything committed since changeset:
+// zones and records it had are restored and ones created later are deleted.
+func stageRollback(r *http.Request, changeset rdb.Changeset) error {
+	ctx := r.Context()
+
+	zonesThen, recordsThen, err := changeset.State(ctx)
+	if err != nil {
+		return err
+	}
+	zonesNow, err := (&rdb.Zone{}).Get(ctx)
+	if err != nil {
+		return err
+	}
+	recordsNow, err := (&rdb.Record{}).GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	zoneNow := make(map[string]rdb.Zone)
+	for _, zone := range zonesNow {
+		zoneNow[zone.UUID] = zone
+	}
+	recordNow := make(map[string]rdb.Record)
+	for _, record := range recordsNow {
+		recordNow[record.UUID] = record
+	}
+
+	// restore zones before their records and delete them after
+	alive := make(map[string]bool)
+	for _, zone := range zonesThen {
+		if zone.DeletedAt.Valid {
+			continue
+		}
+		alive[zone.UUID] = true
+		if now, ok := zoneNow[zone.UUID]; ok && sameZone(now, zone) {
+			continue
+		}
+		if err := zone.Stage(ctx); err != nil {
+			return err
+		}
+	}
+	for _, record := range recordsThen {
+		if record.DeletedAt.Valid {
+			continue
+		}
+		alive[record.UUID] = true
+		if now, ok := recordNow[record.UUID]; ok && sameRecord(now, record) {
+			continue
+		}
+		if err := record.Stage(ctx); err != nil {
+			return err
+		}
+	}
+	for _, record := range recordsNow {
+		if !alive[record.UUID] {
+			if err := record.Delete(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	for _, zone := range zonesNow {
+		if !alive[zone.UUID] {
+			if err := zone.Delete(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sameZone reports whether two versions of a zone render the same.
+func sameZone(a, b rdb.Zone) bool {
+	return a.Name == b.Name && a.PrimaryNS == b.PrimaryNS && a.AdminEmail == b.AdminEmail &&
+		a.Refresh == b.Refresh && a.Retry == b.Retry && a.Expire == b.Expire && a.Minimum == b.Minimum &&
+		a.TTL == b.TTL && reflect.DeepEqual(a.NameServers, b.NameServers)
+}
+
+// sameRecord reports whether two versions of a record render the same.
+func sameRecord(a, b rdb.Record) bool {
+	return a.Type == b.Type && a.Host == b.Host && a.Content == b.Content &&
+		a.TTL == b.TTL && a.AddPTR == b.AddPTR && a.ZoneUUID == b.ZoneUUID
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/DrC0ns0le/bind-api/ansible"
 	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/DrC0ns0le/bind-api/reverse"
 )
 
 type Config struct {
@@ -96,6 +98,33 @@ func GetConfigHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(responseBody)
 }
 
+// checkConfig checks the value of a config created or updated, returning
+// the error to answer with when it is invalid.
+func checkConfig(c Config) *responseBody {
+	// Reverse networks are only parsed when rendering, reject bad ones early
+	if c.ConfigKey == reverse.ConfigKey {
+		if _, err := reverse.Parse(c.ConfigValue); err != nil {
+			return &responseBody{
+				Code:    3,
+				Message: "Invalid reverse network",
+				Data:    err.Error(),
+			}
+		}
+	}
+
+	// Servers are only parsed when deploying, reject bad ones early
+	if c.ConfigKey == ansible.ServersConfigKey {
+		if _, err := ansible.ParseServers(c.ConfigValue); err != nil {
+			return &responseBody{
+				Code:    4,
+				Message: "Invalid server",
+				Data:    err.Error(),
+			}
+		}
+	}
+	return nil
+}
+
 func CreateConfigHandler(w http.ResponseWriter, r *http.Request) {
 	c := new(Config)
 	err := json.NewDecoder(r.Body).Decode(c)
@@ -110,6 +139,12 @@ func CreateConfigHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if errorMsg := checkConfig(*c); errorMsg != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
 	config := &rdb.Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigValue, Staging: c.Staging}
 	if err = config.Create(r.Context()); err != nil {
 		responseBody := responseBody{
@@ -121,6 +156,7 @@ func CreateConfigHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(responseBody)
 		return
 	}
+	audit(r.Context(), "config.create", "", "", nil, Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigValue, Staging: c.Staging})
 
 	responseBody := responseBody{
 		Code:    0,
@@ -150,6 +186,12 @@ func UpdateConfigHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if errorMsg := checkConfig(*c); errorMsg != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
 	config := &rdb.Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigOld, Staging: c.Staging}
 	if err = config.Update(r.Context(), c.ConfigValue); err != nil {
 		responseBody := responseBody{
@@ -161,6 +203,9 @@ func UpdateConfigHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(responseBody)
 		return
 	}
+	audit(r.Context(), "config.update", "", "",
+		Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigOld},
+		Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigValue, Staging: c.Staging})
 
 	responseBody := responseBody{
 		Code:    0,
@@ -202,6 +247,7 @@ func DeleteConfigHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(responseBody)
 		return
 	}
+	audit(r.Context(), "config.delete", "", "", Config{ConfigKey: c.ConfigKey, ConfigValue: c.ConfigValue}, nil)
 
 	responseBody := responseBody{
 		Code:    0,
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"github.com/DrC0ns0le/bind-api/ansible"
 	"github.com/DrC0ns0le/bind-api/rdb"
 )
 
@@ -50,24 +49,3 @@ func GetDeployHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
-func DeployHandler(w http.ResponseWriter, r *http.Request) {
-	output, err := ansible.DeployConfig(r.Context())
-	if err != nil {
-		responseBody := responseBody{
-			Code:    1,
-			Message: "Unable to deploy changes",
-			Data:    err.Error(),
-		}
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(responseBody)
-		return
-	}
-
-	responseBody := responseBody{
-		Code:    0,
-		Message: "Successfully deployed changes",
-		Data:    output,
-	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(responseBody)
-}
@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/ansible"
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+type DeployJob struct {
+	ID          int64           `json:"id"`
+	Status      string          `json:"status"`
+	CreatedBy   string          `json:"created_by"`
+	CreatedByID string          `json:"created_by_id,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	Output      string          `json:"output,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Results     json.RawMessage `json:"results,omitempty"`
+	Owner       string          `json:"owner,omitempty"`
+	HeartbeatAt *time.Time      `json:"heartbeat_at,omitempty"`
+}
+
+// deployJobFromRDB converts a database deploy job into its API
+// representation.
+func deployJobFromRDB(j rdb.DeployJob) DeployJob {
+	job := DeployJob{
+		ID:          j.ID,
+		Status:      j.Status,
+		CreatedBy:   j.CreatedBy,
+		CreatedByID: j.CreatedByID,
+		CreatedAt:   j.CreatedAt,
+		Output:      j.Output,
+		Error:       j.Error,
+		Results:     j.Results,
+		Owner:       j.Owner,
+	}
+	if j.HeartbeatAt.Valid {
+		job.HeartbeatAt = &j.HeartbeatAt.Time
+	}
+	if j.StartedAt.Valid {
+		job.StartedAt = &j.StartedAt.Time
+	}
+	if j.FinishedAt.Valid {
+		job.FinishedAt = &j.FinishedAt.Time
+	}
+	return job
+}
+
+// deployOutputInterval is how often the output of a running job is stored,
+// for log streams served by other API servers.
+const deployOutputInterval = 2 * time.Second
+
+// deployLog is the output of a deploy job running on this server, which log
+// streams follow as it is written.
+type deployLog struct {
+	mu      sync.Mutex
+	output  bytes.Buffer
+	done    bool
+	changed chan struct{} // closed on every write and when done
+}
+
+func newDeployLog() *deployLog {
+	return &deployLog{changed: make(chan struct{})}
+}
+
+func (l *deployLog) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n, err := l.output.Write(b)
+	close(l.changed)
+	l.changed = make(chan struct{})
+	return n, err
+}
+
+func (l *deployLog) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.done = true
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// since returns the output after offset, whether the job is done and a
+// channel closed once there is more.
+func (l *deployLog) since(offset int) ([]byte, bool, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var output []byte
+	if offset < l.output.Len() {
+		output = append(output, l.output.Bytes()[offset:]...)
+	}
+	return output, l.done, l.changed
+}
+
+func (l *deployLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.output.String()
+}
+
+var (
+	// deployLogs holds the logs of the jobs running on this server.
+	deployLogs   = make(map[int64]*deployLog)
+	deployLogsMu sync.Mutex
+
+	// deployWake tells the worker a job was queued on this server.
+	deployWake = make(chan struct{}, 1)
+)
+
+func runningDeployLog(id int64) *deployLog {
+	deployLogsMu.Lock()
+	defer deployLogsMu.Unlock()
+	return deployLogs[id]
+}
+
+// DeployHandler queues a deploy of the committed configuration. The job runs
+// in the background, see GetDeployJobHandler and DeployJobLogHandler.
+func DeployHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Refuse to queue a deploy while the pipeline is busy
+	unlock, ok := lockPipeline(w, r, "deploy", 2)
+	if !ok {
+		return
+	}
+	unlock()
+
+	// or while one is pending, which the store enforces
+	principal, _ := policy.FromContext(r.Context())
+	job := rdb.DeployJob{CreatedBy: principal.Name, CreatedByID: principal.ID}
+	if err := job.Create(r.Context()); err != nil {
+		if errors.Is(err, rdb.ErrDeployPending) {
+			writeDeployPending(w, r)
+			return
+		}
+		responseBody := responseBody{
+			Code:    1,
+			Message: "Unable to queue deploy",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+
+	select {
+	case deployWake <- struct{}{}:
+	default:
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Deploy queued",
+		Data:    deployJobFromRDB(job),
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/deploy/jobs/%d", job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// writeDeployPending answers a deploy refused as another one is queued or
+// running, with who asked for it.
+func writeDeployPending(w http.ResponseWriter, r *http.Request) {
+	errorMsg := responseBody{
+		Code:    3,
+		Message: "Another deploy is queued or running",
+	}
+	for _, status := range []string{rdb.DeployRunning, rdb.DeployQueued} {
+		pending, err := (&rdb.DeployJob{Status: status}).Get(r.Context())
+		if err != nil || len(pending) == 0 {
+			continue
+		}
+		errorMsg.Message = "Another deploy is " + status
+		errorMsg.Data = PipelineLock{
+			Holder:   pending[0].CreatedBy,
+			HolderID: pending[0].CreatedByID,
+			Action:   "deploy",
+			Since:    pending[0].CreatedAt,
+		}
+		break
+	}
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(errorMsg)
+}
+
+// GetDeployJobsHandler lists deploy jobs, most recent first, without their
+// output, optionally only those with the given status.
+func GetDeployJobsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	jobs, err := (&rdb.DeployJob{Status: r.URL.Query().Get("status")}).Get(r.Context())
+	if err != nil {
+		responseBody := responseBody{
+			Code:    1,
+			Message: "Unable to retrieve deploy jobs",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+
+	J := []DeployJob{}
+	for _, job := range jobs {
+		job.Output = ""
+		J = append(J, deployJobFromRDB(job))
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Deploy jobs retrieved successfully",
+		Data:    J,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// GetDeployJobHandler retrieves a deploy job with its output so far.
+func GetDeployJobHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	job, ok := findDeployJob(w, r)
+	if !ok {
+		return
+	}
+	if l := runningDeployLog(job.ID); l != nil {
+		job.Output = l.String()
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Deploy job retrieved successfully",
+		Data:    deployJobFromRDB(job),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// DeployJobLogHandler streams the output of a deploy job until it finishes.
+// Clients accepting text/event-stream get server-sent events, one data line
+// per output line and a final done event holding the job, others get the
+// plain output as it is written.
+func DeployJobLogHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := findDeployJob(w, r)
+	if !ok {
+		return
+	}
+
+	events := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if events {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher := http.NewResponseController(w)
+
+	offset := 0
+	for {
+		var output []byte
+		var done bool
+		var more <-chan struct{}
+		var poll <-chan time.Time
+
+		if l := runningDeployLog(job.ID); l != nil {
+			output, done, more = l.since(offset)
+		} else {
+			// queued, or running on another server
+			if err := job.Find(r.Context()); err != nil {
+				return
+			}
+			if offset < len(job.Output) {
+				output = []byte(job.Output[offset:])
+			}
+			done = job.Status == rdb.DeploySucceeded || job.Status == rdb.DeployFailed
+			poll = time.After(time.Second)
+		}
+
+		// events carry whole lines until the end
+		if events && !done {
+			output = output[:bytes.LastIndexByte(output, '\n')+1]
+		}
+		if len(output) > 0 {
+			offset += len(output)
+			if events {
+				for _, line := range strings.Split(strings.TrimSuffix(string(output), "\n"), "\n") {
+					fmt.Fprintf(w, "data: %s\n", line)
+				}
+				fmt.Fprint(w, "\n")
+			} else {
+				w.Write(output)
+			}
+			flusher.Flush()
+		}
+
+		if done {
+			if events {
+				if err := job.Find(r.Context()); err == nil {
+					job.Output = ""
+					data, _ := json.Marshal(deployJobFromRDB(job))
+					fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+					flusher.Flush()
+				}
+			}
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-more:
+		case <-poll:
+		}
+	}
+}
+
+// findDeployJob looks up the deploy job of the id path value, answering 400
+// or 404 when there is none.
+func findDeployJob(w http.ResponseWriter, r *http.Request) (rdb.DeployJob, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Invalid deploy job id",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return rdb.DeployJob{}, false
+	}
+
+	job := rdb.DeployJob{ID: id}
+	if err := job.Find(r.Context()); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		w.Header().Set("Content-Type", "application/json")
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Deploy job not found",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMsg)
+		return rdb.DeployJob{}, false
+	}
+	return job, true
+}
+
+// RunDeployWorker runs the queued deploy jobs one at a time, as they are
+// queued on this server or every interval for those queued on others, until
+// ctx is done.
+//
+// Jobs are leased to Instance while they run. Those this server left
+// running when it stopped are marked failed on start, and those of other
+// servers once their lease expires.
+func RunDeployWorker(ctx context.Context, interval time.Duration) {
+	failStaleDeployJobs(ctx, Instance)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			job := rdb.DeployJob{Owner: Instance}
+			if err := job.ClaimQueued(ctx); err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					log.Printf("Unable to claim queued deploy jobs: %v", err)
+				}
+				break
+			}
+			runDeployJob(deployerContext(ctx, job), job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-deployWake:
+		}
+		failStaleDeployJobs(ctx, "")
+	}
+}
+
+// failStaleDeployJobs marks failed the running jobs whose lease expired, and
+// those of owner when it is set.
+func failStaleDeployJobs(ctx context.Context, owner string) {
+	reason := "interrupted, the API server running it stopped"
+	failed, err := (&rdb.DeployJob{Owner: owner, Error: reason}).FailStale(ctx, leaseDuration)
+	if err != nil {
+		log.Printf("Unable to fail interrupted deploy jobs: %v", err)
+	}
+	for _, job := range failed {
+		log.Printf("Deploy job %d of %s failed: %s", job.ID, job.Owner, reason)
+	}
+}
+
+// deployerContext runs job as the principal that queued it.
+func deployerContext(ctx context.Context, job rdb.DeployJob) context.Context {
+	return policy.WithPrincipal(ctx, policy.Principal{
+		Name:   job.CreatedBy,
+		ID:     job.CreatedByID,
+		Grants: []policy.Grant{{Role: policy.Operator}},
+	})
+}
+
+// runDeployJob deploys the configuration for a running job leased to
+// Instance, once it holds the pipeline lock, storing its output as it goes and its outcome once done.
+func runDeployJob(ctx context.Context, job rdb.DeployJob) error {
+	stopLease := keepLease(ctx, fmt.Sprintf("deploy job %d", job.ID), job.Renew)
+	defer stopLease()
+
+	l := newDeployLog()
+	deployLogsMu.Lock()
+	deployLogs[job.ID] = l
+	deployLogsMu.Unlock()
+	log.Printf("Running deploy job %d", job.ID)
+
+	ctx, unlock, err := waitPipeline(ctx, "deploy", func(lock rdb.PipelineLock) {
+		fmt.Fprintf(l, "Waiting for %s of %s since %s to finish\n", lock.Action, lock.Holder, lock.Since.Format(time.RFC3339))
+	})
+	if err == nil {
+		defer unlock()
+	}
+
+	// store the output now and then for other servers to follow
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(deployOutputInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				running := job
+				running.Output = l.String()
+				if err := running.Update(ctx); err != nil {
+					log.Printf("Unable to store the output of deploy job %d: %v", job.ID, err)
+				}
+			}
+		}
+	}()
+
+	var results []ansible.HostResult
+	if err == nil {
+		results, err = ansible.Deploy(ctx, l)
+	}
+	close(stop)
+	wg.Wait()
+
+	job.Status = rdb.DeploySucceeded
+	job.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	job.Output = l.String()
+	if results != nil {
+		job.Results, _ = json.Marshal(results)
+	}
+	if err != nil {
+		job.Status = rdb.DeployFailed
+		job.Error = err.Error()
+		log.Printf("Deploy job %d failed: %v", job.ID, err)
+	} else {
+		log.Printf("Deploy job %d succeeded", job.ID)
+	}
+	if err := job.Update(ctx); err != nil {
+		log.Printf("Unable to record the outcome of deploy job %d: %v", job.ID, err)
+	}
+
+	deployLogsMu.Lock()
+	delete(deployLogs, job.ID)
+	deployLogsMu.Unlock()
+	l.finish()
+
+	job.Output = ""
+	audit(ctx, "deploy", "", strconv.FormatInt(job.ID, 10), nil, deployJobFromRDB(job))
+	return err
+}
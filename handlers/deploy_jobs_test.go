@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestDeployHandlerPending(t *testing.T) {
+	a := newAPI(t)
+
+	var queued response
+	if status := a.do(operator, "POST", "/api/v1/deploy", nil, &queued); status != http.StatusAccepted {
+		t.Fatalf("POST /api/v1/deploy = %d %+v", status, queued)
+	}
+
+	var refused response
+	if status := a.do(operator, "POST", "/api/v1/deploy", nil, &refused); status != http.StatusConflict || refused.Code != 3 {
+		t.Fatalf("POST /api/v1/deploy while one is queued = %d %+v, want 409 code 3", status, refused)
+	}
+	var holder PipelineLock
+	json.Unmarshal(refused.Data, &holder)
+	if refused.Message != "Another deploy is queued" || holder.Holder != "ops" {
+		t.Errorf("POST /api/v1/deploy while one is queued = %+v, want the queued deploy of ops", refused)
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/DrC0ns0le/bind-api/commit"
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/DrC0ns0le/bind-api/render"
+	"github.com/DrC0ns0le/bind-api/zonefile"
+	"gopkg.in/yaml.v3"
+)
+
+// ExportZoneHandler returns a single zone as a file.
+//
+// The format query parameter selects bind (RFC 1035 master file, default),
+// json or yaml. By default the zone is exported as last committed to the
+// output repository; with staged=true the pending changes are included.
+func ExportZoneHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract zone UUID from URL
+	zone := rdb.Zone{UUID: r.PathValue("zone_uuid")}
+
+	// Find the zone by UUID
+	if err := zone.Find(r.Context()); err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Zone not found",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "bind"
+	}
+	if format != "bind" && format != "json" && format != "yaml" {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Unsupported export format, expected bind, json or yaml",
+			Data:    format,
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	staged := r.URL.Query().Get("staged") == "true"
+
+	var text []byte
+	var doc zonefile.Document
+	if staged {
+		if format == "bind" {
+			rendered, err := render.PreviewZone(r.Context(), zone.Name)
+			if err != nil {
+				errorMsg := responseBody{
+					Code:    3,
+					Message: "Zone rendering failed",
+					Data:    err.Error(),
+				}
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(errorMsg)
+				return
+			}
+			text = []byte(rendered)
+		} else {
+			records, err := (&rdb.Record{ZoneUUID: zone.UUID}).Get(r.Context())
+			if err != nil {
+				errorMsg := responseBody{
+					Code:    3,
+					Message: "Unable to retrieve records",
+					Data:    err.Error(),
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(errorMsg)
+				return
+			}
+			doc = zonefile.NewDocument(zone, records)
+		}
+	} else if format == "bind" {
+		committed, err := commit.ReadCommitted(zone.Name + ".conf")
+		if err != nil {
+			errorMsg := responseBody{
+				Code:    4,
+				Message: "Unable to read committed zone",
+				Data:    err.Error(),
+			}
+			if errors.Is(err, os.ErrNotExist) {
+				errorMsg.Message = "Zone has not been committed yet"
+				w.WriteHeader(http.StatusNotFound)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			json.NewEncoder(w).Encode(errorMsg)
+			return
+		}
+		text = committed
+	} else {
+		// the committed versions rather than the rendered file, which
+		// holds glue and generated PTR records as ordinary ones
+		committed, records, err := (&rdb.Zone{UUID: zone.UUID}).Committed(r.Context())
+		if err != nil {
+			errorMsg := responseBody{
+				Code:    4,
+				Message: "Unable to read committed zone",
+				Data:    err.Error(),
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				errorMsg.Message = "Zone has not been committed yet"
+				w.WriteHeader(http.StatusNotFound)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			json.NewEncoder(w).Encode(errorMsg)
+			return
+		}
+		doc = zonefile.NewDocument(committed, records)
+	}
+
+	var err error
+	switch format {
+	case "json":
+		text, err = json.MarshalIndent(doc, "", "  ")
+	case "yaml":
+		text, err = yaml.Marshal(doc)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contentTypes := map[string]string{
+		"bind": "text/dns",
+		"json": "application/json",
+		"yaml": "application/yaml",
+	}
+	extensions := map[string]string{
+		"bind": "conf",
+		"json": "json",
+		"yaml": "yaml",
+	}
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", zone.Name+"."+extensions[format]))
+	w.WriteHeader(http.StatusOK)
+	w.Write(text)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+// export returns the status and body of an export of the zone.
+func (a *api) export(zoneUUID, query string) (int, string) {
+	a.t.Helper()
+
+	r := httptest.NewRequest("GET", "/api/v1/zones/"+zoneUUID+"/export?"+query, nil)
+	r = r.WithContext(policy.WithPrincipal(r.Context(), operator))
+	w := httptest.NewRecorder()
+	a.mux.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
+
+func TestExportZoneHandlerCommitted(t *testing.T) {
+	a := newAPI(t)
+	ctx := context.Background()
+
+	zone := rdb.Zone{
+		UUID:        rdb.ZoneUUID("example.org"),
+		Name:        "example.org",
+		PrimaryNS:   "ns1.example.org",
+		AdminEmail:  "hostmaster.example.org",
+		Refresh:     7200,
+		Retry:       3600,
+		Expire:      1209600,
+		Minimum:     300,
+		TTL:         3600,
+		NameServers: rdb.NameServers{{Host: "ns1", Addresses: []string{"192.0.2.1"}}},
+		Staging:     true,
+	}
+	if err := zone.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := a.export(zone.UUID, "format=json"); status != http.StatusNotFound {
+		t.Errorf("export of an uncommitted zone = %d, want 404", status)
+	}
+
+	for _, record := range []rdb.Record{
+		{UUID: "8c7d3b52-0000-4000-8000-000000000001", ZoneUUID: zone.UUID, Host: "www", Type: "A", Content: "192.0.2.10", TTL: 300, AddPTR: true, Staging: true},
+		{UUID: "8c7d3b52-0000-4000-8000-000000000002", ZoneUUID: zone.UUID, Host: "@", Type: "MX", Content: "10 mail.example.org.", TTL: 300, Staging: true},
+		{UUID: "8c7d3b52-0000-4000-8000-000000000003", ZoneUUID: zone.UUID, Host: "old", Type: "A", Content: "192.0.2.99", TTL: 300, Staging: true},
+	} {
+		if err := record.Create(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := (&rdb.Record{ZoneUUID: zone.UUID, UUID: "8c7d3b52-0000-4000-8000-000000000003"}).Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := zone.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"json", "yaml"} {
+		status, committed := a.export(zone.UUID, "format="+format)
+		if status != http.StatusOK {
+			t.Fatalf("committed %s export = %d %s", format, status, committed)
+		}
+		status, staged := a.export(zone.UUID, "format="+format+"&staged=true")
+		if status != http.StatusOK {
+			t.Fatalf("staged %s export = %d %s", format, status, staged)
+		}
+		if committed != staged {
+			t.Errorf("committed %s export of an unchanged zone =\n%s\nwant the staged export\n%s", format, committed, staged)
+		}
+	}
+
+	if _, committed := a.export(zone.UUID, "format=json"); !strings.Contains(committed, `"add_ptr": true`) || strings.Contains(committed, "192.0.2.99") || strings.Contains(committed, "in-addr.arpa") {
+		t.Errorf("committed export = %s, want www with add_ptr and neither the deleted record nor PTRs", committed)
+	}
+
+	// staged changes are left out of the committed export
+	www := rdb.Record{UUID: "8c7d3b52-0000-4000-8000-000000000001", ZoneUUID: zone.UUID, Host: "www", Type: "A", Content: "192.0.2.11", TTL: 300, Staging: true}
+	if err := www.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, committed := a.export(zone.UUID, "format=json")
+	_, staged := a.export(zone.UUID, "format=json&staged=true")
+	if committed == staged {
+		t.Errorf("committed export = %s, want the staged change left out", committed)
+	}
+}
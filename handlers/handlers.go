@@ -3,6 +3,9 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
 )
 
 type responseBody struct {
@@ -20,3 +23,29 @@ func CatchAllHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
 	json.NewEncoder(w).Encode(responseBody)
 }
+
+// canEdit reports whether the caller may stage changes to zone and its
+// records, answering 403 with code when it may not. A zone with only its
+// UUID set is looked up first.
+func canEdit(w http.ResponseWriter, r *http.Request, zone rdb.Zone, code int) bool {
+	var reason interface{}
+	if zone.Name == "" {
+		if err := zone.Find(r.Context()); err != nil {
+			reason = err.Error()
+		}
+	}
+
+	principal, _ := policy.FromContext(r.Context())
+	if reason == nil && principal.CanEdit(zone) {
+		return true
+	}
+
+	responseBody := responseBody{
+		Code:    code,
+		Message: "Not allowed to change zone " + zone.Name,
+		Data:    reason,
+	}
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(responseBody)
+	return false
+}
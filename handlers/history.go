@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+type RecordVersion struct {
+	Version   int64     `json:"version"`
+	ChangedAt time.Time `json:"changed_at"`
+	Record    Record    `json:"record"`
+}
+
+type ZoneVersion struct {
+	Version   int64     `json:"version"`
+	ChangedAt time.Time `json:"changed_at"`
+	Zone      Zone      `json:"zone"`
+}
+
+// GetRecordHistoryHandler lists every version of a record, oldest first,
+// including the versions after it was deleted.
+func GetRecordHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	zoneUUID := r.PathValue("zone_uuid")
+	versions, err := (&rdb.Record{UUID: r.PathValue("record_uuid")}).History(r.Context())
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Unable to retrieve record history",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if len(versions) == 0 || versions[0].Record.ZoneUUID != zoneUUID {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Record not found",
+			Data:    nil,
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	history := make([]RecordVersion, 0, len(versions))
+	for _, v := range versions {
+		history = append(history, RecordVersion{
+			Version:   v.Version,
+			ChangedAt: v.ChangedAt,
+			Record:    recordFromRDB(v.Record),
+		})
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Record history retrieved successfully",
+		Data:    history,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// GetZoneHistoryHandler lists every version of a zone, oldest first.
+func GetZoneHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	versions, err := (&rdb.Zone{UUID: r.PathValue("zone_uuid")}).History(r.Context())
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Unable to retrieve zone history",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if len(versions) == 0 {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Zone not found",
+			Data:    nil,
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	history := make([]ZoneVersion, 0, len(versions))
+	for _, v := range versions {
+		history = append(history, ZoneVersion{
+			Version:   v.Version,
+			ChangedAt: v.ChangedAt,
+			Zone:      zoneFromRDB(v.Zone),
+		})
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Zone history retrieved successfully",
+		Data:    history,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
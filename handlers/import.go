@@ -0,0 +1,582 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/DrC0ns0le/bind-api/validation"
+	"github.com/DrC0ns0le/bind-api/zonefile"
+	"github.com/google/uuid"
+)
+
+// maxImportSize caps the size of an uploaded zone file including its $INCLUDE files
+const maxImportSize = 32 << 20
+
+type importReport struct {
+	Zone        Zone               `json:"zone"`
+	ZoneCreated bool               `json:"zone_created"`
+	DryRun      bool               `json:"dry_run"`
+	Created     Records            `json:"created"`
+	Skipped     []zonefile.Skipped `json:"skipped"`
+	Warnings    []string           `json:"warnings,omitempty"`
+}
+
+// ImportZoneHandler stages the contents of an RFC 1035 zone file.
+//
+// The zone file is either the raw request body, or the "zone" part of a
+// multipart/form-data upload whose other file parts are available to
+// $INCLUDE directives. With a zone UUID in the path the file is imported
+// into that zone, and rejected when its origin names another one. Without
+// it the zone named by the origin query parameter or the file's SOA record
+// is used, and created from that SOA record when it does not exist yet.
+// With dry_run=true nothing is staged.
+func ImportZoneHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	zone := rdb.Zone{UUID: r.PathValue("zone_uuid")}
+	origin := r.URL.Query().Get("origin")
+
+	// Find the zone by UUID if importing into an existing zone
+	if zone.UUID != "" {
+		if err := zone.Find(r.Context()); err != nil {
+			errorMsg := responseBody{
+				Code:    1,
+				Message: "Zone not found",
+				Data:    err.Error(),
+			}
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(errorMsg)
+			return
+		}
+		if origin != "" && !equalZoneNames(origin, zone.Name) {
+			errorMsg := responseBody{
+				Code:    6,
+				Message: "Origin does not match the zone",
+				Data:    origin,
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(errorMsg)
+			return
+		}
+		origin = zone.Name
+	}
+
+	body, fileName, includes, err := readZoneUpload(w, r)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	rrs, err := zonefile.Parse(bytes.NewReader(body), origin, fileName, includes)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Unable to parse zone file",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	// a zone file for another zone is not imported into the path zone
+	if soa := zonefile.Origin(rrs); zone.UUID != "" && soa != "" && !equalZoneNames(soa, zone.Name) {
+		errorMsg := responseBody{
+			Code:    6,
+			Message: "Origin does not match the zone",
+			Data:    soa,
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	result, err := zonefile.Convert(origin, rrs)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Unable to parse zone file",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	exists := zone.UUID != ""
+	if !exists {
+		if zone, exists, err = importTarget(r.Context(), result.Origin); err != nil {
+			errorMsg := responseBody{
+				Code:    4,
+				Message: "Failed to import zone",
+				Data:    err.Error(),
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(errorMsg)
+			return
+		}
+	}
+	if !canEdit(w, r, zone, 5) {
+		return
+	}
+
+	report, err := importZone(r.Context(), zone, exists, result, r.URL.Query().Get("dry_run") == "true")
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    4,
+			Message: "Failed to import zone",
+			Data: map[string]interface{}{
+				"error":  err.Error(),
+				"report": report,
+			},
+		}
+		w.WriteHeader(importStatus(err))
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	response := responseBody{
+		Code:    0,
+		Message: "Zone imported successfully",
+		Data:    report,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// importTarget returns the zone an import of origin goes into, matching
+// its name regardless of case, and whether it exists. A zone that does not
+// exist yet is returned as it would be created.
+func importTarget(ctx context.Context, origin string) (rdb.Zone, bool, error) {
+	// Zone UUIDs are derived from the name, see CreateZoneHandler
+	zone := rdb.Zone{UUID: uuid.NewSHA1(dnsNamespaceUUID, []byte(origin)).String()}
+	err := zone.Find(ctx)
+	if err == nil {
+		return zone, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return rdb.Zone{}, false, err
+	}
+
+	zones, err := (&rdb.Zone{}).Get(ctx)
+	if err != nil {
+		return rdb.Zone{}, false, err
+	}
+	for _, z := range zones {
+		if equalZoneNames(z.Name, origin) {
+			return z, true, nil
+		}
+	}
+	return rdb.Zone{UUID: zone.UUID, Name: origin}, false, nil
+}
+
+// equalZoneNames reports whether a and b name the same zone.
+func equalZoneNames(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+// invalidImport is an import rejected for what it holds rather than
+// because the store failed.
+type invalidImport struct {
+	err error
+}
+
+func (e invalidImport) Error() string { return e.err.Error() }
+
+// importStatus is the status answering a failed import with err.
+func importStatus(err error) int {
+	if errors.As(err, &invalidImport{}) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// importZone stages a converted zone into zone, creating it when it does not
+// exist yet and skipping records already present in it. The whole import is
+// checked before anything is staged, and what was staged is discarded again
+// when staging fails part way.
+func importZone(ctx context.Context, zone rdb.Zone, exists bool, result zonefile.Result, dryRun bool) (importReport, error) {
+	report := importReport{
+		DryRun:   dryRun,
+		Skipped:  result.Skipped,
+		Warnings: result.Warnings,
+	}
+
+	original := zone
+	updated := false
+	switch {
+	case !exists:
+		if result.SOA == nil {
+			return report, invalidImport{fmt.Errorf("zone %s does not exist and the import has no SOA record", result.Origin)}
+		}
+		if len(result.NameServers) == 0 {
+			return report, invalidImport{fmt.Errorf("zone %s does not exist and the import has no apex NS records", result.Origin)}
+		}
+		zoneUUID := zone.UUID
+		zone = *result.SOA
+		zone.UUID = zoneUUID
+		zone.NameServers = result.NameServers
+		zone.Staging = true
+		if errorMsg := checkNewZone(zone.Name, zone.NameServers); errorMsg != nil {
+			return report, invalidImport{fmt.Errorf("%s: %v", errorMsg.Message, errorMsg.Data)}
+		}
+		report.ZoneCreated = true
+	case result.SOA != nil || len(result.NameServers) > 0:
+		if result.SOA != nil {
+			zone.PrimaryNS = result.SOA.PrimaryNS
+			zone.AdminEmail = result.SOA.AdminEmail
+			zone.Refresh = result.SOA.Refresh
+			zone.Retry = result.SOA.Retry
+			zone.Expire = result.SOA.Expire
+			zone.Minimum = result.SOA.Minimum
+			zone.TTL = result.SOA.TTL
+		}
+		if len(result.NameServers) > 0 {
+			if errs := validation.NameServers(zone.Name, result.NameServers); errs != nil {
+				return report, invalidImport{fmt.Errorf("invalid name servers: %w", errs)}
+			}
+			zone.NameServers = result.NameServers
+		}
+		updated = true
+	}
+	report.Zone = zoneFromRDB(zone)
+
+	// Collect what the zone already holds so the import can be repeated safely
+	existing := make(map[string]bool)
+	if !report.ZoneCreated {
+		records, err := (&rdb.Record{ZoneUUID: zone.UUID}).Get(ctx)
+		if err != nil {
+			return report, err
+		}
+		for _, record := range records {
+			if !record.DeletedAt.Valid {
+				existing[recordKey(record)] = true
+			}
+		}
+	}
+
+	var records []rdb.Record
+	for _, record := range result.Records {
+		if record.TTL == 0 {
+			record.TTL = 3600
+		}
+		validation.Normalize(&record)
+		if errs := validation.Record(record); errs != nil {
+			report.Skipped = append(report.Skipped, zonefile.Skipped{
+				Record: fmt.Sprintf("%s %d IN %s %s", record.Host, record.TTL, record.Type, record.Content),
+				Reason: errs.Error(),
+			})
+			continue
+		}
+		if existing[recordKey(record)] {
+			report.Skipped = append(report.Skipped, zonefile.Skipped{
+				Record: fmt.Sprintf("%s %d IN %s %s", record.Host, record.TTL, record.Type, record.Content),
+				Reason: "record already exists",
+			})
+			continue
+		}
+		existing[recordKey(record)] = true
+
+		record.UUID = uuid.New().String()
+		record.ZoneUUID = zone.UUID
+		record.Staging = true
+		records = append(records, record)
+		report.Created = append(report.Created, recordFromRDB(record))
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	var err error
+	switch {
+	case report.ZoneCreated:
+		err = zone.Create(ctx)
+	case updated:
+		err = zone.Update(ctx)
+	}
+	if err != nil {
+		report.ZoneCreated = false
+		report.Created = nil
+		return report, err
+	}
+	for i, record := range records {
+		if err := record.Create(ctx); err != nil {
+			undoImport(ctx, original, report.ZoneCreated, updated, records[:i])
+			report.ZoneCreated = false
+			report.Created = nil
+			return report, err
+		}
+	}
+
+	switch {
+	case report.ZoneCreated:
+		audit(ctx, "zone.create", zone.UUID, zone.UUID, nil, zoneFromRDB(zone))
+	case updated:
+		audit(ctx, "zone.update", zone.UUID, zone.UUID, zoneFromRDB(original), zoneFromRDB(zone))
+	}
+	for _, record := range records {
+		audit(ctx, "record.create", zone.UUID, record.UUID, nil, recordFromRDB(record))
+	}
+	return report, nil
+}
+
+// undoImport discards what a failed import staged: the records created,
+// then the zone, which is put back as it was when the import only updated it.
+func undoImport(ctx context.Context, original rdb.Zone, created, updated bool, records []rdb.Record) {
+	for _, record := range records {
+		if _, err := (&rdb.Record{ZoneUUID: record.ZoneUUID, UUID: record.UUID}).Discard(ctx); err != nil {
+			log.Printf("Unable to discard record %s of a failed import: %v", record.UUID, err)
+		}
+	}
+	switch {
+	case created || (updated && !original.Staging):
+		if _, err := (&rdb.Zone{UUID: original.UUID}).Discard(ctx); err != nil {
+			log.Printf("Unable to discard zone %s of a failed import: %v", original.UUID, err)
+		}
+	case updated:
+		if err := original.Stage(ctx); err != nil {
+			log.Printf("Unable to restore zone %s of a failed import: %v", original.UUID, err)
+		}
+	}
+}
+
+// recordKey identifies a record by owner, type and content.
+func recordKey(record rdb.Record) string {
+	return strings.ToLower(record.Host) + " " + strings.ToUpper(record.Type) + " " + record.Content
+}
+
+// readZoneUpload returns the zone file, its name and the files available to $INCLUDE.
+func readZoneUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, fs.FS, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		return body, "zone", nil, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var body []byte
+	fileName := "zone"
+	includes := includeFS{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		if part.FormName() == "zone" {
+			body = data
+			if part.FileName() != "" {
+				fileName = part.FileName()
+			}
+			continue
+		}
+		if part.FileName() != "" {
+			includes[part.FileName()] = data
+		}
+	}
+
+	if body == nil {
+		return nil, "", nil, errors.New("multipart upload has no zone part")
+	}
+	return body, fileName, includes, nil
+}
+
+// includeFS serves uploaded files to $INCLUDE by name. Directives naming a
+// path the upload does not have fall back to the file's base name, as
+// browsers only send base names.
+type includeFS map[string][]byte
+
+func (f includeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	data, ok := f[name]
+	if !ok {
+		data, ok = f[path.Base(name)]
+	}
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &includeFile{name: path.Base(name), Reader: bytes.NewReader(data)}, nil
+}
+
+// includeFile is an uploaded file opened by includeFS, its own fs.FileInfo.
+type includeFile struct {
+	name string
+	*bytes.Reader
+}
+
+func (f *includeFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *includeFile) Close() error               { return nil }
+func (f *includeFile) Name() string               { return f.name }
+func (f *includeFile) Mode() fs.FileMode          { return 0444 }
+func (f *includeFile) ModTime() time.Time         { return time.Time{} }
+func (f *includeFile) IsDir() bool                { return false }
+func (f *includeFile) Sys() interface{}           { return nil }
+
+// TSIGKeys are the keys zone transfers can be signed with, by name. They
+// are configured on the server, so that secrets are never sent to the API.
+var TSIGKeys map[string]zonefile.TSIG
+
+// TransferZoneHandler stages a zone pulled by AXFR from an existing name server.
+//
+// The request body names the zone, the server to transfer from and
+// optionally the TSIG key to sign the transfer with, one of TSIGKeys. The
+// zone is created from the transferred SOA when it does not exist yet, and
+// records already present are skipped.
+func TransferZoneHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse request body
+	var requestData struct {
+		Zone    string `json:"zone"`
+		Server  string `json:"server"`
+		TSIGKey string `json:"tsig_key"`
+		DryRun  bool   `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	var missingFields []string
+	if requestData.Zone == "" {
+		missingFields = append(missingFields, "zone")
+	}
+	if requestData.Server == "" {
+		missingFields = append(missingFields, "server")
+	}
+	if len(missingFields) > 0 {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Missing fields",
+			Data: map[string]string{
+				"missing_fields": strings.Join(missingFields, ", "),
+			},
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	var tsig *zonefile.TSIG
+	if requestData.TSIGKey != "" {
+		key, ok := TSIGKeys[requestData.TSIGKey]
+		if !ok {
+			errorMsg := responseBody{
+				Code:    6,
+				Message: "Unknown TSIG key",
+				Data:    requestData.TSIGKey,
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(errorMsg)
+			return
+		}
+		tsig = &key
+	}
+
+	rrs, err := zonefile.Transfer(r.Context(), requestData.Zone, requestData.Server, tsig)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Zone transfer failed",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	result, err := zonefile.Convert(requestData.Zone, rrs)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Zone transfer failed",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	zone, exists, err := importTarget(r.Context(), result.Origin)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    4,
+			Message: "Failed to import zone",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if !canEdit(w, r, zone, 5) {
+		return
+	}
+
+	report, err := importZone(r.Context(), zone, exists, result, requestData.DryRun)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    4,
+			Message: "Failed to import zone",
+			Data: map[string]interface{}{
+				"error":  err.Error(),
+				"report": report,
+			},
+		}
+		w.WriteHeader(importStatus(err))
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	response := responseBody{
+		Code:    0,
+		Message: "Zone transferred successfully",
+		Data:    report,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+// importFile posts zone file to path as the caller p, decoding the response
+// into v, and returns the status.
+func (a *api) importFile(p policy.Principal, path, file string, v interface{}) int {
+	a.t.Helper()
+
+	r := httptest.NewRequest("POST", path, strings.NewReader(file))
+	r = r.WithContext(policy.WithPrincipal(r.Context(), p))
+	w := httptest.NewRecorder()
+	a.mux.ServeHTTP(w, r)
+
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		a.t.Fatalf("POST %s answered %q: %v", path, w.Body.String(), err)
+	}
+	return w.Code
+}
+
+const importedZone = `$ORIGIN example.org.
+@    3600 IN SOA ns1.example.org. hostmaster.example.org. 1 7200 3600 1209600 300
+@    3600 IN NS  ns1.example.net.
+www  300  IN A   192.0.2.10
+mail 300  IN A   192.0.2.25
+bad  1    IN TXT "too short a TTL"
+`
+
+func TestImportZoneHandler(t *testing.T) {
+	a := newAPI(t)
+	ctx := context.Background()
+
+	var got struct {
+		Code int          `json:"code"`
+		Data importReport `json:"data"`
+	}
+	if status := a.importFile(operator, "/api/v1/zones/import?dry_run=true", importedZone, &got); status != http.StatusOK {
+		t.Fatalf("dry run import = %d %+v", status, got)
+	}
+	if !got.Data.ZoneCreated || len(got.Data.Created) != 2 {
+		t.Errorf("dry run import = %+v, want the zone and 2 records", got.Data)
+	}
+	if zones, _ := (&rdb.Zone{}).Get(ctx); len(zones) != 0 {
+		t.Fatalf("dry run import staged %+v", zones)
+	}
+
+	if status := a.importFile(operator, "/api/v1/zones/import", importedZone, &got); status != http.StatusOK {
+		t.Fatalf("import = %d %+v", status, got)
+	}
+	zoneUUID := rdb.ZoneUUID("example.org")
+	records, err := (&rdb.Record{ZoneUUID: zoneUUID}).Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || len(got.Data.Skipped) != 1 {
+		t.Errorf("import staged %+v and skipped %+v, want www and mail staged, bad skipped", records, got.Data.Skipped)
+	}
+
+	// importing again only skips the records staged the first time
+	if status := a.importFile(operator, "/api/v1/zones/"+zoneUUID+"/import", importedZone, &got); status != http.StatusOK || len(got.Data.Created) != 0 {
+		t.Errorf("second import = %d %+v, want nothing created", status, got.Data)
+	}
+
+	var failed response
+	for name, file := range map[string]string{
+		"reverse zone of no network": "$ORIGIN 300.2.0.192.in-addr.arpa.\n@ 3600 IN SOA ns1.example.org. hostmaster.example.org. 1 7200 3600 1209600 300\n@ 3600 IN NS ns1.example.org.\n1 300 IN PTR host.example.org.\n",
+		"invalid name servers":       "$ORIGIN example.com.\n@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300\n@ 3600 IN NS ns1.example.net.\n@ 3600 IN NS ns1.example.net.\nwww 300 IN A 192.0.2.10\n",
+		"no name servers":            "$ORIGIN example.com.\n@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300\nwww 300 IN A 192.0.2.10\n",
+	} {
+		if status := a.importFile(operator, "/api/v1/zones/import", file, &failed); status != http.StatusBadRequest || failed.Code != 4 {
+			t.Errorf("import of %s = %d %+v, want 400 code 4", name, status, failed)
+		}
+	}
+	zones, err := (&rdb.Zone{}).Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 1 {
+		t.Errorf("zones = %+v, want example.org only", zones)
+	}
+}
+
+func TestUndoImport(t *testing.T) {
+	newAPI(t)
+	ctx := context.Background()
+
+	zone := rdb.Zone{UUID: rdb.ZoneUUID("example.org"), Name: "example.org", PrimaryNS: "ns1.example.org", AdminEmail: "hostmaster.example.org", TTL: 3600, NameServers: rdb.NameServers{{Host: "ns1.example.net."}}, Staging: true}
+	if err := zone.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := zone.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := zone.Find(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := zone
+	updated.TTL = 300
+	if err := updated.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	record := rdb.Record{UUID: "2b0e3c1a-0000-4000-8000-000000000001", ZoneUUID: zone.UUID, Host: "www", Type: "A", Content: "192.0.2.10", TTL: 300, Staging: true}
+	if err := record.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	undoImport(ctx, zone, false, true, []rdb.Record{record})
+
+	staged, err := (&rdb.Zone{}).GetStaging(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(staged) != 0 {
+		t.Errorf("staged zones = %+v, want the update discarded", staged)
+	}
+	if records, _ := (&rdb.Record{ZoneUUID: zone.UUID}).Get(ctx); len(records) != 0 {
+		t.Errorf("records = %+v, want the imported record discarded", records)
+	}
+	got := rdb.Zone{UUID: zone.UUID}
+	if err := got.Find(ctx); err != nil || got.TTL != 3600 {
+		t.Errorf("zone = %+v %v, want its committed TTL back", got, err)
+	}
+}
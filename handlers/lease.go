@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Instance names this API server among those sharing the database. The
+// scheduled applies and deploy jobs it runs are leased to it, so that other
+// servers only fail them once it stops renewing the lease.
+var Instance string
+
+// leaseDuration is how long a lease lasts without being renewed.
+const leaseDuration = 2 * time.Minute
+
+// keepLease calls renew every quarter of leaseDuration until the returned
+// function is called.
+func keepLease(ctx context.Context, name string, renew func(context.Context) error) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(leaseDuration / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := renew(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("Unable to renew the lease on %s: %v", name, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DrC0ns0le/bind-api/lint"
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+// LintZoneHandler checks the staged state of a zone for CNAME conflicts,
+// duplicate records and dangling targets.
+//
+// Errors in the report block ApplyStagingHandler, warnings do not.
+func LintZoneHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract zone UUID from URL
+	zone := rdb.Zone{UUID: r.PathValue("zone_uuid")}
+
+	// Find the zone by UUID
+	if err := zone.Find(r.Context()); err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Zone not found",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	report, err := lint.Zone(r.Context(), zone)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Unable to lint zone",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	message := "Zone passed linting"
+	if report.HasErrors() {
+		message = "Zone failed linting"
+	}
+
+	response := responseBody{
+		Code:    0,
+		Message: message,
+		Data:    report,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+// pipelineRetry is how often waitPipeline tries to take a taken lock.
+const pipelineRetry = time.Second
+
+type PipelineLock struct {
+	Holder   string    `json:"holder"`
+	HolderID string    `json:"holder_id,omitempty"`
+	Action   string    `json:"action"`
+	Since    time.Time `json:"since"`
+}
+
+// pipelineHeld marks a context whose caller already holds the pipeline
+// lock, for the handlers it runs to not take it again.
+type pipelineHeld struct{}
+
+// lockPipeline takes the pipeline lock for action as the caller of r and
+// returns the function that releases it. It answers 409 with the holder and
+// returns false when the lock is taken.
+func lockPipeline(w http.ResponseWriter, r *http.Request, action string, code int) (func(), bool) {
+	if r.Context().Value(pipelineHeld{}) != nil {
+		return func() {}, true
+	}
+
+	principal, _ := policy.FromContext(r.Context())
+	lock := rdb.PipelineLock{Holder: principal.Name, HolderID: principal.ID, Action: action}
+	unlock, err := lock.Lock(r.Context())
+	if err != nil {
+		status := http.StatusInternalServerError
+		var data interface{} = err.Error()
+		if errors.Is(err, rdb.ErrPipelineLocked) {
+			status = http.StatusConflict
+			data = PipelineLock(lock)
+		}
+		errorMsg := responseBody{
+			Code:    code,
+			Message: "Another apply, rollback or deploy is in progress",
+			Data:    data,
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMsg)
+		return nil, false
+	}
+	return unlock, true
+}
+
+// waitPipeline takes the pipeline lock for action as the principal of ctx,
+// waiting for it while it is taken, and returns a context marking it held
+// along with the function that releases it. waiting is called with the
+// holder the first time the lock is found taken.
+func waitPipeline(ctx context.Context, action string, waiting func(rdb.PipelineLock)) (context.Context, func(), error) {
+	if ctx.Value(pipelineHeld{}) != nil {
+		return ctx, func() {}, nil
+	}
+
+	principal, _ := policy.FromContext(ctx)
+	for {
+		lock := rdb.PipelineLock{Holder: principal.Name, HolderID: principal.ID, Action: action}
+		unlock, err := lock.Lock(ctx)
+		if err == nil {
+			return context.WithValue(ctx, pipelineHeld{}, true), unlock, nil
+		}
+		if !errors.Is(err, rdb.ErrPipelineLocked) {
+			return ctx, nil, err
+		}
+		if waiting != nil {
+			waiting(lock)
+			waiting = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx, nil, ctx.Err()
+		case <-time.After(pipelineRetry):
+		}
+	}
+}
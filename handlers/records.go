@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/DrC0ns0le/bind-api/validation"
 	"github.com/google/uuid"
 )
 
@@ -29,7 +30,7 @@ type Record struct {
 type Records []Record
 
 // Predefined namespace UUID for DNS purposes
-var dnsNamespaceUUID = uuid.Must(uuid.Parse("6ba7b810-9dad-11d1-80b4-00c04fd430c8"))
+var dnsNamespaceUUID = rdb.ZoneNamespace
 
 func GetZoneRecordsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -37,7 +38,25 @@ func GetZoneRecordsHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract zone UUID from URL
 	zoneUUID := r.PathValue("zone_uuid")
 
-	records, err := (&rdb.Record{ZoneUUID: zoneUUID}).Get(r.Context())
+	// as_of shows the records as they were at that moment
+	var records []rdb.Record
+	var err error
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		at, perr := parseTime(v)
+		if perr != nil {
+			errorMsg := responseBody{
+				Code:    2,
+				Message: "Invalid as_of, expected RFC 3339 or unix seconds",
+				Data:    perr.Error(),
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(errorMsg)
+			return
+		}
+		records, err = (&rdb.Record{ZoneUUID: zoneUUID}).GetAsOf(r.Context(), at)
+	} else {
+		records, err = (&rdb.Record{ZoneUUID: zoneUUID}).Get(r.Context())
+	}
 
 	if err != nil {
 		errorMsg := responseBody{
@@ -154,6 +173,10 @@ func CreateRecordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canEdit(w, r, zone, 5) {
+		return
+	}
+
 	// Parse request body
 	var requestData struct {
 		Type    string   `json:"type"`
@@ -206,6 +229,19 @@ func CreateRecordHandler(w http.ResponseWriter, r *http.Request) {
 		Tags:     requestData.Tags,
 	}
 
+	// Validate the record before staging it
+	validation.Normalize(&newRecord)
+	if errs := validation.Record(newRecord); errs != nil {
+		errorMsg := responseBody{
+			Code:    4,
+			Message: "Invalid record",
+			Data:    errs,
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
 	// Create the record
 	if err := newRecord.Create(r.Context()); err != nil {
 		errorMsg := responseBody{
@@ -217,6 +253,7 @@ func CreateRecordHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(errorMsg)
 		return
 	}
+	audit(r.Context(), "record.create", zone.UUID, newRecord.UUID, nil, recordFromRDB(newRecord))
 
 	// Respond with the created zone
 	responseBody := responseBody{
@@ -257,6 +294,11 @@ func UpdateRecordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canEdit(w, r, rdb.Zone{UUID: record.ZoneUUID}, 6) {
+		return
+	}
+	before := recordFromRDB(record)
+
 	// Parse request body
 	var requestData struct {
 		Type    string   `json:"type"`
@@ -317,6 +359,19 @@ func UpdateRecordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Validate the record before staging it
+	validation.Normalize(&record)
+	if errs := validation.Record(record); errs != nil {
+		errorMsg := responseBody{
+			Code:    5,
+			Message: "Invalid record",
+			Data:    errs,
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
 	// Update the record
 	if err := record.Update(r.Context()); err != nil {
 		errorMsg := responseBody{
@@ -328,6 +383,7 @@ func UpdateRecordHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(errorMsg)
 		return
 	}
+	audit(r.Context(), "record.update", record.ZoneUUID, record.UUID, before, recordFromRDB(record))
 
 	// Respond with the updated zone
 	response := responseBody{
@@ -369,6 +425,10 @@ func DeleteRecordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canEdit(w, r, rdb.Zone{UUID: record.ZoneUUID}, 3) {
+		return
+	}
+
 	// Delete record from database
 	if err := record.Delete(r.Context()); err != nil {
 		errorMsg := responseBody{
@@ -380,6 +440,7 @@ func DeleteRecordHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(errorMsg)
 		return
 	}
+	audit(r.Context(), "record.delete", record.ZoneUUID, record.UUID, recordFromRDB(record), nil)
 
 	// Respond with success message
 	successMsg := responseBody{
@@ -391,3 +452,26 @@ func DeleteRecordHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(successMsg)
 
 }
+
+// recordFromRDB converts a database record into its API representation.
+func recordFromRDB(record rdb.Record) Record {
+	return Record{
+		UUID:       record.UUID,
+		Type:       record.Type,
+		Host:       record.Host,
+		Content:    record.Content,
+		TTL:        record.TTL,
+		AddPTR:     record.AddPTR,
+		CreatedAt:  uint64(record.CreatedAt.Unix()),
+		ModifiedAt: uint64(record.ModifiedAt.Unix()),
+		DeletedAt: func(t sql.NullTime) uint64 {
+			if t.Valid {
+				return uint64(t.Time.Unix())
+			}
+			return 0
+		}(record.DeletedAt),
+		ZoneUUID: record.ZoneUUID,
+		Staging:  record.Staging,
+		Tags:     record.Tags,
+	}
+}
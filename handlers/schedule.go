@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+type ScheduledApply struct {
+	ID              int64      `json:"id"`
+	Kind            string     `json:"kind"`
+	ChangeRequestID int64      `json:"change_request_id,omitempty"`
+	ApplyAt         time.Time  `json:"apply_at"`
+	Deploy          bool       `json:"deploy"`
+	Status          string     `json:"status"`
+	CreatedBy       string     `json:"created_by"`
+	CreatedByID     string     `json:"created_by_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	ChangesetID     int64      `json:"changeset_id,omitempty"`
+	Owner           string     `json:"owner,omitempty"`
+	HeartbeatAt     *time.Time `json:"heartbeat_at,omitempty"`
+}
+
+// scheduledApplyFromRDB converts a database scheduled apply into its API
+// representation.
+func scheduledApplyFromRDB(s rdb.ScheduledApply) ScheduledApply {
+	apply := ScheduledApply{
+		ID:              s.ID,
+		Kind:            s.Kind,
+		ChangeRequestID: s.ChangeRequestID,
+		ApplyAt:         s.ApplyAt,
+		Deploy:          s.Deploy,
+		Status:          s.Status,
+		CreatedBy:       s.CreatedBy,
+		CreatedByID:     s.CreatedByID,
+		CreatedAt:       s.CreatedAt,
+		Error:           s.Error,
+		ChangesetID:     s.ChangesetID,
+		Owner:           s.Owner,
+	}
+	if s.HeartbeatAt.Valid {
+		apply.HeartbeatAt = &s.HeartbeatAt.Time
+	}
+	if s.StartedAt.Valid {
+		apply.StartedAt = &s.StartedAt.Time
+	}
+	if s.FinishedAt.Valid {
+		apply.FinishedAt = &s.FinishedAt.Time
+	}
+	return apply
+}
+
+// GetScheduleHandler lists the scheduled applies in the order they are due,
+// optionally only those with the given status.
+func GetScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	applies, err := (&rdb.ScheduledApply{Status: r.URL.Query().Get("status")}).Get(r.Context())
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Unable to retrieve the schedule",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	S := []ScheduledApply{}
+	for _, apply := range applies {
+		S = append(S, scheduledApplyFromRDB(apply))
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Schedule retrieved successfully",
+		Data:    S,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// CancelScheduledApplyHandler cancels a scheduled apply that has not run yet.
+func CancelScheduledApplyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Invalid scheduled apply id",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	apply := rdb.ScheduledApply{ID: id}
+	if err := apply.Find(r.Context()); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Scheduled apply not found",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	before := scheduledApplyFromRDB(apply)
+
+	if err := apply.Cancel(r.Context()); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusConflict
+			err = fmt.Errorf("the apply is %s", apply.Status)
+		}
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Only pending applies can be cancelled",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	audit(r.Context(), "schedule.cancel", "", strconv.FormatInt(apply.ID, 10), before, scheduledApplyFromRDB(apply))
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Scheduled apply cancelled",
+		Data:    scheduledApplyFromRDB(apply),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// scheduleApply reads the optional body of an apply, {"apply_at": ...,
+// "deploy": ...}, and schedules apply for apply_at, a time in RFC 3339 or
+// seconds since the epoch, deploying afterwards when deploy is set. It
+// answers the request and returns true when it scheduled the apply or the
+// body is invalid, and returns false to apply right away when there is no
+// apply_at.
+func scheduleApply(w http.ResponseWriter, r *http.Request, apply rdb.ScheduledApply, code int) bool {
+	var requestData struct {
+		ApplyAt string `json:"apply_at"`
+		Deploy  bool   `json:"deploy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false
+		}
+		errorMsg := responseBody{
+			Code:    code,
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return true
+	}
+	if requestData.ApplyAt == "" {
+		return false
+	}
+
+	applyAt, err := parseTime(requestData.ApplyAt)
+	if err == nil && !applyAt.After(time.Now()) {
+		err = errors.New("apply_at is not in the future")
+	}
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    code,
+			Message: "Invalid apply_at",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return true
+	}
+
+	principal, _ := policy.FromContext(r.Context())
+	apply.ApplyAt = applyAt
+	apply.Deploy = requestData.Deploy
+	apply.CreatedBy = principal.Name
+	apply.CreatedByID = principal.ID
+	if err := apply.Create(r.Context()); err != nil {
+		errorMsg := responseBody{
+			Code:    code,
+			Message: "Unable to schedule the apply",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return true
+	}
+	audit(r.Context(), "schedule.create", "", strconv.FormatInt(apply.ID, 10), nil, scheduledApplyFromRDB(apply))
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Apply scheduled",
+		Data:    scheduledApplyFromRDB(apply),
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(responseBody)
+	return true
+}
+
+// RunScheduler applies the scheduled applies as they become due, checking
+// every interval until ctx is done.
+//
+// Applies are leased to Instance while they run. Those this server left
+// running when it stopped are marked failed on start, and those of other
+// servers once their lease expires, as they may have been interrupted
+// anywhere in the pipeline.
+func RunScheduler(ctx context.Context, interval time.Duration) {
+	failStaleScheduledApplies(ctx, Instance)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			apply := rdb.ScheduledApply{Owner: Instance}
+			if err := apply.ClaimDue(ctx, time.Now()); err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					log.Printf("Unable to claim due scheduled applies: %v", err)
+				}
+				break
+			}
+			stop := keepLease(ctx, fmt.Sprintf("scheduled apply %d", apply.ID), apply.Renew)
+			runScheduledApply(schedulerContext(ctx, apply), apply)
+			stop()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		failStaleScheduledApplies(ctx, "")
+	}
+}
+
+// failStaleScheduledApplies marks failed the running applies whose lease
+// expired, and those of owner when it is set.
+func failStaleScheduledApplies(ctx context.Context, owner string) {
+	reason := "interrupted, the API server running it stopped"
+	failed, err := (&rdb.ScheduledApply{Owner: owner, Error: reason}).FailStale(ctx, leaseDuration)
+	if err != nil {
+		log.Printf("Unable to fail interrupted scheduled applies: %v", err)
+	}
+	for _, apply := range failed {
+		log.Printf("Scheduled apply %d of %s failed: %s", apply.ID, apply.Owner, reason)
+		before := apply
+		before.Status = rdb.ScheduleRunning
+		before.FinishedAt = sql.NullTime{}
+		before.Error = ""
+		audit(schedulerContext(ctx, apply), "schedule.run", "", strconv.FormatInt(apply.ID, 10), scheduledApplyFromRDB(before), scheduledApplyFromRDB(apply))
+	}
+}
+
+// schedulerContext runs apply as the principal that scheduled it, with the
+// operator role required to schedule it.
+func schedulerContext(ctx context.Context, apply rdb.ScheduledApply) context.Context {
+	return policy.WithPrincipal(ctx, policy.Principal{
+		Name:   apply.CreatedBy,
+		ID:     apply.CreatedByID,
+		Grants: []policy.Grant{{Role: policy.Operator}},
+	})
+}
+
+// runScheduledApply applies the staged changes or the change request of
+// apply the same way as the API, and deploys the result when
+// asked to. It waits for the pipeline lock rather than failing when an
+// apply or deploy is in progress.
+func runScheduledApply(ctx context.Context, apply rdb.ScheduledApply) {
+	log.Printf("Running scheduled apply %d of %s", apply.ID, apply.Kind)
+
+	// hold the pipeline lock from the apply through the deploy
+	ctx, unlock, err := waitPipeline(ctx, "schedule.run", func(lock rdb.PipelineLock) {
+		log.Printf("Scheduled apply %d waits for %s of %s since %s", apply.ID, lock.Action, lock.Holder, lock.Since.Format(time.RFC3339))
+	})
+	if err != nil {
+		finishScheduledApply(ctx, apply, err)
+		return
+	}
+	defer unlock()
+
+	switch apply.Kind {
+	case rdb.ScheduleKindChangeRequest:
+		request := rdb.ChangeRequest{ID: apply.ChangeRequestID}
+		if err = request.Find(ctx); err == nil {
+			err = applyChangeRequest(ctx, &request)
+			apply.ChangesetID = request.ChangesetID
+		}
+	default:
+		var zones []Zone
+		var records []Record
+		zones, records, err = getAllStaging(ctx)
+		if err == nil && len(zones) == 0 && len(records) == 0 {
+			err = errors.New("nothing is staged")
+		}
+		if err == nil {
+			var changeset rdb.Changeset
+			changeset, err = applyStaging(ctx)
+			apply.ChangesetID = changeset.ID
+		}
+	}
+	if err != nil {
+		finishScheduledApply(ctx, apply, err)
+		return
+	}
+
+	// deploy as a job of its own, so that it is listed and logged like others
+	if apply.Deploy {
+		principal, _ := policy.FromContext(ctx)
+		job := rdb.DeployJob{
+			Status:      rdb.DeployRunning,
+			Owner:       Instance,
+			CreatedBy:   principal.Name,
+			CreatedByID: principal.ID,
+			StartedAt:   sql.NullTime{Time: time.Now(), Valid: true},
+		}
+		err := job.Create(ctx)
+		if err == nil {
+			err = runDeployJob(ctx, job)
+		}
+		if err != nil {
+			finishScheduledApply(ctx, apply, fmt.Errorf("applied as changeset %d, but the deploy failed: %w", apply.ChangesetID, err))
+			return
+		}
+	}
+
+	finishScheduledApply(ctx, apply, nil)
+}
+
+// finishScheduledApply records that apply succeeded, or failed with err.
+func finishScheduledApply(ctx context.Context, apply rdb.ScheduledApply, err error) {
+	before := scheduledApplyFromRDB(apply)
+	apply.Status = rdb.ScheduleSucceeded
+	apply.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	if err != nil {
+		apply.Status = rdb.ScheduleFailed
+		apply.Error = err.Error()
+		log.Printf("Scheduled apply %d failed: %v", apply.ID, err)
+	} else {
+		log.Printf("Scheduled apply %d succeeded as changeset %d", apply.ID, apply.ChangesetID)
+	}
+
+	if err := apply.Update(ctx); err != nil {
+		log.Printf("Unable to record the outcome of scheduled apply %d: %v", apply.ID, err)
+	}
+	audit(ctx, "schedule.run", "", strconv.FormatInt(apply.ID, 10), before, scheduledApplyFromRDB(apply))
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/DrC0ns0le/bind-api/ansible"
+)
+
+// GetServersHandler lists the servers the configuration is deployed to.
+func GetServersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	servers, err := ansible.Servers(r.Context())
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Unable to retrieve servers",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if servers == nil {
+		servers = []ansible.Server{}
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Servers retrieved successfully",
+		Data:    servers,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// GetInventoryHandler returns the inventory generated from the servers, as
+// it is written before the next deploy.
+func GetInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	servers, err := ansible.Servers(r.Context())
+	if err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Unable to retrieve servers",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if len(servers) == 0 {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "No server configured, deploys use the mounted inventory",
+			Data:    nil,
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Inventory generated successfully",
+		Data:    ansible.Inventory(servers),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// CreateServerHandler adds a server to deploy to.
+func CreateServerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var server ansible.Server
+	if err := json.NewDecoder(r.Body).Decode(&server); err != nil {
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if err := server.Validate(); err != nil {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Invalid server",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	if _, ok, err := findServer(r, server.Host); err != nil || ok {
+		status := http.StatusConflict
+		var data interface{} = server.Host
+		if err != nil {
+			status, data = http.StatusInternalServerError, err.Error()
+		}
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Server already exists",
+			Data:    data,
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
+	if err := ansible.SetServer(r.Context(), "", server); err != nil {
+		errorMsg := responseBody{
+			Code:    4,
+			Message: "Unable to create server",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	audit(r.Context(), "server.create", "", server.Host, nil, server)
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Server created successfully",
+		Data:    server,
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// UpdateServerHandler changes the fields of a server given in the request
+// body. A host in the body renames the server, vars replace all variables.
+func UpdateServerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	host := r.PathValue("host")
+	server, ok, err := findServer(r, host)
+	if err != nil || !ok {
+		status := http.StatusNotFound
+		var data interface{} = host
+		if err != nil {
+			status, data = http.StatusInternalServerError, err.Error()
+		}
+		errorMsg := responseBody{
+			Code:    1,
+			Message: "Server not found",
+			Data:    data,
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	before := server
+
+	var requestData struct {
+		Host  string             `json:"host"`
+		User  *string            `json:"user"`
+		Port  *int               `json:"port"`
+		Group *string            `json:"group"`
+		Vars  *map[string]string `json:"vars"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if requestData.Host != "" {
+		server.Host = requestData.Host
+	}
+	if requestData.User != nil {
+		server.User = *requestData.User
+	}
+	if requestData.Port != nil {
+		server.Port = *requestData.Port
+	}
+	if requestData.Group != nil {
+		server.Group = *requestData.Group
+	}
+	if requestData.Vars != nil {
+		server.Vars = *requestData.Vars
+	}
+
+	if err := server.Validate(); err != nil {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Invalid server",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	if !strings.EqualFold(server.Host, before.Host) {
+		if _, taken, err := findServer(r, server.Host); err != nil || taken {
+			status := http.StatusConflict
+			var data interface{} = server.Host
+			if err != nil {
+				status, data = http.StatusInternalServerError, err.Error()
+			}
+			errorMsg := responseBody{
+				Code:    4,
+				Message: "Server already exists",
+				Data:    data,
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(errorMsg)
+			return
+		}
+	}
+
+	if err := ansible.SetServer(r.Context(), before.Host, server); err != nil {
+		errorMsg := responseBody{
+			Code:    5,
+			Message: "Unable to update server",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	audit(r.Context(), "server.update", "", before.Host, before, server)
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Server updated successfully",
+		Data:    server,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// DeleteServerHandler stops deploying to a server.
+func DeleteServerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	host := r.PathValue("host")
+	server, ok, err := findServer(r, host)
+	if err == nil && ok {
+		err = ansible.RemoveServer(r.Context(), server.Host)
+	} else if err == nil {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Unable to delete server"
+		if errors.Is(err, sql.ErrNoRows) {
+			status, message = http.StatusNotFound, "Server not found"
+		}
+		errorMsg := responseBody{
+			Code:    1,
+			Message: message,
+			Data:    host,
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+	audit(r.Context(), "server.delete", "", server.Host, server, nil)
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Server deleted successfully",
+		Data:    server,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// findServer looks up the configured server with host.
+func findServer(r *http.Request, host string) (ansible.Server, bool, error) {
+	servers, err := ansible.Servers(r.Context())
+	if err != nil {
+		return ansible.Server{}, false, err
+	}
+	for _, server := range servers {
+		if strings.EqualFold(server.Host, host) {
+			return server, true, nil
+		}
+	}
+	return ansible.Server{}, false, nil
+}
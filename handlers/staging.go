@@ -4,11 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/DrC0ns0le/bind-api/commit"
+	"github.com/DrC0ns0le/bind-api/lint"
+	"github.com/DrC0ns0le/bind-api/policy"
 	"github.com/DrC0ns0le/bind-api/rdb"
 	"github.com/DrC0ns0le/bind-api/render"
+	"github.com/DrC0ns0le/bind-api/verify"
 )
 
 // GetStagingHandler retrieves all zones and records in staging and returns them in a JSON response.
@@ -49,58 +54,288 @@ func GetStagingHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// ApplyStagingHandler applies the staged changes, or schedules them to be
+// applied at the apply_at of the request body, see scheduleApply. A
+// scheduled apply takes whatever is staged when it runs.
 func ApplyStagingHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	if scheduleApply(w, r, rdb.ScheduledApply{Kind: rdb.ScheduleKindStaging}, 6) {
+		return
+	}
+
+	unlock, ok := lockPipeline(w, r, "staging.apply", 7)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	changeset, err := applyStaging(r.Context())
+	if err != nil {
+		writeApplyError(w, err)
+		return
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Changes successfully committed",
+		Data:    changesetFromRDB(changeset),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// applyError is why an apply failed, with the status, code and data the
+// apply endpoints answer it with.
+type applyError struct {
+	status  int
+	code    int
+	message string
+	data    interface{}
+}
+
+func (e *applyError) Error() string {
+	switch data := e.data.(type) {
+	case nil:
+		return e.message
+	case string:
+		return e.message + ": " + data
+	}
+	data, _ := json.Marshal(e.data)
+	return e.message + ": " + string(data)
+}
+
+// writeApplyError answers the request with err, an *applyError or any other
+// error of an apply.
+func writeApplyError(w http.ResponseWriter, err error) {
+	var e *applyError
+	if !errors.As(err, &e) {
+		e = &applyError{status: http.StatusInternalServerError, code: 1, message: "Unable to apply changes", data: err.Error()}
+	}
+	errorMsg := responseBody{
+		Code:    e.code,
+		Message: e.message,
+		Data:    e.data,
+	}
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(errorMsg)
+}
+
+// applyStaging lints, renders and verifies all zones, pushes the result and
+// commits the staged changes as a new changeset. Only the staged changes of
+// the zones in the staging scope of ctx are applied when it has one, see
+// rdb.WithStagingScope. The pipeline lock must be held. Failures are
+// returned as an *applyError.
+func applyStaging(ctx context.Context) (rdb.Changeset, error) {
+	// Lint all zones, errors block the apply
+	reports, err := lint.All(ctx)
+	if err != nil {
+		return rdb.Changeset{}, &applyError{status: http.StatusInternalServerError, code: 2, message: "Unable to lint zones", data: err.Error()}
+	}
+	var failed []lint.Report
+	for _, report := range reports {
+		if report.HasErrors() {
+			failed = append(failed, report)
+		}
+	}
+	if len(failed) > 0 {
+		return rdb.Changeset{}, &applyError{status: http.StatusUnprocessableEntity, code: 3, message: "Zones failed linting", data: failed}
+	}
+
 	// Render all zones
-	if err := render.RenderZonesTemplate(r.Context()); err != nil {
-		errorMsg := responseBody{
-			Code:    1,
-			Message: "Zone rendering failed",
-			Data:    err.Error(),
+	rendered, err := render.RenderZonesTemplate(ctx)
+	if err != nil {
+		// discard what was rendered before the failure
+		if err := commit.Reset(); err != nil {
+			log.Printf("Unable to reset output after failed render: %v", err)
 		}
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(errorMsg)
-		return
+		return rdb.Changeset{}, &applyError{status: http.StatusNotFound, code: 1, message: "Zone rendering failed", data: err.Error()}
+	}
+
+	// Check the rendered files the way BIND will load them
+	check, err := verify.Dir(ctx, "output")
+	if err != nil {
+		return rdb.Changeset{}, &applyError{status: http.StatusInternalServerError, code: 4, message: "Unable to verify rendered zones", data: err.Error()}
+	}
+	if !check.OK {
+		// discard the rendered files so they are not pushed later on
+		if err := commit.Reset(); err != nil {
+			log.Printf("Unable to reset output after failed verification: %v", err)
+		}
+		return rdb.Changeset{}, &applyError{status: http.StatusUnprocessableEntity, code: 5, message: "Rendered zones failed verification", data: check.Failed()}
+	}
+
+	// Store the new reverse zones and serials now that they are verified
+	if err := rendered.Save(ctx); err != nil {
+		if err := commit.Reset(); err != nil {
+			log.Printf("Unable to reset output after failed render: %v", err)
+		}
+		return rdb.Changeset{}, &applyError{status: http.StatusInternalServerError, code: 1, message: "Unable to save rendered zones", data: err.Error()}
+	}
+
+	// Keep what is being applied for the changeset and the audit log
+	stagedZones, stagedRecords, err := getAllStaging(ctx)
+	if err != nil {
+		if err := commit.Reset(); err != nil {
+			log.Printf("Unable to reset output after failed apply: %v", err)
+		}
+		return rdb.Changeset{}, &applyError{status: http.StatusInternalServerError, code: 1, message: "Unable to retrieve changes in staging", data: err.Error()}
 	}
 
 	// Commit changes
-	if err := commit.Push(); err != nil {
+	hash, err := commit.Push()
+	if err != nil {
+		return rdb.Changeset{}, &applyError{status: http.StatusNotFound, code: 1, message: "Unable to commit changes", data: err.Error()}
+	}
+
+	// Commit all changes, or only those of the zones in the staging scope
+	// and the reverse zones created for them
+	if zoneUUIDs, scoped := rdb.StagingScope(ctx); scoped {
+		for _, zoneUUID := range append(zoneUUIDs, rendered.Created()...) {
+			if err = (&rdb.Zone{UUID: zoneUUID}).Commit(ctx); err != nil {
+				break
+			}
+		}
+	} else {
+		err = (&rdb.Record{}).CommitAll(ctx)
+	}
+	if err != nil {
+		return rdb.Changeset{}, &applyError{status: http.StatusInternalServerError, code: 1, message: "Unable to commit staged changes", data: err.Error()}
+	}
+
+	// set config_status to awaiting_deployment
+	if err := (&rdb.Config{ConfigKey: "config_status", ConfigValue: "deployed"}).Update(ctx, "awaiting_deployment"); err != nil {
+		return rdb.Changeset{}, &applyError{status: http.StatusNotFound, code: 1, message: "Unable to update deploy_status", data: err.Error()}
+	}
+
+	// Record the apply as a changeset that can be rolled back to
+	applied := map[string]interface{}{
+		"zones":   stagedZones,
+		"records": stagedRecords,
+	}
+	principal, _ := policy.FromContext(ctx)
+	changeset := rdb.Changeset{CommitHash: hash, Actor: principal.Name, ActorID: principal.ID}
+	if changeset.Changes, err = json.Marshal(applied); err == nil {
+		err = changeset.Create(ctx)
+	}
+	if err != nil {
+		// the changes are pushed and committed all the same, keep them in
+		// the audit log before failing the apply
+		audit(ctx, "staging.apply", "", "", nil, applied)
+		return rdb.Changeset{}, &applyError{status: http.StatusInternalServerError, code: 1, message: "Unable to record changeset", data: map[string]string{
+			"error":       err.Error(),
+			"commit_hash": hash,
+		}}
+	}
+	applied["changeset"] = changeset.ID
+
+	audit(ctx, "staging.apply", "", "", nil, applied)
+
+	return changeset, nil
+}
+
+// DiscardStagingHandler throws away every staged change, reverting zones and
+// records to their last committed version.
+func DiscardStagingHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	discardStaging(w, r, "", "")
+}
+
+// DiscardZoneStagingHandler throws away the staged changes of a zone and its
+// records.
+func DiscardZoneStagingHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	zoneUUID := r.PathValue("zone_uuid")
+	if !canEdit(w, r, rdb.Zone{UUID: zoneUUID}, 3) {
+		return
+	}
+	discardStaging(w, r, zoneUUID, "")
+}
+
+// DiscardRecordStagingHandler throws away the staged changes of one record.
+func DiscardRecordStagingHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	zoneUUID := r.PathValue("zone_uuid")
+	record := rdb.Record{UUID: r.PathValue("record_uuid")}
+
+	if err := record.Find(r.Context()); err != nil || record.ZoneUUID != zoneUUID {
 		errorMsg := responseBody{
-			Code:    1,
-			Message: "Unable to commit changes",
-			Data:    err.Error(),
+			Code:    4,
+			Message: "Record not found",
+			Data:    nil,
 		}
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(errorMsg)
 		return
 	}
-
-	// Commit all changes
-	err := (&rdb.Record{}).CommitAll(r.Context())
-	if err != nil {
+	if !canEdit(w, r, rdb.Zone{UUID: zoneUUID}, 3) {
 		return
 	}
+	discardStaging(w, r, zoneUUID, record.UUID)
+}
 
-	// set config_status to awaiting_deployment
-	if err := (&rdb.Config{ConfigKey: "config_status", ConfigValue: "deployed"}).Update(r.Context(), "awaiting_deployment"); err != nil {
+// discardStaging discards the staged records of zoneUUID, or only recordUUID,
+// followed by the zone itself. Empty arguments discard everything.
+func discardStaging(w http.ResponseWriter, r *http.Request, zoneUUID, recordUUID string) {
+	// records go first, a zone created in staging still holds its new records
+	records, err := (&rdb.Record{ZoneUUID: zoneUUID, UUID: recordUUID}).Discard(r.Context())
+	if err != nil {
 		errorMsg := responseBody{
 			Code:    1,
-			Message: "Unable to update deploy_status",
+			Message: "Unable to discard staged records",
 			Data:    err.Error(),
 		}
-		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(errorMsg)
 		return
 	}
 
-	responseBody := responseBody{
+	var zones []rdb.Zone
+	if recordUUID == "" {
+		zones, err = (&rdb.Zone{UUID: zoneUUID}).Discard(r.Context())
+		if err != nil {
+			errorMsg := responseBody{
+				Code:    2,
+				Message: "Unable to discard staged zones",
+				Data:    err.Error(),
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(errorMsg)
+			return
+		}
+	}
+
+	Z := []Zone{}
+	for _, zone := range zones {
+		Z = append(Z, zoneFromRDB(zone))
+	}
+	R := []Record{}
+	for _, record := range records {
+		R = append(R, recordFromRDB(record))
+	}
+
+	objectUUID := recordUUID
+	if objectUUID == "" {
+		objectUUID = zoneUUID
+	}
+	audit(r.Context(), "staging.discard", zoneUUID, objectUUID, map[string]interface{}{
+		"zones":   Z,
+		"records": R,
+	}, nil)
+
+	response := responseBody{
 		Code:    0,
-		Message: "Changes successfully committed",
-		Data:    nil,
+		Message: "Staged changes discarded",
+		Data: struct {
+			Zones   []Zone   `json:"zones"`
+			Records []Record `json:"records"`
+		}{
+			Zones:   Z,
+			Records: R,
+		},
 	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(responseBody)
+	json.NewEncoder(w).Encode(response)
 }
 
 func getAllStaging(ctx context.Context) ([]Zone, []Record, error) {
@@ -128,7 +363,8 @@ func getAllStaging(ctx context.Context) ([]Zone, []Record, error) {
 				Minimum:    zone.Minimum,
 				TTL:        zone.TTL,
 			},
-			Tags: zone.Tags,
+			NameServers: zone.NameServers,
+			Tags:        zone.Tags,
 		}
 		Z = append(Z, temp)
 	}
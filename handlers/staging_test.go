@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// useOutput clones a new repository holding files into the output directory
+// of a temporary working directory, where commit and render expect it, and
+// returns the output directory.
+func useOutput(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	origin := filepath.Join(dir, "origin")
+	repo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(origin, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Commit("initial", &git.CommitOptions{Author: &object.Signature{Name: "test", When: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git.PlainClone(filepath.Join(dir, "output"), false, &git.CloneOptions{URL: origin}); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return filepath.Join(dir, "output")
+}
+
+func TestApplyStagingRenderFailure(t *testing.T) {
+	newAPI(t)
+	ctx := context.Background()
+	output := useOutput(t, map[string]string{"named.conf.zones": "// committed\n"})
+
+	// the zone list renders, the zone file cannot be created
+	zone := rdb.Zone{
+		UUID:        rdb.ZoneUUID("missing/example.org"),
+		Name:        "missing/example.org",
+		PrimaryNS:   "ns1.example.net",
+		AdminEmail:  "hostmaster.example.net",
+		Refresh:     7200,
+		Retry:       3600,
+		Expire:      1209600,
+		Minimum:     300,
+		TTL:         3600,
+		NameServers: rdb.NameServers{{Host: "ns1.example.net."}},
+		Staging:     true,
+	}
+	if err := zone.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := applyStaging(ctx)
+	var e *applyError
+	if !errors.As(err, &e) || e.message != "Zone rendering failed" {
+		t.Fatalf("applyStaging() error = %v, want the render failure", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(output, "named.conf.zones"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "// committed\n" {
+		t.Errorf("named.conf.zones = %q, want the committed version back", b)
+	}
+	if staged, _ := (&rdb.Zone{}).GetStaging(ctx); len(staged) != 1 {
+		t.Errorf("staged zones = %+v, want the zone still staged", staged)
+	}
+}
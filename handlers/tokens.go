@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/google/uuid"
+)
+
+// tokenPrefix makes API tokens easy to recognise, e.g. by secret scanners.
+const tokenPrefix = "bapi_"
+
+type Token struct {
+	UUID       string         `json:"uuid"`
+	Name       string         `json:"name"`
+	Role       string         `json:"role"`
+	Scope      rdb.TokenScope `json:"scope"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time     `json:"revoked_at,omitempty"`
+
+	// Secret is only returned when the token is created
+	Secret string `json:"token,omitempty"`
+}
+
+func tokenFromRDB(t rdb.Token) Token {
+	nullTime := func(t sql.NullTime) *time.Time {
+		if !t.Valid {
+			return nil
+		}
+		return &t.Time
+	}
+	return Token{
+		UUID:       t.UUID,
+		Name:       t.Name,
+		Role:       t.Role,
+		Scope:      t.Scope,
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  nullTime(t.ExpiresAt),
+		LastUsedAt: nullTime(t.LastUsedAt),
+		RevokedAt:  nullTime(t.RevokedAt),
+	}
+}
+
+func GetTokensHandler(w http.ResponseWriter, r *http.Request) {
+	tokens, err := new(rdb.Token).Get(r.Context())
+	if err != nil {
+		responseBody := responseBody{
+			Code:    1,
+			Message: "Unable to retrieve tokens",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+
+	tokensList := []Token{}
+	for _, token := range tokens {
+		tokensList = append(tokensList, tokenFromRDB(token))
+	}
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Tokens retrieved successfully",
+		Data:    tokensList,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// CreateTokenHandler creates an API token. The secret is part of the
+// response and cannot be retrieved again.
+//
+// The token gets one role, see package policy, and editor tokens can be
+// limited to zones and zone tags through scope.
+//
+// The token expires at expires_at, or expires_in seconds after creation,
+// and never when neither is given.
+func CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name      string         `json:"name"`
+		Role      string         `json:"role"`
+		Scope     rdb.TokenScope `json:"scope"`
+		ExpiresAt *time.Time     `json:"expires_at"`
+		ExpiresIn int64          `json:"expires_in"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		responseBody := responseBody{
+			Code:    1,
+			Message: "Unable to decode request body",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+
+	token := rdb.Token{
+		UUID:  uuid.New().String(),
+		Name:  strings.TrimSpace(body.Name),
+		Scope: body.Scope,
+	}
+	switch {
+	case body.ExpiresAt != nil:
+		token.ExpiresAt = sql.NullTime{Time: *body.ExpiresAt, Valid: true}
+	case body.ExpiresIn > 0:
+		token.ExpiresAt = sql.NullTime{Time: time.Now().Add(time.Duration(body.ExpiresIn) * time.Second), Valid: true}
+	}
+
+	role, err := policy.ParseRole(body.Role)
+	token.Role = string(role)
+
+	var problem string
+	switch {
+	case token.Name == "":
+		problem = "name is required"
+	case err != nil:
+		problem = err.Error()
+	case role != policy.Editor && !policy.Scope(body.Scope).Empty():
+		problem = "only editor tokens can be limited to zones or tags"
+	case body.ExpiresAt != nil && body.ExpiresIn != 0:
+		problem = "expires_at and expires_in are mutually exclusive"
+	case body.ExpiresIn < 0:
+		problem = "expires_in must be positive"
+	case token.ExpiresAt.Valid && !token.ExpiresAt.Time.After(time.Now()):
+		problem = "expires_at must be in the future"
+	}
+	if problem != "" {
+		responseBody := responseBody{
+			Code:    2,
+			Message: "Invalid token",
+			Data:    problem,
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		responseBody := responseBody{
+			Code:    3,
+			Message: "Unable to generate token",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+	secret := tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	token.Hash = rdb.HashToken(secret)
+
+	if err := token.Create(r.Context()); err != nil {
+		responseBody := responseBody{
+			Code:    3,
+			Message: "Unable to create token",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+
+	audit(r.Context(), "token.create", "", token.UUID, nil, tokenFromRDB(token))
+
+	data := tokenFromRDB(token)
+	data.Secret = secret
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Token created successfully, store it now as it cannot be retrieved again",
+		Data:    data,
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(responseBody)
+}
+
+// RevokeTokenHandler revokes an API token. Revoked tokens stay listed.
+func RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	token := rdb.Token{UUID: r.PathValue("token_uuid")}
+	err := token.Revoke(r.Context())
+	if errors.Is(err, sql.ErrNoRows) {
+		responseBody := responseBody{
+			Code:    1,
+			Message: "Token of UUID " + token.UUID + " not found or already revoked",
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+	if err != nil {
+		responseBody := responseBody{
+			Code:    2,
+			Message: "Unable to revoke token",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(responseBody)
+		return
+	}
+
+	before := tokenFromRDB(token)
+	before.RevokedAt = nil
+	audit(r.Context(), "token.revoke", "", token.UUID, before, tokenFromRDB(token))
+
+	responseBody := responseBody{
+		Code:    0,
+		Message: "Token revoked successfully",
+		Data:    tokenFromRDB(token),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responseBody)
+}
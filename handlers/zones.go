@@ -8,19 +8,22 @@ import (
 	"time"
 
 	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/DrC0ns0le/bind-api/reverse"
+	"github.com/DrC0ns0le/bind-api/validation"
 	"github.com/google/uuid"
 )
 
 type Zone struct {
-	ID         uint32       `json:"-"`
-	UUID       string       `json:"uuid"`
-	Name       string       `json:"name"`
-	CreatedAt  time.Time    `json:"created_at"`
-	ModifiedAt time.Time    `json:"modified_at"`
-	DeletedAt  sql.NullTime `json:"deleted_at"`
-	Staging    bool         `json:"staging"`
-	SOA        SOA          `json:"soa,omitempty"`
-	Tags       []string     `json:"tags"`
+	ID          uint32          `json:"-"`
+	UUID        string          `json:"uuid"`
+	Name        string          `json:"name"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ModifiedAt  time.Time       `json:"modified_at"`
+	DeletedAt   sql.NullTime    `json:"deleted_at"`
+	Staging     bool            `json:"staging"`
+	SOA         SOA             `json:"soa,omitempty"`
+	NameServers rdb.NameServers `json:"name_servers"`
+	Tags        []string        `json:"tags"`
 }
 
 type SOA struct {
@@ -111,6 +114,7 @@ func GetZoneHandler(w http.ResponseWriter, r *http.Request) {
 				Minimum:    zone.Minimum,
 				TTL:        zone.TTL,
 			},
+			NameServers: zone.NameServers,
 		},
 	}
 
@@ -128,8 +132,9 @@ func GetZoneHandler(w http.ResponseWriter, r *http.Request) {
 func CreateZoneHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
 	var requestData struct {
-		Name string `json:"name"`
-		SOA  SOA    `json:"soa"`
+		Name        string          `json:"name"`
+		SOA         SOA             `json:"soa"`
+		NameServers rdb.NameServers `json:"name_servers"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
@@ -158,40 +163,50 @@ func CreateZoneHandler(w http.ResponseWriter, r *http.Request) {
 	// Generate UUID for the zone using UUID version 5
 	uuid5 := uuid.NewSHA1(dnsNamespaceUUID, []byte(requestData.Name)).String()
 
+	// Editors may only create zones within their scope
+	if !canEdit(w, r, rdb.Zone{UUID: uuid5, Name: requestData.Name}, 6) {
+		return
+	}
+
+	if errorMsg := checkNewZone(requestData.Name, requestData.NameServers); errorMsg != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
+
 	// Check if the zone already exists
-	if err := (&rdb.Zone{UUID: uuid5}).Find(r.Context()); err != nil {
-		if err == sql.ErrNoRows {
-			errorMsg := responseBody{
-				Code:    2,
-				Message: "Zone already exists",
-				Data:    nil,
-			}
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(errorMsg)
-			return
-		} else {
-			errorMsg := responseBody{
-				Code:    3,
-				Message: "Error checking if zone exists",
-				Data:    err.Error(),
-			}
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(errorMsg)
-			return
+	if err := (&rdb.Zone{UUID: uuid5}).Find(r.Context()); err == nil {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Zone already exists",
+			Data:    nil,
 		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	} else if err != sql.ErrNoRows {
+		errorMsg := responseBody{
+			Code:    3,
+			Message: "Error checking if zone exists",
+			Data:    err.Error(),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
 	}
 
 	newZone := rdb.Zone{
-		UUID:       uuid5,
-		Name:       requestData.Name,
-		PrimaryNS:  requestData.SOA.PrimaryNS,
-		AdminEmail: requestData.SOA.AdminEmail,
-		Refresh:    requestData.SOA.Refresh,
-		Retry:      requestData.SOA.Retry,
-		Expire:     requestData.SOA.Expire,
-		Minimum:    requestData.SOA.Minimum,
-		TTL:        requestData.SOA.TTL,
-		Staging:    true,
+		UUID:        uuid5,
+		Name:        requestData.Name,
+		PrimaryNS:   requestData.SOA.PrimaryNS,
+		AdminEmail:  requestData.SOA.AdminEmail,
+		Refresh:     requestData.SOA.Refresh,
+		Retry:       requestData.SOA.Retry,
+		Expire:      requestData.SOA.Expire,
+		Minimum:     requestData.SOA.Minimum,
+		TTL:         requestData.SOA.TTL,
+		NameServers: requestData.NameServers,
+		Staging:     true,
 	}
 
 	// Create the zone
@@ -200,6 +215,7 @@ func CreateZoneHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	audit(r.Context(), "zone.create", newZone.UUID, newZone.UUID, nil, zoneFromRDB(newZone))
 
 	// Respond with the created zone
 	w.Header().Set("Content-Type", "application/json")
@@ -225,10 +241,16 @@ func UpdateZoneHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canEdit(w, r, zone, 3) {
+		return
+	}
+	before := zoneFromRDB(zone)
+
 	// Parse request body
 	var requestData struct {
-		Name string `json:"name"`
-		SOA  SOA    `json:"soa"`
+		Name        string           `json:"name"`
+		SOA         SOA              `json:"soa"`
+		NameServers *rdb.NameServers `json:"name_servers"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
 		errorMsg := responseBody{
@@ -266,6 +288,26 @@ func UpdateZoneHandler(w http.ResponseWriter, r *http.Request) {
 	if requestData.SOA.TTL != 0 {
 		zone.TTL = requestData.SOA.TTL
 	}
+	if requestData.NameServers != nil {
+		zone.NameServers = *requestData.NameServers
+	}
+
+	// Renaming must not move the zone out of the editor's scope
+	if requestData.Name != "" && !canEdit(w, r, zone, 3) {
+		return
+	}
+
+	// Validate the name servers, renaming the zone may move them out of it
+	if errs := validation.NameServers(zone.Name, zone.NameServers); errs != nil {
+		errorMsg := responseBody{
+			Code:    2,
+			Message: "Invalid name servers",
+			Data:    errs,
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMsg)
+		return
+	}
 
 	// Update the zone
 	if err := zone.Update(r.Context()); err != nil {
@@ -278,6 +320,7 @@ func UpdateZoneHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(errorMsg)
 		return
 	}
+	audit(r.Context(), "zone.update", zone.UUID, zone.UUID, before, zoneFromRDB(zone))
 
 	// Respond with success message
 	responseBody := responseBody{
@@ -307,6 +350,10 @@ func DeleteZoneHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canEdit(w, r, zone, 2) {
+		return
+	}
+
 	// Execute the delete query
 	if err := zone.Delete(r.Context()); err != nil {
 		errorMsg := responseBody{
@@ -318,6 +365,7 @@ func DeleteZoneHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(errorMsg)
 		return
 	}
+	audit(r.Context(), "zone.delete", zone.UUID, zone.UUID, zoneFromRDB(zone), nil)
 
 	// Respond with success message
 	responseBody := responseBody{
@@ -335,3 +383,50 @@ func DeleteZoneHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(responseBody)
 }
+
+// checkNewZone checks the name and name servers of a zone about to be
+// created, returning the error to answer with when they are invalid.
+func checkNewZone(name string, nameServers rdb.NameServers) *responseBody {
+	// Reverse zones must be named after the network they serve
+	if reverse.IsReverse(name) {
+		if _, ok := reverse.FromName(name); !ok {
+			return &responseBody{
+				Code:    4,
+				Message: "Reverse zone name does not match a network",
+				Data:    name,
+			}
+		}
+	}
+
+	if errs := validation.NameServers(name, nameServers); errs != nil {
+		return &responseBody{
+			Code:    5,
+			Message: "Invalid name servers",
+			Data:    errs,
+		}
+	}
+	return nil
+}
+
+// zoneFromRDB converts a database zone into its API representation.
+func zoneFromRDB(zone rdb.Zone) Zone {
+	return Zone{
+		UUID:       zone.UUID,
+		Name:       zone.Name,
+		CreatedAt:  zone.CreatedAt,
+		ModifiedAt: zone.ModifiedAt,
+		DeletedAt:  zone.DeletedAt,
+		Staging:    zone.Staging,
+		SOA: SOA{
+			PrimaryNS:  zone.PrimaryNS,
+			AdminEmail: zone.AdminEmail,
+			Refresh:    zone.Refresh,
+			Retry:      zone.Retry,
+			Expire:     zone.Expire,
+			Minimum:    zone.Minimum,
+			TTL:        zone.TTL,
+		},
+		NameServers: zone.NameServers,
+		Tags:        zone.Tags,
+	}
+}
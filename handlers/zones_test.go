@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+var (
+	operator = policy.Principal{Name: "ops", ID: "ops-token", Grants: []policy.Grant{{Role: policy.Operator}}}
+	editor   = policy.Principal{Name: "lab-editor", ID: "lab-token", Grants: []policy.Grant{{Role: policy.Editor, Scope: policy.Scope{Zones: []string{"*.lab.example.com"}}}}}
+)
+
+// api routes requests to the zone, export, import, record, staging and
+// deploy handlers, as the caller p, with a memory store as the backend.
+type api struct {
+	t   *testing.T
+	mux *http.ServeMux
+}
+
+func newAPI(t *testing.T) *api {
+	t.Helper()
+	s, err := rdb.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdb.Use(s)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/zones", GetZonesHandler)
+	mux.HandleFunc("GET /api/v1/zones/{zone_uuid}", GetZoneHandler)
+	mux.HandleFunc("POST /api/v1/zones", CreateZoneHandler)
+	mux.HandleFunc("GET /api/v1/zones/{zone_uuid}/export", ExportZoneHandler)
+	mux.HandleFunc("POST /api/v1/zones/import", ImportZoneHandler)
+	mux.HandleFunc("POST /api/v1/zones/{zone_uuid}/import", ImportZoneHandler)
+	mux.HandleFunc("DELETE /api/v1/zones/{zone_uuid}", DeleteZoneHandler)
+	mux.HandleFunc("GET /api/v1/zones/{zone_uuid}/records", GetZoneRecordsHandler)
+	mux.HandleFunc("POST /api/v1/zones/{zone_uuid}/records", CreateRecordHandler)
+	mux.HandleFunc("GET /api/v1/staging", GetStagingHandler)
+	mux.HandleFunc("POST /api/v1/deploy", DeployHandler)
+	mux.HandleFunc("DELETE /api/v1/zones/{zone_uuid}/staging", DiscardZoneStagingHandler)
+	return &api{t: t, mux: mux}
+}
+
+// do sends a request with body encoded as JSON, decoding the response into
+// v unless it is nil, and returns the status.
+func (a *api) do(p policy.Principal, method, path string, body, v interface{}) int {
+	a.t.Helper()
+
+	var b bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&b).Encode(body)
+	}
+	r := httptest.NewRequest(method, path, &b)
+	r = r.WithContext(policy.WithPrincipal(r.Context(), p))
+	w := httptest.NewRecorder()
+	a.mux.ServeHTTP(w, r)
+
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			a.t.Fatalf("%s %s answered %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// response is a responseBody with its data left to decode.
+type response struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func newZoneBody(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name": name,
+		"soa": SOA{
+			PrimaryNS:  "ns1." + name + ".",
+			AdminEmail: "hostmaster." + name + ".",
+			Refresh:    7200,
+			Retry:      3600,
+			Expire:     1209600,
+			Minimum:    300,
+			TTL:        3600,
+		},
+		"name_servers": rdb.NameServers{{Host: "ns1", Addresses: []string{"192.0.2.1"}}},
+	}
+}
+
+func TestCreateZoneHandler(t *testing.T) {
+	a := newAPI(t)
+
+	tests := []struct {
+		name   string
+		caller policy.Principal
+		body   interface{}
+		status int
+		code   int // code of the error, unchecked when created
+	}{
+		{name: "created", caller: editor, body: newZoneBody("dev.lab.example.com"), status: http.StatusCreated},
+		{name: "duplicate", caller: operator, body: newZoneBody("dev.lab.example.com"), status: http.StatusConflict, code: 2},
+		{name: "outside the editor's scope", caller: editor, body: newZoneBody("example.net"), status: http.StatusForbidden, code: 6},
+		{name: "by an operator", caller: operator, body: newZoneBody("example.net"), status: http.StatusCreated},
+		{name: "no name", caller: operator, body: newZoneBody(""), status: http.StatusBadRequest, code: 2},
+		{name: "no name servers", caller: operator, body: map[string]string{"name": "example.org"}, status: http.StatusBadRequest, code: 5},
+		{name: "reverse zone of no network", caller: operator, body: newZoneBody("300.2.0.192.in-addr.arpa"), status: http.StatusBadRequest, code: 4},
+		{name: "not JSON", caller: operator, body: "example.org", status: http.StatusBadRequest, code: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got response
+			status := a.do(tt.caller, "POST", "/api/v1/zones", tt.body, &got)
+			if status != tt.status {
+				t.Fatalf("POST /api/v1/zones = %d %+v, want %d", status, got, tt.status)
+			}
+			if status != http.StatusCreated && got.Code != tt.code {
+				t.Errorf("POST /api/v1/zones code = %d %q, want %d", got.Code, got.Message, tt.code)
+			}
+		})
+	}
+
+	var zones response
+	if status := a.do(operator, "GET", "/api/v1/zones", nil, &zones); status != http.StatusOK {
+		t.Fatalf("GET /api/v1/zones = %d", status)
+	}
+	var listed []Zone
+	json.Unmarshal(zones.Data, &listed)
+	if len(listed) != 2 || listed[0].Name != "dev.lab.example.com" || listed[1].Name != "example.net" || !listed[0].Staging {
+		t.Errorf("GET /api/v1/zones = %+v, want the 2 zones created, staged", listed)
+	}
+
+	entries, err := rdb.AuditFilter{Action: "zone.create"}.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Actor != "lab-editor" || entries[1].ActorID != "lab-token" || entries[1].ZoneUUID != rdb.ZoneUUID("dev.lab.example.com") {
+		t.Errorf("audit = %+v, want the creations by lab-editor then ops", entries)
+	}
+}
+
+func TestZoneStagingHandlers(t *testing.T) {
+	a := newAPI(t)
+	lab := rdb.ZoneUUID("dev.lab.example.com")
+	net := rdb.ZoneUUID("example.net")
+	for _, name := range []string{"dev.lab.example.com", "example.net"} {
+		if status := a.do(operator, "POST", "/api/v1/zones", newZoneBody(name), nil); status != http.StatusCreated {
+			t.Fatalf("POST /api/v1/zones of %s = %d", name, status)
+		}
+	}
+
+	var created response
+	record := map[string]interface{}{"type": "A", "host": "www", "content": "192.0.2.10"}
+	if status := a.do(editor, "POST", "/api/v1/zones/"+lab+"/records", record, &created); status != http.StatusCreated {
+		t.Fatalf("POST records = %d %+v", status, created)
+	}
+	var got response
+	if status := a.do(editor, "POST", "/api/v1/zones/"+net+"/records", record, &got); status != http.StatusForbidden || got.Code != 5 {
+		t.Errorf("POST records outside the editor's scope = %d %+v, want 403 code 5", status, got)
+	}
+	invalid := map[string]interface{}{"type": "A", "host": "www", "content": "not an address"}
+	if status := a.do(editor, "POST", "/api/v1/zones/"+lab+"/records", invalid, &got); status != http.StatusBadRequest || got.Code != 4 {
+		t.Errorf("POST of an invalid record = %d %+v, want 400 code 4", status, got)
+	}
+
+	var records response
+	if status := a.do(editor, "GET", "/api/v1/zones/"+lab+"/records", nil, &records); status != http.StatusOK {
+		t.Fatalf("GET records = %d", status)
+	}
+	var listed []Record
+	json.Unmarshal(records.Data, &listed)
+	if len(listed) != 1 || listed[0].Content != "192.0.2.10" || listed[0].TTL != 3600 || !listed[0].Staging {
+		t.Errorf("GET records = %+v, want the staged www record with the default TTL", listed)
+	}
+
+	// editors can only discard the staged changes of their zones, which
+	// takes the zone created in staging along with its records
+	if status := a.do(editor, "DELETE", "/api/v1/zones/"+net+"/staging", nil, &got); status != http.StatusForbidden || got.Code != 3 {
+		t.Errorf("DELETE staging outside the editor's scope = %d %+v, want 403 code 3", status, got)
+	}
+	var discarded struct {
+		Data struct {
+			Zones   []Zone   `json:"zones"`
+			Records []Record `json:"records"`
+		} `json:"data"`
+	}
+	if status := a.do(editor, "DELETE", "/api/v1/zones/"+lab+"/staging", nil, &discarded); status != http.StatusOK {
+		t.Fatalf("DELETE staging = %d", status)
+	}
+	if len(discarded.Data.Zones) != 1 || len(discarded.Data.Records) != 1 {
+		t.Errorf("DELETE staging = %+v, want the zone and its record", discarded.Data)
+	}
+
+	var staging struct {
+		Data struct {
+			Zones   []Zone   `json:"zones"`
+			Records []Record `json:"records"`
+		} `json:"data"`
+	}
+	if status := a.do(operator, "GET", "/api/v1/staging", nil, &staging); status != http.StatusOK {
+		t.Fatalf("GET staging = %d", status)
+	}
+	if len(staging.Data.Zones) != 1 || staging.Data.Zones[0].UUID != net || len(staging.Data.Records) != 0 {
+		t.Errorf("GET staging = %+v, want example.net only", staging.Data)
+	}
+	if status := a.do(operator, "GET", "/api/v1/zones/"+lab, nil, &got); status != http.StatusNotFound || got.Code != 1 {
+		t.Errorf("GET the discarded zone = %d %+v, want 404 code 1", status, got)
+	}
+
+	// a committed zone is only marked deleted until the deletion is applied
+	if err := (&rdb.Zone{UUID: net}).Commit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if status := a.do(operator, "DELETE", "/api/v1/zones/"+net, nil, &got); status != http.StatusOK {
+		t.Fatalf("DELETE zone = %d %+v", status, got)
+	}
+	var zone response
+	if status := a.do(operator, "GET", "/api/v1/zones/"+net, nil, &zone); status != http.StatusOK {
+		t.Fatalf("GET the deleted zone = %d", status)
+	}
+	var deleted Zone
+	json.Unmarshal(zone.Data, &deleted)
+	if !deleted.Staging || !deleted.DeletedAt.Valid {
+		t.Errorf("GET the deleted zone = %+v, want it staged for deletion", deleted)
+	}
+}
@@ -0,0 +1,446 @@
+// Package lint checks zones as a whole, catching mistakes that are valid
+// record by record but break the zone once rendered: CNAMEs sharing a name
+// with other records, duplicate records, CNAMEs pointing nowhere and
+// addresses claimed by several PTRs.
+package lint
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/DrC0ns0le/bind-api/reverse"
+	"github.com/DrC0ns0le/bind-api/validation"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // Blocks applying staged changes
+	SeverityWarning Severity = "warning" // Reported only
+)
+
+// Issue is a single finding of the linter.
+type Issue struct {
+	Severity   Severity `json:"severity"`
+	Check      string   `json:"check"`
+	Host       string   `json:"host"`
+	Type       string   `json:"type,omitempty"`
+	RecordUUID string   `json:"record_uuid,omitempty"`
+	Message    string   `json:"message"`
+}
+
+// Report holds the findings for one zone.
+type Report struct {
+	ZoneUUID string  `json:"zone_uuid"`
+	Zone     string  `json:"zone"`
+	Errors   int     `json:"errors"`
+	Warnings int     `json:"warnings"`
+	Issues   []Issue `json:"issues"`
+}
+
+// HasErrors reports whether the zone must not be applied.
+func (rep Report) HasErrors() bool {
+	return rep.Errors > 0
+}
+
+func (rep *Report) add(severity Severity, check string, r rdb.Record, format string, args ...interface{}) {
+	rep.Issues = append(rep.Issues, Issue{
+		Severity:   severity,
+		Check:      check,
+		Host:       r.Host,
+		Type:       r.Type,
+		RecordUUID: r.UUID,
+		Message:    fmt.Sprintf(format, args...),
+	})
+	if severity == SeverityError {
+		rep.Errors++
+	} else {
+		rep.Warnings++
+	}
+}
+
+// Zone lints the staged state of a single zone.
+func Zone(ctx context.Context, zone rdb.Zone) (Report, error) {
+	l, err := load(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	return l.zone(zone), nil
+}
+
+// All lints the staged state of every zone that is not pending deletion.
+func All(ctx context.Context) ([]Report, error) {
+	l, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]Report, 0, len(l.zones))
+	for _, z := range l.zones {
+		reports = append(reports, l.zone(z))
+	}
+	return reports, nil
+}
+
+// linter holds every managed name, so that CNAME targets can be resolved
+// across zones.
+type linter struct {
+	zones   []rdb.Zone
+	records map[string][]rdb.Record    // zone UUID -> live records
+	names   map[string]map[string]bool // owner name -> record types
+	reverse []reverse.Zone
+	claims  map[netip.Addr][]string // address -> names asking for its PTR
+}
+
+func load(ctx context.Context) (*linter, error) {
+	zones, err := (&rdb.Zone{}).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve zones: %w", err)
+	}
+	records, err := (&rdb.Record{}).GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve records: %w", err)
+	}
+
+	reverseZones, err := reverse.Zones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve reverse zones: %w", err)
+	}
+
+	l := &linter{
+		records: make(map[string][]rdb.Record),
+		names:   make(map[string]map[string]bool),
+		reverse: reverseZones,
+		claims:  make(map[netip.Addr][]string),
+	}
+	live := make(map[string]rdb.Zone)
+	for _, z := range zones {
+		if z.DeletedAt.Valid {
+			continue
+		}
+		live[z.UUID] = z
+		l.zones = append(l.zones, z)
+		for _, g := range generated(z) {
+			l.addName(ownerName(g.Host, z.Name), g.Type)
+		}
+	}
+	sort.Slice(l.zones, func(i, j int) bool { return l.zones[i].Name < l.zones[j].Name })
+
+	for _, r := range records {
+		z, ok := live[r.ZoneUUID]
+		if !ok || r.DeletedAt.Valid {
+			continue
+		}
+		l.records[r.ZoneUUID] = append(l.records[r.ZoneUUID], r)
+		l.addName(ownerName(r.Host, z.Name), strings.ToUpper(r.Type))
+
+		if addr, ok := ptrAddr(r); ok {
+			l.claims[addr] = append(l.claims[addr], ownerName(r.Host, z.Name))
+		}
+	}
+
+	return l, nil
+}
+
+func (l *linter) addName(name, recordType string) {
+	if l.names[name] == nil {
+		l.names[name] = make(map[string]bool)
+	}
+	l.names[name][recordType] = true
+}
+
+func (l *linter) zone(z rdb.Zone) Report {
+	rep := Report{
+		ZoneUUID: z.UUID,
+		Zone:     z.Name,
+		Issues:   []Issue{},
+	}
+	records := l.records[z.UUID]
+
+	l.checkNameServers(&rep, z)
+	checkCNAMEConflicts(&rep, z, records)
+	checkDuplicates(&rep, z, records)
+	l.checkDanglingCNAMEs(&rep, z, records)
+	l.checkTargets(&rep, z, records)
+	l.checkPTRs(&rep, z, records)
+
+	return rep
+}
+
+// generated returns the records rendered for every zone besides its own:
+// the SOA, the NS set and the glue of name servers within the zone.
+func generated(z rdb.Zone) []rdb.Record {
+	records := []rdb.Record{{Host: "@", Type: "SOA"}}
+	for _, ns := range z.NameServers {
+		records = append(records, rdb.Record{Host: "@", Type: "NS", Content: ns.Host})
+	}
+	for _, ns := range z.NameServers {
+		if !validation.IsInZone(ns.Host, z.Name) {
+			continue
+		}
+		for _, a := range ns.Addresses {
+			recordType := "A"
+			if addr, err := netip.ParseAddr(a); err == nil && addr.Is6() && !addr.Is4In6() {
+				recordType = "AAAA"
+			}
+			records = append(records, rdb.Record{Host: ns.Host, Type: recordType, Content: a})
+		}
+	}
+	return records
+}
+
+// checkNameServers reports name servers within the zone that have neither
+// glue nor address records, which BIND refuses to load.
+func (l *linter) checkNameServers(rep *Report, z rdb.Zone) {
+	if len(z.NameServers) == 0 {
+		rep.add(SeverityError, "ns_missing", rdb.Record{Host: "@", Type: "NS"}, "zone %s has no name servers", z.Name)
+		return
+	}
+	for _, ns := range z.NameServers {
+		if !validation.IsInZone(ns.Host, z.Name) {
+			continue
+		}
+		name := ownerName(ns.Host, z.Name)
+		if !l.names[name]["A"] && !l.names[name]["AAAA"] {
+			rep.add(SeverityError, "ns_no_address", rdb.Record{Host: ns.Host, Type: "NS"}, "name server %s has no glue or address records", name)
+		}
+	}
+}
+
+// checkCNAMEConflicts reports CNAMEs that share their owner name with any
+// other record, including the ones generated by the zone template (RFC 1034 3.6.2).
+func checkCNAMEConflicts(rep *Report, z rdb.Zone, records []rdb.Record) {
+	types := make(map[string][]string)
+	cnames := make(map[string][]rdb.Record)
+	for _, r := range append(generated(z), records...) {
+		name := ownerName(r.Host, z.Name)
+		recordType := strings.ToUpper(r.Type)
+		if recordType == "CNAME" {
+			cnames[name] = append(cnames[name], r)
+		}
+		types[name] = append(types[name], recordType)
+	}
+
+	names := make([]string, 0, len(cnames))
+	for name := range cnames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rs := cnames[name]
+		var others []string
+		for _, t := range types[name] {
+			if t != "CNAME" {
+				others = append(others, t)
+			}
+		}
+		for _, r := range rs {
+			if len(others) > 0 {
+				rep.add(SeverityError, "cname_conflict", r, "CNAME at %s coexists with %s records", name, strings.Join(unique(others), ", "))
+			}
+		}
+		if len(rs) > 1 {
+			rep.add(SeverityError, "cname_conflict", rs[1], "%s has %d CNAME records, only one is allowed", name, len(rs))
+		}
+	}
+}
+
+// checkDuplicates reports records identical to another in the zone, and
+// records whose TTL differs from the rest of their RRset.
+func checkDuplicates(rep *Report, z rdb.Zone, records []rdb.Record) {
+	seen := make(map[string]rdb.Record)
+	ttls := make(map[string]rdb.Record)
+	for _, r := range records {
+		name := ownerName(r.Host, z.Name)
+		recordType := strings.ToUpper(r.Type)
+
+		key := name + " " + recordType + " " + normalizeContent(recordType, r.Content)
+		if first, ok := seen[key]; ok {
+			rep.add(SeverityError, "duplicate", r, "%s %s %s duplicates record %s", name, recordType, r.Content, first.UUID)
+			continue
+		}
+		seen[key] = r
+
+		rrset := name + " " + recordType
+		if first, ok := ttls[rrset]; !ok {
+			ttls[rrset] = r
+		} else if first.TTL != r.TTL && recordType != "CNAME" {
+			rep.add(SeverityWarning, "ttl_mismatch", r, "TTL %d differs from %d used by the rest of the %s %s RRset", r.TTL, first.TTL, name, recordType)
+		}
+	}
+}
+
+// checkDanglingCNAMEs reports CNAMEs whose target falls inside a managed
+// zone but does not exist there. Targets outside managed zones are not checked.
+func (l *linter) checkDanglingCNAMEs(rep *Report, z rdb.Zone, records []rdb.Record) {
+	for _, r := range records {
+		if strings.ToUpper(r.Type) != "CNAME" {
+			continue
+		}
+		target := canonical(r.Content)
+		zone, ok := l.managedZone(target)
+		if !ok {
+			continue
+		}
+		if !l.exists(target, zone) {
+			rep.add(SeverityError, "dangling_cname", r, "CNAME target %s does not exist in managed zone %s", target, zone)
+		}
+	}
+}
+
+// checkTargets warns about MX, NS and SRV targets that are aliases or do not
+// exist in a managed zone, which RFC 2181 10.3 forbids.
+func (l *linter) checkTargets(rep *Report, z rdb.Zone, records []rdb.Record) {
+	for _, r := range records {
+		recordType := strings.ToUpper(r.Type)
+		fields := strings.Fields(r.Content)
+
+		var target string
+		switch {
+		case recordType == "MX" && len(fields) == 2:
+			target = fields[1]
+		case recordType == "SRV" && len(fields) == 4:
+			target = fields[3]
+		case recordType == "NS" && len(fields) == 1:
+			target = fields[0]
+		default:
+			continue
+		}
+		if target == "." {
+			continue
+		}
+		// names in content are relative to the zone unless they end with a dot
+		target = ownerName(target, z.Name)
+
+		zone, ok := l.managedZone(target)
+		if !ok {
+			continue
+		}
+		if l.names[target]["CNAME"] {
+			rep.add(SeverityWarning, "alias_target", r, "%s target %s is a CNAME", recordType, target)
+		} else if !l.exists(target, zone) {
+			rep.add(SeverityWarning, "dangling_target", r, "%s target %s does not exist in managed zone %s", recordType, target, zone)
+		}
+	}
+}
+
+// checkPTRs reports addresses whose PTR is requested by several names, and
+// PTRs that will not be generated because a record exists at their name.
+func (l *linter) checkPTRs(rep *Report, z rdb.Zone, records []rdb.Record) {
+	for _, r := range records {
+		addr, ok := ptrAddr(r)
+		if !ok {
+			continue
+		}
+		name := ownerName(r.Host, z.Name)
+
+		var others []string
+		for _, claim := range l.claims[addr] {
+			if claim != name {
+				others = append(others, claim)
+			}
+		}
+		if len(others) > 0 {
+			rep.add(SeverityError, "ptr_conflict", r, "PTR for %s is also requested by %s", addr, strings.Join(unique(others), ", "))
+		}
+
+		rz, ok := reverse.Match(l.reverse, addr)
+		if !ok {
+			rep.add(SeverityWarning, "ptr_no_zone", r, "no reverse zone is configured for %s, its PTR is not generated", addr)
+			continue
+		}
+		if owner := canonical(rz.PTRName(addr)); len(l.names[owner]) > 0 {
+			rep.add(SeverityWarning, "ptr_overridden", r, "PTR for %s is not generated, %s already holds records in %s", addr, owner, rz.Name)
+		}
+	}
+}
+
+// ptrAddr returns the address of a record a PTR is generated for.
+func ptrAddr(r rdb.Record) (netip.Addr, bool) {
+	if !r.AddPTR {
+		return netip.Addr{}, false
+	}
+	switch strings.ToUpper(r.Type) {
+	case "A", "AAAA":
+	default:
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(r.Content))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// managedZone returns the closest enclosing managed zone of name.
+func (l *linter) managedZone(name string) (string, bool) {
+	best := ""
+	for _, z := range l.zones {
+		zone := canonical(z.Name)
+		if (name == zone || strings.HasSuffix(name, "."+zone)) && len(zone) > len(best) {
+			best = zone
+		}
+	}
+	return best, best != ""
+}
+
+// exists reports whether name owns records, directly or through a wildcard
+// below zone.
+func (l *linter) exists(name, zone string) bool {
+	if len(l.names[name]) > 0 {
+		return true
+	}
+	for name != zone {
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return false
+		}
+		name = name[i+1:]
+		if len(l.names["*."+name]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ownerName returns the absolute, lower case form of a name relative to zone.
+func ownerName(host, zone string) string {
+	if host == "@" || host == "" {
+		return canonical(zone)
+	}
+	if strings.HasSuffix(host, ".") {
+		return canonical(host)
+	}
+	return canonical(host + "." + zone)
+}
+
+func canonical(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// normalizeContent makes content comparable: names are case insensitive and
+// fully qualified, everything else is compared field by field.
+func normalizeContent(recordType, content string) string {
+	switch recordType {
+	case "CNAME", "NS", "PTR", "MX", "SRV":
+		return canonical(strings.Join(strings.Fields(content), " "))
+	}
+	return strings.Join(strings.Fields(content), " ")
+}
+
+func unique(values []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -1,12 +1,27 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"math"
 	"net"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/DrC0ns0le/bind-api/ansible"
 	"github.com/DrC0ns0le/bind-api/commit"
+	"github.com/DrC0ns0le/bind-api/handlers"
+	"github.com/DrC0ns0le/bind-api/middleware"
+	"github.com/DrC0ns0le/bind-api/oidc"
+	"github.com/DrC0ns0le/bind-api/policy"
 	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/DrC0ns0le/bind-api/render"
+	"github.com/DrC0ns0le/bind-api/validation"
+	"github.com/DrC0ns0le/bind-api/verify"
+	"github.com/DrC0ns0le/bind-api/zonefile"
 
 	_ "github.com/DrC0ns0le/bind-api/commit"
 )
@@ -17,16 +32,126 @@ func main() {
 
 	// Connect to the database
 	dbConfig := rdb.DBConfig{
+		Driver:   *dbDriver,
 		Host:     *dbAddr,
 		Port:     *dbPort,
 		User:     *dbUser,
 		Password: *dbPass,
 		DBName:   *dbName,
+		Path:     *dbPath,
 	}
 	rdb.Init(dbConfig)
 
+	if flag.Arg(0) == "migrate" {
+		runMigrate(flag.Args()[1:])
+	}
+	if *dbMigrate {
+		migrateOnStartup()
+	}
+
 	commit.Init(*gitToken)
 
+	if *recordMinTTL < 0 || *recordMinTTL > math.MaxUint16 {
+		log.Fatalf("Invalid record.min_ttl %d, expected 0 to %d", *recordMinTTL, math.MaxUint16)
+	}
+	if *recordMaxTTL < 0 || *recordMaxTTL > math.MaxUint16 {
+		log.Fatalf("Invalid record.max_ttl %d, expected 0 to %d", *recordMaxTTL, math.MaxUint16)
+	}
+	if *recordMinTTL > *recordMaxTTL {
+		log.Fatalf("Invalid record.min_ttl %d, greater than record.max_ttl %d", *recordMinTTL, *recordMaxTTL)
+	}
+	validation.MinTTL = uint16(*recordMinTTL)
+	validation.MaxTTL = uint16(*recordMaxTTL)
+
+	if err := render.SetSerialScheme(*soaSerialScheme); err != nil {
+		log.Fatal(err)
+	}
+
+	verify.CheckConfCmd = *checkConfCmd
+	verify.CheckZoneCmd = *checkZoneCmd
+
+	middleware.AdminToken = *authAdminToken
+	if middleware.AdminToken == "" {
+		log.Println("No admin token configured, only existing API tokens are accepted")
+	}
+
+	if *oidcIssuer != "" {
+		verifier, err := oidc.New(oidc.Config{
+			Issuer:      *oidcIssuer,
+			Audience:    *oidcAudience,
+			JWKSURL:     *oidcJWKSURL,
+			JWKSFile:    *oidcJWKSFile,
+			Refresh:     time.Duration(*oidcJWKSRefresh) * time.Second,
+			GroupsClaim: *oidcGroupsClaim,
+		})
+		if err != nil {
+			log.Fatalf("Invalid OIDC configuration: %v", err)
+		}
+		middleware.OIDC = verifier
+
+		roles := []byte(*oidcGroupRoles)
+		if s := strings.TrimSpace(*oidcGroupRoles); s != "" && !strings.HasPrefix(s, "{") {
+			if roles, err = os.ReadFile(s); err != nil {
+				log.Fatalf("Unable to read OIDC group roles: %v", err)
+			}
+		}
+		if len(roles) > 0 {
+			if middleware.Groups, err = policy.ParseGroupGrants(roles); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if len(middleware.Groups) == 0 {
+			log.Println("No OIDC group roles configured, OIDC users are authenticated but may not do anything")
+		}
+	}
+
+	if s := strings.TrimSpace(*transferTSIGKeys); s != "" {
+		keys := []byte(s)
+		var err error
+		if !strings.HasPrefix(s, "{") {
+			if keys, err = os.ReadFile(s); err != nil {
+				log.Fatalf("Unable to read TSIG keys: %v", err)
+			}
+		}
+		if handlers.TSIGKeys, err = zonefile.ParseTSIGKeys(keys); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch *deployDriver {
+	case "ansible":
+	case "ssh":
+		driver, err := ansible.NewSSH(ansible.SSHConfig{
+			KeyFile:        *deploySSHKey,
+			KnownHostsFile: *deploySSHKnownHosts,
+			User:           *deploySSHUser,
+			Parallelism:    *deployParallelism,
+			Timeout:        time.Duration(*deploySSHTimeout) * time.Second,
+		})
+		if err != nil {
+			log.Fatalf("Invalid SSH deploy configuration: %v", err)
+		}
+		ansible.Driver = driver
+	default:
+		log.Fatalf("Unknown deploy driver %q, expected ansible or ssh", *deployDriver)
+	}
+
+	handlers.Instance = *instanceID
+	if handlers.Instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Fatalf("Unable to name this API server, set instance.id: %v", err)
+		}
+		handlers.Instance = hostname
+	}
+
+	if *scheduleInterval > 0 {
+		go handlers.RunScheduler(context.Background(), time.Duration(*scheduleInterval)*time.Second)
+	}
+	if *deployInterval > 0 {
+		go handlers.RunDeployWorker(context.Background(), time.Duration(*deployInterval)*time.Second)
+	}
+
 	mux := http.NewServeMux()
 
 	registerRoutes(mux)
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/oidc"
+	"github.com/DrC0ns0le/bind-api/policy"
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+// AdminToken is a static secret accepted as an admin token, used to create
+// the first API tokens. Empty disables it.
+var AdminToken string
+
+// OIDC verifies JWTs of web UI users, nil disables them.
+var OIDC *oidc.Verifier
+
+// Groups grants roles to OIDC users by their groups.
+var Groups policy.GroupGrants
+
+// touchInterval limits how often the last-used time of a token is written.
+const touchInterval = time.Minute
+
+type errorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// AuthMiddleware authenticates requests with a bearer token, either an API
+// token, a JWT of the OIDC provider or AdminToken, and rejects everything
+// else with 401.
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, secret, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		secret = strings.TrimSpace(secret)
+		if !strings.EqualFold(scheme, "Bearer") || secret == "" {
+			unauthorized(w, "Missing bearer token")
+			return
+		}
+
+		if AdminToken != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(AdminToken)) == 1 {
+			admin := policy.Principal{Name: "admin", Grants: []policy.Grant{{Role: policy.Admin}}}
+			next.ServeHTTP(w, r.WithContext(policy.WithPrincipal(r.Context(), admin)))
+			return
+		}
+
+		if OIDC != nil && oidc.IsJWT(secret) {
+			claims, err := OIDC.Verify(r.Context(), secret)
+			if err != nil {
+				log.Printf("Rejected JWT: %v", err)
+				unauthorized(w, "Invalid token")
+				return
+			}
+			user := Groups.Principal(claims.Name, claims.Subject, claims.Groups)
+			next.ServeHTTP(w, r.WithContext(policy.WithPrincipal(r.Context(), user)))
+			return
+		}
+
+		token := rdb.Token{Hash: rdb.HashToken(secret)}
+		err := token.FindByHash(r.Context())
+		if errors.Is(err, sql.ErrNoRows) {
+			unauthorized(w, "Invalid token")
+			return
+		}
+		if err != nil {
+			log.Printf("Unable to look up token: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(errorBody{Code: 1, Message: "Unable to authenticate request"})
+			return
+		}
+
+		now := time.Now()
+		if !token.Active(now) {
+			unauthorized(w, "Token expired or revoked")
+			return
+		}
+
+		if !token.LastUsedAt.Valid || now.Sub(token.LastUsedAt.Time) >= touchInterval {
+			if err := token.Touch(r.Context(), now); err != nil {
+				log.Printf("Unable to record use of token %s: %v", token.UUID, err)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(policy.WithPrincipal(r.Context(), policy.TokenPrincipal(token))))
+	})
+}
+
+// Require rejects requests whose caller lacks role with 403. It must run
+// after AuthMiddleware. Zone scopes of editors are checked by the handlers.
+func Require(role policy.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := policy.FromContext(r.Context())
+			if !ok || !principal.Has(role) {
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(errorBody{Code: 1, Message: "The " + string(role) + " role is required"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="bind-api"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(errorBody{Code: 1, Message: message})
+}
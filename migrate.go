@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+const migrateUsage = "usage: bind-api [flags] migrate up|down [steps]|status"
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := rdb.MigrateUp(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("database schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+		reverted, err := rdb.MigrateDown(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		status, err := rdb.GetMigrationStatus(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range status {
+			if m.Applied {
+				fmt.Printf("%04d_%s\tapplied %s\n", m.Version, m.Name, m.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d_%s\tpending\n", m.Version, m.Name)
+			}
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+
+	if err := rdb.Close(); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(0)
+}
+
+// migrateOnStartup brings the schema up to date before serving requests.
+func migrateOnStartup() {
+	applied, err := rdb.MigrateUp(context.Background())
+	if errors.Is(err, rdb.ErrMigrationsUnsupported) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Database migration failed: %v", err)
+	}
+	for _, m := range applied {
+		log.Printf("Applied database migration %04d_%s\n", m.Version, m.Name)
+	}
+}
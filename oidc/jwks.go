@@ -0,0 +1,286 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwk is a JSON Web Key as defined by RFC 7517, reduced to the members
+// needed for signature verification.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// key is a parsed verification key.
+type key struct {
+	id     string
+	alg    string // Algorithm the key is restricted to, empty for any matching its type
+	public crypto.PublicKey
+}
+
+// parseJWKS parses a JSON Web Key Set. Keys that are not signature keys or
+// of unsupported types are skipped.
+func parseJWKS(b []byte) ([]key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	var keys []key
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		public, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS key %q: %w", k.Kid, err)
+		}
+		if public == nil {
+			continue
+		}
+		keys = append(keys, key{id: k.Kid, alg: k.Alg, public: public})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS holds no usable signature keys")
+	}
+	return keys, nil
+}
+
+// publicKey returns the public key of k, or nil for unsupported key types.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("exponent out of range")
+		}
+		if n.BitLen() < 2048 {
+			return nil, fmt.Errorf("%d bit RSA keys are too weak", n.BitLen())
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// keySet holds the verification keys of the provider, either loaded once
+// from a file or fetched from the JWKS URL and refreshed periodically.
+type keySet struct {
+	static  bool
+	issuer  string // Discovers the JWKS URL when url is empty
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      []key
+	fetchedAt time.Time
+	lastTry   time.Time
+}
+
+// minRefetch limits how often an unknown key ID triggers a fetch, so tokens
+// signed by random keys cannot make bind-api hammer the provider.
+const minRefetch = time.Minute
+
+func loadKeySet(path string) (*keySet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseJWKS(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &keySet{static: true, keys: keys}, nil
+}
+
+// find returns the keys a token with key ID kid may be signed with.
+func (s *keySet) find(ctx context.Context, kid string) ([]key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.match(kid)
+	if s.static {
+		return keys, nil
+	}
+	if len(keys) > 0 && !s.fetchedAt.IsZero() && time.Since(s.fetchedAt) < s.refresh {
+		return keys, nil
+	}
+
+	// refetch when the keys are stale or kid is unknown, the provider may
+	// have rotated its keys
+	if !s.lastTry.IsZero() && time.Since(s.lastTry) < minRefetch {
+		return keys, nil
+	}
+	s.lastTry = time.Now()
+
+	fetched, err := s.fetch(ctx)
+	if err != nil {
+		if len(s.keys) == 0 {
+			return nil, err
+		}
+		// keep verifying with the keys we have rather than locking everyone out
+		log.Printf("Keeping cached JWKS: %v", err)
+		return keys, nil
+	}
+	s.keys = fetched
+	s.fetchedAt = time.Now()
+	return s.match(kid), nil
+}
+
+// match returns the keys with ID kid, or every key when kid is empty.
+// Callers must hold the lock.
+func (s *keySet) match(kid string) []key {
+	var keys []key
+	for _, k := range s.keys {
+		if kid == "" || k.id == kid {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (s *keySet) fetch(ctx context.Context) ([]key, error) {
+	if s.url == "" {
+		url, err := discover(ctx, s.client, s.issuer)
+		if err != nil {
+			return nil, err
+		}
+		s.url = url
+	}
+
+	b, err := getJSON(ctx, s.client, s.url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch JWKS: %w", err)
+	}
+	keys, err := parseJWKS(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.url, err)
+	}
+	return keys, nil
+}
+
+// discover returns the JWKS URL from the OpenID provider configuration of issuer.
+func discover(ctx context.Context, client *http.Client, issuer string) (string, error) {
+	b, err := getJSON(ctx, client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return "", fmt.Errorf("unable to discover OpenID provider: %w", err)
+	}
+
+	var config struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return "", fmt.Errorf("invalid OpenID provider configuration: %w", err)
+	}
+	if config.Issuer != issuer {
+		return "", fmt.Errorf("OpenID provider configuration is for issuer %q, expected %q", config.Issuer, issuer)
+	}
+	if config.JWKSURI == "" {
+		return "", errors.New("OpenID provider configuration has no jwks_uri")
+	}
+	return config.JWKSURI, nil
+}
+
+// getJSON fetches url and returns the body of a successful response.
+func getJSON(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
@@ -0,0 +1,155 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey := publicJWK("rsa", "RS256", testKeys.rsa)
+	ecKey := publicJWK("ec", "ES256", testKeys.ec)
+
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offCurve := ecKey
+	offCurve.Kid = "off-curve"
+	offCurve.Y = offCurve.X
+	encryption := rsaKey
+	encryption.Kid = "enc"
+	encryption.Use = "enc"
+	smallExponent := rsaKey
+	smallExponent.E = b64([]byte{1})
+
+	tests := []struct {
+		name string
+		keys []jwk
+		want []string // IDs of the keys parsed
+		err  string
+	}{
+		{name: "RSA and EC", keys: []jwk{rsaKey, ecKey}, want: []string{"rsa", "ec"}},
+		{name: "encryption key skipped", keys: []jwk{encryption, ecKey}, want: []string{"ec"}},
+		{name: "unknown type skipped", keys: []jwk{{Kty: "oct", Kid: "hmac"}, rsaKey}, want: []string{"rsa"}},
+		{name: "unknown curve skipped", keys: []jwk{{Kty: "EC", Kid: "k", Crv: "secp256k1"}, rsaKey}, want: []string{"rsa"}},
+		{name: "weak RSA key", keys: []jwk{publicJWK("weak", "", weak)}, err: "1024 bit RSA keys are too weak"},
+		{name: "small exponent", keys: []jwk{smallExponent}, err: "exponent out of range"},
+		{name: "point off the curve", keys: []jwk{offCurve}, err: "not on the curve"},
+		{name: "missing modulus", keys: []jwk{{Kty: "RSA", Kid: "k", E: "AQAB"}}, err: "modulus: empty value"},
+		{name: "bad Ed25519 key", keys: []jwk{{Kty: "OKP", Kid: "k", Crv: "Ed25519", X: "AAAA"}}, err: "invalid Ed25519 public key"},
+		{name: "no usable key", keys: []jwk{encryption}, err: "no usable signature keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := json.Marshal(map[string][]jwk{"keys": tt.keys})
+			keys, err := parseJWKS(b)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseJWKS() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseJWKS() error = %v", err)
+			}
+			var ids []string
+			for _, k := range keys {
+				ids = append(ids, k.id)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("parseJWKS() keys = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseJWKS([]byte("not json")); err == nil || !strings.Contains(err.Error(), "invalid JWKS") {
+		t.Errorf("parseJWKS() error = %v, want invalid JWKS", err)
+	}
+}
+
+// provider serves the discovery document and JWKS of an OpenID provider,
+// counting the JWKS fetches.
+type provider struct {
+	server  *httptest.Server
+	keys    atomic.Value // []jwk
+	fetches atomic.Int32
+}
+
+func newProvider(t *testing.T, keys ...jwk) *provider {
+	p := &provider{}
+	p.keys.Store(keys)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": p.server.URL, "jwks_uri": p.server.URL + "/keys"})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		p.fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string][]jwk{"keys": p.keys.Load().([]jwk)})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func TestKeySetFetch(t *testing.T) {
+	p := newProvider(t, publicJWK("rsa", "RS256", testKeys.rsa))
+	v, err := New(Config{Issuer: p.server.URL, Audience: testAudience})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	claims := map[string]interface{}{
+		"iss": p.server.URL,
+		"aud": testAudience,
+		"sub": "3f1c2a",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if _, err := v.Verify(ctx, sign(t, "RS256", "rsa", claims, testKeys.rsa)); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if _, err := v.Verify(ctx, sign(t, "RS256", "rsa", claims, testKeys.rsa)); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if n := p.fetches.Load(); n != 1 {
+		t.Errorf("fetched the JWKS %d times, want once while cached", n)
+	}
+
+	// the provider rotates to an EC key, which is only fetched once
+	// minRefetch has passed since the last fetch
+	p.keys.Store([]jwk{publicJWK("ec", "ES256", testKeys.ec)})
+	rotated := sign(t, "ES256", "ec", claims, testKeys.ec)
+	if _, err := v.Verify(ctx, rotated); err == nil || !strings.Contains(err.Error(), `unknown signing key "ec"`) {
+		t.Fatalf("Verify() error = %v, want unknown signing key within minRefetch", err)
+	}
+	if n := p.fetches.Load(); n != 1 {
+		t.Errorf("fetched the JWKS %d times, want no refetch within minRefetch", n)
+	}
+
+	v.keys.mu.Lock()
+	v.keys.lastTry = time.Now().Add(-minRefetch)
+	v.keys.mu.Unlock()
+	if _, err := v.Verify(ctx, rotated); err != nil {
+		t.Fatalf("Verify() error = %v after the refetch", err)
+	}
+	if n := p.fetches.Load(); n != 2 {
+		t.Errorf("fetched the JWKS %d times, want a refetch for the unknown key", n)
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	p := newProvider(t, publicJWK("rsa", "RS256", testKeys.rsa))
+	// the configuration is found, but names the issuer without the slash
+	_, err := discover(context.Background(), p.server.Client(), p.server.URL+"/")
+	if err == nil || !strings.Contains(err.Error(), "is for issuer") {
+		t.Fatalf("discover() error = %v, want an issuer mismatch", err)
+	}
+}
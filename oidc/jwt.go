@@ -0,0 +1,130 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// header is the JOSE header of a signed JWT.
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+	Cty string `json:"cty"`
+}
+
+// token is a JWT in compact serialisation, split but not yet verified.
+type token struct {
+	header    header
+	payload   []byte
+	signed    []byte // header and payload as signed
+	signature []byte
+}
+
+// parseToken splits a compact JWS. It does not verify anything.
+func parseToken(raw string) (*token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	h, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	t := &token{signed: []byte(parts[0] + "." + parts[1])}
+	if err := json.Unmarshal(h, &t.header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	if t.header.Cty != "" {
+		return nil, errors.New("nested tokens are not supported")
+	}
+
+	if t.payload, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+	if t.signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+	return t, nil
+}
+
+// algorithms maps the supported JWS algorithms to their hash.
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+	"EdDSA": 0,
+}
+
+// verify checks the signature of t with k. The algorithm named by the token
+// must fit the key, which rules out "none" and algorithm confusion.
+func (t *token) verify(k key) error {
+	alg := t.header.Alg
+	hash, ok := algorithms[alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	if k.alg != "" && k.alg != alg {
+		return fmt.Errorf("key %q is for %s, not %s", k.id, k.alg, alg)
+	}
+
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(t.signed)
+		digest = h.Sum(nil)
+	}
+
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(public, hash, digest, t.signature)
+		case "PS":
+			return rsa.VerifyPSS(public, hash, digest, t.signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case *ecdsa.PublicKey:
+		if alg[:2] != "ES" || public.Curve.Params().BitSize != ecBits[alg] {
+			break
+		}
+		size := (public.Curve.Params().BitSize + 7) / 8
+		if len(t.signature) != 2*size {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+		if !ecdsa.Verify(public, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			break
+		}
+		if !ed25519.Verify(public, t.signed, t.signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("key %q cannot verify %s signatures", k.id, alg)
+}
+
+// ecBits is the curve size each ECDSA algorithm is defined for.
+var ecBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
@@ -0,0 +1,238 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testAudience = "bind-ui"
+)
+
+// testKeys are generated once, RSA key generation is slow.
+var testKeys = struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}{}
+
+func TestMain(m *testing.M) {
+	var err error
+	if testKeys.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if testKeys.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// publicJWK returns the JWK of the public key of signer.
+func publicJWK(kid, alg string, signer crypto.Signer) jwk {
+	switch public := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return jwk{Kty: "RSA", Kid: kid, Alg: alg, Use: "sig", N: b64(public.N.Bytes()), E: b64([]byte{1, 0, 1})}
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		return jwk{Kty: "EC", Kid: kid, Alg: alg, Crv: public.Curve.Params().Name, X: b64(public.X.FillBytes(make([]byte, size))), Y: b64(public.Y.FillBytes(make([]byte, size)))}
+	}
+	panic("unsupported key")
+}
+
+// sign returns a compact JWS of claims with header alg and kid, signed by
+// signer, or unsigned when signer is nil.
+func sign(t *testing.T, alg, kid string, claims map[string]interface{}, signer crypto.Signer) string {
+	t.Helper()
+
+	h, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	p, _ := json.Marshal(claims)
+	signed := b64(h) + "." + b64(p)
+	if signer == nil {
+		return signed + "."
+	}
+
+	hash := algorithms[alg]
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+	d := hash.New()
+	d.Write([]byte(signed))
+	digest := d.Sum(nil)
+
+	var sig []byte
+	var err error
+	switch k := signer.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(alg, "PS") {
+			sig, err = rsa.SignPSS(rand.Reader, k, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, signErr := ecdsa.Sign(rand.Reader, k, digest)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig, err = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...), signErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(sig)
+}
+
+// newTestVerifier returns a Verifier of the test issuer and audience with
+// a static JWKS of keys.
+func newTestVerifier(t *testing.T, keys ...jwk) *Verifier {
+	t.Helper()
+
+	b, _ := json.Marshal(map[string][]jwk{"keys": keys})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v, err := New(Config{Issuer: testIssuer, Audience: testAudience, JWKSFile: path, GroupsClaim: "realm_access.roles"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	v := newTestVerifier(t,
+		publicJWK("rsa", "", testKeys.rsa),
+		publicJWK("rsa-rs256", "RS256", testKeys.rsa),
+		publicJWK("ec", "ES256", testKeys.ec),
+	)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		alg    string
+		kid    string
+		signer crypto.Signer
+		claims map[string]interface{} // merged into valid claims, nil values remove them
+		tamper bool                   // change the payload after signing
+		err    string                 // part of the error, empty when valid
+	}{
+		{name: "RS256", alg: "RS256", kid: "rsa", signer: testKeys.rsa},
+		{name: "PS256", alg: "PS256", kid: "rsa", signer: testKeys.rsa},
+		{name: "RS512", alg: "RS512", kid: "rsa", signer: testKeys.rsa},
+		{name: "ES256", alg: "ES256", kid: "ec", signer: testKeys.ec},
+		{name: "any key without kid", alg: "ES256", signer: testKeys.ec},
+		{name: "alg none", alg: "none", kid: "rsa", err: `unsupported algorithm "none"`},
+		{name: "HMAC with the public key", alg: "HS256", kid: "rsa", signer: testKeys.rsa, err: `unsupported algorithm "HS256"`},
+		{name: "alg of another key type", alg: "ES256", kid: "rsa", signer: testKeys.ec, err: "cannot verify ES256"},
+		{name: "alg the key is not for", alg: "PS256", kid: "rsa-rs256", signer: testKeys.rsa, err: "is for RS256"},
+		{name: "unknown kid", alg: "RS256", kid: "rotated", signer: testKeys.rsa, err: `unknown signing key "rotated"`},
+		{name: "signed by another key", alg: "RS256", kid: "rsa", signer: mustRSA(t), err: "verification error"},
+		{name: "tampered RSA", alg: "RS256", kid: "rsa", signer: testKeys.rsa, tamper: true, err: "verification error"},
+		{name: "tampered EC", alg: "ES256", kid: "ec", signer: testKeys.ec, tamper: true, err: "invalid signature"},
+		{name: "expired", alg: "RS256", kid: "rsa", signer: testKeys.rsa, claims: map[string]interface{}{"exp": now.Add(-2 * leeway).Unix()}, err: "token expired"},
+		{name: "expired within leeway", alg: "RS256", kid: "rsa", signer: testKeys.rsa, claims: map[string]interface{}{"exp": now.Add(-leeway / 2).Unix()}},
+		{name: "no expiry", alg: "RS256", kid: "rsa", signer: testKeys.rsa, claims: map[string]interface{}{"exp": nil}, err: "token has no expiry"},
+		{name: "not valid yet", alg: "RS256", kid: "rsa", signer: testKeys.rsa, claims: map[string]interface{}{"nbf": now.Add(2 * leeway).Unix()}, err: "token not valid yet"},
+		{name: "valid within leeway", alg: "RS256", kid: "rsa", signer: testKeys.rsa, claims: map[string]interface{}{"nbf": now.Add(leeway / 2).Unix()}},
+		{name: "wrong issuer", alg: "RS256", kid: "rsa", signer: testKeys.rsa, claims: map[string]interface{}{"iss": "https://evil.example.com"}, err: "token issued by"},
+		{name: "wrong audience", alg: "RS256", kid: "rsa", signer: testKeys.rsa, claims: map[string]interface{}{"aud": "other-client"}, err: "not intended for"},
+		{name: "audience in a list", alg: "RS256", kid: "rsa", signer: testKeys.rsa, claims: map[string]interface{}{"aud": []string{"other-client", testAudience}}},
+		{name: "no subject", alg: "RS256", kid: "rsa", signer: testKeys.rsa, claims: map[string]interface{}{"sub": nil}, err: "token has no subject"},
+		{name: "empty subject", alg: "RS256", kid: "rsa", signer: testKeys.rsa, claims: map[string]interface{}{"sub": ""}, err: "token has no subject"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]interface{}{
+				"iss":          testIssuer,
+				"aud":          testAudience,
+				"sub":          "3f1c2a",
+				"exp":          now.Add(time.Hour).Unix(),
+				"iat":          now.Unix(),
+				"email":        "jane@example.com",
+				"realm_access": map[string]interface{}{"roles": []string{"dns-admins", "dns-editors"}},
+			}
+			for name, value := range tt.claims {
+				if value == nil {
+					delete(claims, name)
+				} else {
+					claims[name] = value
+				}
+			}
+
+			raw := sign(t, tt.alg, tt.kid, claims, tt.signer)
+			if tt.tamper {
+				claims["sub"] = "someone-else"
+				p, _ := json.Marshal(claims)
+				parts := strings.Split(raw, ".")
+				raw = parts[0] + "." + b64(p) + "." + parts[2]
+			}
+
+			got, err := v.Verify(context.Background(), raw)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Verify() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if got.Subject != "3f1c2a" || got.Name != "jane@example.com" {
+				t.Errorf("Verify() = %+v, want subject 3f1c2a named jane@example.com", got)
+			}
+			if strings.Join(got.Groups, ",") != "dns-admins,dns-editors" {
+				t.Errorf("Verify() groups = %v, want dns-admins,dns-editors", got.Groups)
+			}
+		})
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	header := b64([]byte(`{"alg":"RS256"}`))
+	tests := []struct {
+		name string
+		raw  string
+		err  string
+	}{
+		{name: "valid", raw: header + "." + b64([]byte(`{}`)) + "." + b64([]byte("sig"))},
+		{name: "two parts", raw: header + "." + b64([]byte(`{}`)), err: "malformed token"},
+		{name: "header not base64", raw: "!!." + b64([]byte(`{}`)) + ".", err: "malformed token header"},
+		{name: "header not JSON", raw: b64([]byte("alg")) + "." + b64([]byte(`{}`)) + ".", err: "malformed token header"},
+		{name: "nested token", raw: b64([]byte(`{"alg":"RS256","cty":"JWT"}`)) + "." + b64([]byte(`{}`)) + ".", err: "nested tokens"},
+		{name: "payload not base64", raw: header + ".!!.", err: "malformed token payload"},
+		{name: "signature not base64", raw: header + "." + b64([]byte(`{}`)) + ".!!", err: "malformed token signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseToken(tt.raw)
+			if tt.err == "" && err != nil {
+				t.Fatalf("parseToken() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("parseToken() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func mustRSA(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
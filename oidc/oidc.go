@@ -0,0 +1,210 @@
+// Package oidc authenticates users of the web UI with JWTs issued by an
+// OpenID Connect provider.
+//
+// Tokens must be signed by a key of the provider, be issued by the
+// configured issuer for the configured audience and be within their
+// validity period. The provider's keys are fetched from its JWKS, found
+// through discovery unless a URL is given, and cached. A static JWKS file
+// can be used instead where bind-api cannot reach the provider.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// leeway allows for clock skew between bind-api and the provider.
+const leeway = time.Minute
+
+// Config describes the provider tokens are accepted from.
+type Config struct {
+	Issuer      string        // Expected iss claim
+	Audience    string        // Expected aud claim, usually the client ID of the web UI
+	JWKSURL     string        // Discovered from the issuer when empty
+	JWKSFile    string        // Static JWKS used instead of fetching one
+	Refresh     time.Duration // How long fetched keys are used before refetching
+	GroupsClaim string        // Claim holding the groups, dots separate nested claims
+}
+
+// Claims are the verified claims of a token.
+type Claims struct {
+	Subject string
+	Name    string // preferred_username, email or the subject, whichever is set
+	Groups  []string
+	Expiry  time.Time
+}
+
+// Verifier checks tokens issued by one provider.
+type Verifier struct {
+	config Config
+	keys   *keySet
+}
+
+// New creates a Verifier. A static JWKS file is loaded right away, keys of
+// the provider are fetched on first use.
+func New(config Config) (*Verifier, error) {
+	if config.Issuer == "" {
+		return nil, errors.New("an issuer is required")
+	}
+	if config.Audience == "" {
+		return nil, errors.New("an audience is required")
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if config.Refresh <= 0 {
+		config.Refresh = time.Hour
+	}
+
+	v := &Verifier{config: config}
+	if config.JWKSFile != "" {
+		keys, err := loadKeySet(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+		return v, nil
+	}
+
+	v.keys = &keySet{
+		issuer:  config.Issuer,
+		url:     config.JWKSURL,
+		refresh: config.Refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	return v, nil
+}
+
+// IsJWT reports whether raw looks like a compact JWS rather than an API token.
+func IsJWT(raw string) bool {
+	return strings.Count(raw, ".") == 2
+}
+
+// Verify checks the signature and claims of a token and returns its claims.
+func (v *Verifier) Verify(ctx context.Context, raw string) (Claims, error) {
+	t, err := parseToken(raw)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	keys, err := v.keys.find(ctx, t.header.Kid)
+	if err != nil {
+		return Claims{}, err
+	}
+	if len(keys) == 0 {
+		return Claims{}, fmt.Errorf("unknown signing key %q", t.header.Kid)
+	}
+	var verifyErr error
+	for _, k := range keys {
+		if verifyErr = t.verify(k); verifyErr == nil {
+			break
+		}
+	}
+	if verifyErr != nil {
+		return Claims{}, verifyErr
+	}
+
+	var claims map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(string(t.payload)))
+	dec.UseNumber()
+	if err := dec.Decode(&claims); err != nil {
+		return Claims{}, fmt.Errorf("malformed token claims: %w", err)
+	}
+	return v.check(claims, time.Now())
+}
+
+// check validates the registered claims and extracts the ones bind-api uses.
+func (v *Verifier) check(claims map[string]interface{}, now time.Time) (Claims, error) {
+	if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+		return Claims{}, fmt.Errorf("token issued by %q, expected %q", iss, v.config.Issuer)
+	}
+	if !hasAudience(claims["aud"], v.config.Audience) {
+		return Claims{}, fmt.Errorf("token is not intended for %q", v.config.Audience)
+	}
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return Claims{}, errors.New("token has no expiry")
+	}
+	if now.After(exp.Add(leeway)) {
+		return Claims{}, errors.New("token expired")
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(leeway).Before(nbf) {
+		return Claims{}, errors.New("token not valid yet")
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return Claims{}, errors.New("token has no subject")
+	}
+
+	c := Claims{Subject: sub, Name: sub, Expiry: exp}
+	for _, name := range []string{"email", "preferred_username"} {
+		if s, ok := claims[name].(string); ok && s != "" {
+			c.Name = s
+		}
+	}
+	c.Groups = stringsClaim(lookup(claims, v.config.GroupsClaim))
+	return c, nil
+}
+
+// hasAudience reports whether the aud claim, a string or an array of
+// strings, contains audience.
+func hasAudience(aud interface{}, audience string) bool {
+	for _, a := range stringsClaim(aud) {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// numericDate converts a NumericDate claim, seconds since the epoch.
+func numericDate(v interface{}) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)), true
+}
+
+// lookup returns the claim at path, dots separating nested objects as in
+// realm_access.roles.
+func lookup(claims map[string]interface{}, path string) interface{} {
+	var v interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	return v
+}
+
+// stringsClaim returns a claim holding a string or an array of strings.
+func stringsClaim(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+// Package policy decides what an authenticated caller may do.
+//
+// Callers hold one or more grants. Each grant has a role:
+//
+//   - viewer: read everything
+//   - editor: additionally stage changes to zones and their records within
+//     the scope of the grant
+//   - operator: additionally edit every zone, change configs, apply staged
+//     changes and deploy
+//   - admin: additionally manage API tokens
+//
+// An editor scope lists zone names, or UUIDs, and zone tags. A zone is in
+// scope when it matches any of them. A name starting with "*." matches the
+// zones below it, and an editor grant without any scope covers every zone.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+// Role is a set of permissions, each role includes those of the roles before it.
+type Role string
+
+const (
+	Viewer   Role = "viewer"
+	Editor   Role = "editor"
+	Operator Role = "operator"
+	Admin    Role = "admin"
+)
+
+// Roles lists every role, least privileged first.
+var Roles = []Role{Viewer, Editor, Operator, Admin}
+
+// level orders roles by privilege, unknown roles grant nothing.
+func (r Role) level() int {
+	for i, role := range Roles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Includes reports whether r has every permission of other.
+func (r Role) Includes(other Role) bool {
+	return r.level() > 0 && r.level() >= other.level()
+}
+
+// ParseRole returns the role called name.
+func ParseRole(name string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(name)))
+	if r.level() == 0 {
+		return "", fmt.Errorf("unknown role %q, expected one of %s, %s, %s or %s", name, Viewer, Editor, Operator, Admin)
+	}
+	return r, nil
+}
+
+// Scope limits the zones an editor grant applies to.
+type Scope struct {
+	Zones []string `json:"zones,omitempty"` // Zone names or UUIDs, "*.example.com" for the zones below example.com
+	Tags  []string `json:"tags,omitempty"`  // Zone tags
+}
+
+// Empty reports whether the scope places no limit.
+func (s Scope) Empty() bool {
+	return len(s.Zones) == 0 && len(s.Tags) == 0
+}
+
+// Contains reports whether zone is within the scope.
+func (s Scope) Contains(zone rdb.Zone) bool {
+	if s.Empty() {
+		return true
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(zone.Name, "."))
+	for _, z := range s.Zones {
+		z = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(z), "."))
+		if suffix, ok := strings.CutPrefix(z, "*."); ok {
+			if strings.HasSuffix(name, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if z == name || (zone.UUID != "" && z == strings.ToLower(zone.UUID)) {
+			return true
+		}
+	}
+
+	for _, tag := range s.Tags {
+		for _, t := range zone.Tags {
+			if strings.EqualFold(tag, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Grant gives a role, scoped for editors.
+type Grant struct {
+	Role  Role  `json:"role"`
+	Scope Scope `json:"scope"`
+}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name   string  // Token name or user
+	ID     string  // Token UUID or subject, empty for the admin token
+	Grants []Grant // Everything the caller may do
+}
+
+// Has reports whether any grant of p includes role. Scopes are not
+// considered, use CanEdit for zone changes.
+func (p Principal) Has(role Role) bool {
+	for _, g := range p.Grants {
+		if g.Role.Includes(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEdit reports whether p may stage changes to zone and its records.
+func (p Principal) CanEdit(zone rdb.Zone) bool {
+	for _, g := range p.Grants {
+		if g.Role.Includes(Operator) {
+			return true
+		}
+		if g.Role == Editor && g.Scope.Contains(zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupGrants maps groups of an identity provider to the grant their
+// members receive.
+type GroupGrants map[string]Grant
+
+// ParseGroupGrants reads group grants from JSON such as
+//
+//	{"dns-admins": {"role": "admin"}, "team-a": {"role": "editor", "scope": {"tags": ["team-a"]}}}
+func ParseGroupGrants(b []byte) (GroupGrants, error) {
+	var grants GroupGrants
+	if err := json.Unmarshal(b, &grants); err != nil {
+		return nil, fmt.Errorf("invalid group roles: %w", err)
+	}
+	for group, g := range grants {
+		role, err := ParseRole(string(g.Role))
+		if err != nil {
+			return nil, fmt.Errorf("group %q: %w", group, err)
+		}
+		if role != Editor && !g.Scope.Empty() {
+			return nil, fmt.Errorf("group %q: only editors can be limited to zones or tags", group)
+		}
+		g.Role = role
+		grants[group] = g
+	}
+	return grants, nil
+}
+
+// Principal returns the principal of a user in groups, with the grants of
+// every group that has one.
+func (g GroupGrants) Principal(name, id string, groups []string) Principal {
+	p := Principal{Name: name, ID: id}
+	for _, group := range groups {
+		if grant, ok := g[group]; ok {
+			p.Grants = append(p.Grants, grant)
+		}
+	}
+	return p
+}
+
+// TokenPrincipal returns the principal authenticated by an API token.
+func TokenPrincipal(t rdb.Token) Principal {
+	return Principal{
+		Name:   t.Name,
+		ID:     t.UUID,
+		Grants: []Grant{{Role: Role(t.Role), Scope: Scope(t.Scope)}},
+	}
+}
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// FromContext returns the principal a request was authenticated as.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey).(Principal)
+	return p, ok
+}
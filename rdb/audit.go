@@ -0,0 +1,58 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records one change made through the API. Entries are only ever
+// appended, never changed or removed.
+type AuditEntry struct {
+	ID         int64           // Sequence number
+	Time       time.Time       // Time of the change
+	Actor      string          // Name of the token or user that made the change
+	ActorID    string          // UUID of the token or OIDC subject, empty for the admin token
+	Action     string          // What happened, e.g. record.update
+	ObjectUUID string          // UUID of the changed zone, record or token
+	ZoneUUID   string          // Zone the change belongs to, if any
+	Before     json.RawMessage // Object before the change, null when created
+	After      json.RawMessage // Object after the change, null when deleted
+}
+
+// AuditFilter selects audit entries. Zero fields do not filter.
+type AuditFilter struct {
+	ZoneUUID string
+	Actor    string // Matches Actor or ActorID
+	Action   string
+	Since    time.Time
+	Until    time.Time
+	Limit    int // Most recent entries first, 0 for all
+}
+
+// Append adds the entry to the audit log, setting its ID and time.
+func (e *AuditEntry) Append(ctx context.Context) error {
+	return store.AppendAudit(ctx, e)
+}
+
+// Get retrieves the audit entries matching f, most recent first.
+func (f AuditFilter) Get(ctx context.Context) ([]AuditEntry, error) {
+	return store.GetAudit(ctx, f)
+}
+
+// matches reports whether e is selected by f.
+func (f AuditFilter) matches(e AuditEntry) bool {
+	switch {
+	case f.ZoneUUID != "" && e.ZoneUUID != f.ZoneUUID:
+		return false
+	case f.Actor != "" && e.Actor != f.Actor && e.ActorID != f.Actor:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
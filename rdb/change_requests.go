@@ -0,0 +1,61 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Change request statuses. A request is edited while open, approved by
+// someone other than its owner, then applied or closed.
+const (
+	ChangeRequestOpen     = "open"
+	ChangeRequestApproved = "approved"
+	ChangeRequestApplied  = "applied"
+	ChangeRequestClosed   = "closed"
+)
+
+// ChangeRequest is a named set of zone, record and config changes that is
+// kept out of staging until it is approved and applied on its own.
+type ChangeRequest struct {
+	ID           int64           // Sequence number
+	Name         string          // Short title
+	Description  string          // What the changes are for
+	Owner        string          // Name of the token or user that created it
+	OwnerID      string          // UUID of the token or OIDC subject of the owner
+	Status       string          // One of the ChangeRequest statuses
+	Changes      json.RawMessage // The changes, in the order they are applied
+	CreatedAt    time.Time       // Creation time
+	ModifiedAt   time.Time       // Time of the last change
+	ApprovedBy   string          // Name of the approver
+	ApprovedByID string          // UUID of the token or OIDC subject of the approver
+	ApprovedAt   sql.NullTime    // Approval time
+	AppliedAt    sql.NullTime    // Apply time
+	ChangesetID  int64           // Changeset created by the apply, 0 until then
+}
+
+// Get retrieves the change requests with the status of c, all of them when
+// it is empty, most recent first.
+func (c *ChangeRequest) Get(ctx context.Context) ([]ChangeRequest, error) {
+	return store.GetChangeRequests(ctx, c.Status)
+}
+
+// Find retrieves the change request with the ID of c.
+//
+// Returns sql.ErrNoRows when there is no such change request.
+func (c *ChangeRequest) Find(ctx context.Context) error {
+	return store.FindChangeRequest(ctx, c)
+}
+
+// Create stores a new open change request, setting its ID and times.
+func (c *ChangeRequest) Create(ctx context.Context) error {
+	return store.CreateChangeRequest(ctx, c)
+}
+
+// Update stores every field of the change request and sets ModifiedAt.
+//
+// Returns sql.ErrNoRows when there is no such change request.
+func (c *ChangeRequest) Update(ctx context.Context) error {
+	return store.UpdateChangeRequest(ctx, c)
+}
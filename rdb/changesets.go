@@ -0,0 +1,50 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Changeset is one apply of the staged changes, pushed to the output
+// repository as a single commit.
+type Changeset struct {
+	ID         int64           // Sequence number
+	CommitHash string          // Commit of the rendered output
+	AppliedAt  time.Time       // Time of the apply, set on Create
+	Actor      string          // Name of the token or user that applied it
+	ActorID    string          // UUID of the token or OIDC subject
+	Changes    json.RawMessage // Staged zones and records that were applied
+}
+
+// Get retrieves all changesets, most recent first.
+func (c *Changeset) Get(ctx context.Context) ([]Changeset, error) {
+	return store.GetChangesets(ctx)
+}
+
+// Find retrieves the changeset with the ID of c.
+//
+// Returns sql.ErrNoRows when there is no such changeset.
+func (c *Changeset) Find(ctx context.Context) error {
+	return store.FindChangeset(ctx, c)
+}
+
+// Create records a changeset, setting its ID and time. Call it once the
+// zones and records are committed.
+func (c *Changeset) Create(ctx context.Context) error {
+	return store.CreateChangeset(ctx, c)
+}
+
+// State retrieves the zones and records as they were committed by the
+// changeset, including the ones that were deleted by then.
+func (c *Changeset) State(ctx context.Context) ([]Zone, []Record, error) {
+	zones, err := store.GetCommittedZonesAt(ctx, c.AppliedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	records, err := store.GetCommittedRecordsAt(ctx, c.AppliedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return zones, records, nil
+}
@@ -0,0 +1,93 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Deploy job statuses. Queued jobs are claimed by a worker, after which
+// they succeed or fail.
+const (
+	DeployQueued    = "queued"
+	DeployRunning   = "running"
+	DeploySucceeded = "succeeded"
+	DeployFailed    = "failed"
+)
+
+// ErrDeployPending is returned by DeployJob.Create while another job is
+// queued or running.
+var ErrDeployPending = errors.New("another deploy is queued or running")
+
+// DeployJob is one run of the deploy of the committed configuration.
+type DeployJob struct {
+	ID          int64           // Sequence number
+	Status      string          // One of the Deploy statuses
+	CreatedBy   string          // Name of the token or user that asked for it
+	CreatedByID string          // UUID of the token or OIDC subject
+	CreatedAt   time.Time       // Time it was queued
+	StartedAt   sql.NullTime    // Time it was claimed
+	FinishedAt  sql.NullTime    // Time it succeeded or failed
+	Output      string          // Output of the deploy so far
+	Error       string          // Why it failed
+	Results     json.RawMessage // Outcome on every server, null until done
+	Owner       string          // API server running it, set on claim
+	HeartbeatAt sql.NullTime    // Time its owner last renewed its lease on it
+}
+
+// Get retrieves the deploy jobs with the status of j, all of them when it
+// is empty, most recent first.
+func (j *DeployJob) Get(ctx context.Context) ([]DeployJob, error) {
+	return store.GetDeployJobs(ctx, j.Status)
+}
+
+// Find retrieves the deploy job with the ID of j.
+//
+// Returns sql.ErrNoRows when there is no such job.
+func (j *DeployJob) Find(ctx context.Context) error {
+	return store.FindDeployJob(ctx, j)
+}
+
+// Create stores a new deploy job, setting its ID. Jobs are queued unless
+// j has another status, such as running for a job its creator runs itself,
+// which is then leased to the owner of j.
+//
+// Returns ErrDeployPending when another job is queued or running, as only
+// one is at a time.
+func (j *DeployJob) Create(ctx context.Context) error {
+	return store.CreateDeployJob(ctx, j)
+}
+
+// Update stores the status, times, output, error and results of j.
+//
+// Returns sql.ErrNoRows when there is no such job.
+func (j *DeployJob) Update(ctx context.Context) error {
+	return store.UpdateDeployJob(ctx, j)
+}
+
+// ClaimQueued marks the oldest queued job as running, leased to the owner
+// of j, and returns it in j. Each job is claimed once, even with several API
+// servers sharing the database.
+//
+// Returns sql.ErrNoRows when nothing is queued.
+func (j *DeployJob) ClaimQueued(ctx context.Context) error {
+	return store.ClaimDeployJob(ctx, j)
+}
+
+// Renew renews the lease of the owner of j on the running job with the ID
+// of j.
+//
+// Returns sql.ErrNoRows when the job is no longer running or owned by it.
+func (j *DeployJob) Renew(ctx context.Context) error {
+	return store.RenewDeployJob(ctx, j)
+}
+
+// FailStale marks failed with the error of j the running jobs whose lease
+// was last renewed more than lease ago, and those of the owner of j when it
+// is set, as their server stopped running them. It returns the jobs it
+// failed.
+func (j *DeployJob) FailStale(ctx context.Context, lease time.Duration) ([]DeployJob, error) {
+	return store.FailStaleDeployJobs(ctx, j.Owner, lease, j.Error)
+}
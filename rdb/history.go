@@ -0,0 +1,78 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// RecordVersion is a record as it was after one change. A version is kept
+// for every create, update, delete and commit of the record.
+type RecordVersion struct {
+	Version   int64     // Increases with every change of any record
+	ChangedAt time.Time // Time of the change
+	Record    Record    // The record after the change, without tags
+}
+
+// ZoneVersion is a zone as it was after one change.
+type ZoneVersion struct {
+	Version   int64     // Increases with every change of any zone
+	ChangedAt time.Time // Time of the change
+	Zone      Zone      // The zone after the change, without tags
+}
+
+// History lists every version of the record, oldest first.
+func (r *Record) History(ctx context.Context) ([]RecordVersion, error) {
+	return store.GetRecordHistory(ctx, r.UUID)
+}
+
+// GetAsOf retrieves the records of the zone as Get returned them at time at.
+func (r *Record) GetAsOf(ctx context.Context, at time.Time) ([]Record, error) {
+	return store.GetRecordsAsOf(ctx, r.ZoneUUID, at)
+}
+
+// History lists every version of the zone, oldest first.
+func (z *Zone) History(ctx context.Context) ([]ZoneVersion, error) {
+	return store.GetZoneHistory(ctx, z.UUID)
+}
+
+// Committed retrieves the zone with the UUID of z and its records as they
+// were last committed, leaving out the records deleted by then.
+//
+// Returns sql.ErrNoRows when the zone was never committed.
+func (z *Zone) Committed(ctx context.Context) (Zone, []Record, error) {
+	now := time.Now()
+	zones, err := store.GetCommittedZonesAt(ctx, now)
+	if err != nil {
+		return Zone{}, nil, err
+	}
+	var zone Zone
+	found := false
+	for _, committed := range zones {
+		if committed.UUID == z.UUID {
+			zone, found = committed, true
+			break
+		}
+	}
+	if !found {
+		return Zone{}, nil, sql.ErrNoRows
+	}
+
+	all, err := store.GetCommittedRecordsAt(ctx, now)
+	if err != nil {
+		return Zone{}, nil, err
+	}
+	var records []Record
+	for _, record := range all {
+		if record.ZoneUUID == z.UUID && !record.DeletedAt.Valid {
+			records = append(records, record)
+		}
+	}
+	return zone, records, nil
+}
+
+// visible reports whether Get lists a record or zone in this state, pending
+// deletions are listed until they are committed.
+func visible(deletedAt bool, staging bool) bool {
+	return !deletedAt || staging
+}
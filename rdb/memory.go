@@ -0,0 +1,103 @@
+package rdb
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps everything in process memory.
+//
+// When created with a path, the full state is written to that file as JSON
+// after every change and loaded back on start, which is enough for small
+// deployments that do not want to run a PostgreSQL server. With an empty
+// path nothing touches the disk, which is handy for tests.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	path string
+	data memoryData
+
+	// pipeline is the holder of the pipeline lock, which is not persisted
+	pipeline *PipelineLock
+}
+
+// memoryData is the snapshot persisted by MemoryStore.
+type memoryData struct {
+	Zones   []Zone       `json:"zones"`
+	Records []Record     `json:"records"`
+	Tags    []memoryTag  `json:"tags"`
+	Configs []Config     `json:"configs"`
+	Serials []Serial     `json:"serials"`
+	Tokens  []Token      `json:"tokens"`
+	Audit   []AuditEntry `json:"audit"`
+
+	RecordVersions   []RecordVersion  `json:"record_versions"`
+	ZoneVersions     []ZoneVersion    `json:"zone_versions"`
+	Changesets       []Changeset      `json:"changesets"`
+	ChangeRequests   []ChangeRequest  `json:"change_requests"`
+	ScheduledApplies []ScheduledApply `json:"scheduled_applies"`
+	DeployJobs       []DeployJob      `json:"deploy_jobs"`
+}
+
+// memoryTag attaches a tag to either a zone or a record.
+type memoryTag struct {
+	ZoneUUID   string `json:"zone_uuid,omitempty"`
+	RecordUUID string `json:"record_uuid,omitempty"`
+	Tag        Tag    `json:"tag"`
+}
+
+// NewMemoryStore creates a MemoryStore, loading any existing snapshot at path.
+func NewMemoryStore(path string) (*MemoryStore, error) {
+	s := &MemoryStore{path: path}
+	if path == "" {
+		return s, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// save writes the snapshot to disk. Callers must hold the write lock.
+func (s *MemoryStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash never leaves half a snapshot
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
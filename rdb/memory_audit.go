@@ -0,0 +1,33 @@
+package rdb
+
+import (
+	"context"
+	"time"
+)
+
+func (s *MemoryStore) AppendAudit(ctx context.Context, e *AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.ID = int64(len(s.data.Audit)) + 1
+	e.Time = time.Now()
+	s.data.Audit = append(s.data.Audit, *e)
+
+	return s.save()
+}
+
+func (s *MemoryStore) GetAudit(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []AuditEntry{}
+	for i := len(s.data.Audit) - 1; i >= 0; i-- {
+		if f.Limit > 0 && len(entries) == f.Limit {
+			break
+		}
+		if f.matches(s.data.Audit[i]) {
+			entries = append(entries, s.data.Audit[i])
+		}
+	}
+	return entries, nil
+}
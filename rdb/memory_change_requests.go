@@ -0,0 +1,57 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *MemoryStore) GetChangeRequests(ctx context.Context, status string) ([]ChangeRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	requests := []ChangeRequest{}
+	for i := len(s.data.ChangeRequests) - 1; i >= 0; i-- {
+		if status == "" || s.data.ChangeRequests[i].Status == status {
+			requests = append(requests, s.data.ChangeRequests[i])
+		}
+	}
+	return requests, nil
+}
+
+func (s *MemoryStore) FindChangeRequest(ctx context.Context, c *ChangeRequest) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if c.ID < 1 || c.ID > int64(len(s.data.ChangeRequests)) {
+		return sql.ErrNoRows
+	}
+	*c = s.data.ChangeRequests[c.ID-1]
+	return nil
+}
+
+func (s *MemoryStore) CreateChangeRequest(ctx context.Context, c *ChangeRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.ID = int64(len(s.data.ChangeRequests)) + 1
+	c.Status = ChangeRequestOpen
+	c.CreatedAt = time.Now()
+	c.ModifiedAt = c.CreatedAt
+	s.data.ChangeRequests = append(s.data.ChangeRequests, *c)
+
+	return s.save()
+}
+
+func (s *MemoryStore) UpdateChangeRequest(ctx context.Context, c *ChangeRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.ID < 1 || c.ID > int64(len(s.data.ChangeRequests)) {
+		return sql.ErrNoRows
+	}
+	c.ModifiedAt = time.Now()
+	s.data.ChangeRequests[c.ID-1] = *c
+
+	return s.save()
+}
@@ -0,0 +1,40 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *MemoryStore) GetChangesets(ctx context.Context) ([]Changeset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	changesets := []Changeset{}
+	for i := len(s.data.Changesets) - 1; i >= 0; i-- {
+		changesets = append(changesets, s.data.Changesets[i])
+	}
+	return changesets, nil
+}
+
+func (s *MemoryStore) FindChangeset(ctx context.Context, c *Changeset) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if c.ID < 1 || c.ID > int64(len(s.data.Changesets)) {
+		return sql.ErrNoRows
+	}
+	*c = s.data.Changesets[c.ID-1]
+	return nil
+}
+
+func (s *MemoryStore) CreateChangeset(ctx context.Context, c *Changeset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.ID = int64(len(s.data.Changesets)) + 1
+	c.AppliedAt = time.Now()
+	s.data.Changesets = append(s.data.Changesets, *c)
+
+	return s.save()
+}
@@ -0,0 +1,128 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (s *MemoryStore) GetConfigs(ctx context.Context) ([]Config, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	configs := []Config{}
+	for _, config := range s.data.Configs {
+		if config.Staging || !config.DeletedAt.Valid {
+			configs = append(configs, config)
+		}
+	}
+	return configs, nil
+}
+
+func (s *MemoryStore) FindConfigs(ctx context.Context, key string) ([]Config, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.findConfigs(key), nil
+}
+
+func (s *MemoryStore) CreateConfig(ctx context.Context, c *Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// make sure config doesn't already exist
+	for _, config := range s.findConfigs(c.ConfigKey) {
+		if config.ConfigValue == c.ConfigValue {
+			return fmt.Errorf("config %s=%s already exists", config.ConfigKey, c.ConfigValue)
+		}
+	}
+
+	timeNow := time.Now()
+	c.CreatedAt = timeNow
+	c.ModifiedAt = timeNow
+	s.data.Configs = append(s.data.Configs, Config{
+		ConfigKey:   c.ConfigKey,
+		ConfigValue: c.ConfigValue,
+		CreatedAt:   timeNow,
+		ModifiedAt:  timeNow,
+		Staging:     c.Staging,
+	})
+
+	return s.save()
+}
+
+func (s *MemoryStore) UpdateConfig(ctx context.Context, c *Config, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// make sure config with value exists
+	found := false
+	for _, config := range s.findConfigs(c.ConfigKey) {
+		if config.ConfigValue == c.ConfigValue {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("could not find %s=%s", c.ConfigKey, c.ConfigValue)
+	}
+
+	timeNow := time.Now()
+	c.ModifiedAt = timeNow
+	for i, config := range s.data.Configs {
+		if config.ConfigKey == c.ConfigKey && config.ConfigValue == c.ConfigValue {
+			s.data.Configs[i].ConfigValue = value
+			s.data.Configs[i].ModifiedAt = timeNow
+			s.data.Configs[i].Staging = c.Staging
+		}
+	}
+
+	return s.save()
+}
+
+func (s *MemoryStore) DeleteConfig(ctx context.Context, c *Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rowsAffected := 0
+	for i, config := range s.data.Configs {
+		if config.ConfigKey == c.ConfigKey && !config.DeletedAt.Valid {
+			s.data.Configs[i].DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			s.data.Configs[i].Staging = c.Staging
+			rowsAffected++
+		}
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return s.save()
+}
+
+func (s *MemoryStore) DeleteConfigValue(ctx context.Context, c *Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rowsAffected := 0
+	for i, config := range s.data.Configs {
+		if config.ConfigKey == c.ConfigKey && config.ConfigValue == c.ConfigValue && !config.DeletedAt.Valid {
+			s.data.Configs[i].DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			s.data.Configs[i].Staging = c.Staging
+			rowsAffected++
+		}
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return s.save()
+}
+
+func (s *MemoryStore) findConfigs(key string) []Config {
+	configs := []Config{}
+	for _, config := range s.data.Configs {
+		if config.ConfigKey == key && (!config.DeletedAt.Valid || config.Staging) {
+			configs = append(configs, config)
+		}
+	}
+	return configs
+}
@@ -0,0 +1,139 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *MemoryStore) GetDeployJobs(ctx context.Context, status string) ([]DeployJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := []DeployJob{}
+	for i := len(s.data.DeployJobs) - 1; i >= 0; i-- {
+		if status == "" || s.data.DeployJobs[i].Status == status {
+			jobs = append(jobs, s.data.DeployJobs[i])
+		}
+	}
+	return jobs, nil
+}
+
+func (s *MemoryStore) FindDeployJob(ctx context.Context, j *DeployJob) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if j.ID < 1 || j.ID > int64(len(s.data.DeployJobs)) {
+		return sql.ErrNoRows
+	}
+	*j = s.data.DeployJobs[j.ID-1]
+	return nil
+}
+
+func (s *MemoryStore) CreateDeployJob(ctx context.Context, j *DeployJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j.Status == "" {
+		j.Status = DeployQueued
+	}
+	for _, job := range s.data.DeployJobs {
+		if pendingDeploy(job.Status) && pendingDeploy(j.Status) {
+			return ErrDeployPending
+		}
+	}
+
+	j.ID = int64(len(s.data.DeployJobs)) + 1
+	j.CreatedAt = time.Now()
+	if j.Status == DeployRunning {
+		j.HeartbeatAt = sql.NullTime{Time: j.CreatedAt, Valid: true}
+	}
+	s.data.DeployJobs = append(s.data.DeployJobs, *j)
+
+	return s.save()
+}
+
+// pendingDeploy reports whether a job of status holds the place of the one
+// pending job, as deploy_jobs_pending_idx does in PostgreSQL.
+func pendingDeploy(status string) bool {
+	return status == DeployQueued || status == DeployRunning
+}
+
+func (s *MemoryStore) UpdateDeployJob(ctx context.Context, j *DeployJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j.ID < 1 || j.ID > int64(len(s.data.DeployJobs)) {
+		return sql.ErrNoRows
+	}
+	job := &s.data.DeployJobs[j.ID-1]
+	job.Status = j.Status
+	job.StartedAt = j.StartedAt
+	job.FinishedAt = j.FinishedAt
+	job.Output = j.Output
+	job.Error = j.Error
+	job.Results = j.Results
+
+	return s.save()
+}
+
+func (s *MemoryStore) ClaimDeployJob(ctx context.Context, j *DeployJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.data.DeployJobs {
+		job := &s.data.DeployJobs[i]
+		if job.Status != DeployQueued {
+			continue
+		}
+		job.Status = DeployRunning
+		job.StartedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		job.Owner = j.Owner
+		job.HeartbeatAt = job.StartedAt
+		*j = *job
+		return s.save()
+	}
+	return sql.ErrNoRows
+}
+
+func (s *MemoryStore) RenewDeployJob(ctx context.Context, j *DeployJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j.ID < 1 || j.ID > int64(len(s.data.DeployJobs)) {
+		return sql.ErrNoRows
+	}
+	job := &s.data.DeployJobs[j.ID-1]
+	if job.Status != DeployRunning || job.Owner != j.Owner {
+		return sql.ErrNoRows
+	}
+	job.HeartbeatAt = sql.NullTime{Time: time.Now(), Valid: true}
+	j.HeartbeatAt = job.HeartbeatAt
+
+	return s.save()
+}
+
+func (s *MemoryStore) FailStaleDeployJobs(ctx context.Context, owner string, lease time.Duration, reason string) ([]DeployJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failed := []DeployJob{}
+	for i, job := range s.data.DeployJobs {
+		if job.Status != DeployRunning {
+			continue
+		}
+		if (owner == "" || job.Owner != owner) && job.HeartbeatAt.Valid && time.Since(job.HeartbeatAt.Time) <= lease {
+			continue
+		}
+		job.Status = DeployFailed
+		job.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		job.Error = reason
+		s.data.DeployJobs[i] = job
+		failed = append(failed, job)
+	}
+	if len(failed) == 0 {
+		return failed, nil
+	}
+
+	return failed, s.save()
+}
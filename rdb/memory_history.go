@@ -0,0 +1,129 @@
+package rdb
+
+import (
+	"context"
+	"time"
+)
+
+func (s *MemoryStore) GetRecordHistory(ctx context.Context, uuid string) ([]RecordVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := []RecordVersion{}
+	for _, v := range s.data.RecordVersions {
+		if v.Record.UUID == uuid {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+func (s *MemoryStore) GetRecordsAsOf(ctx context.Context, zoneUUID string, at time.Time) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// versions are appended in order, the last one before at wins
+	latest := make(map[string]int)
+	var order []string
+	for i, v := range s.data.RecordVersions {
+		if v.Record.ZoneUUID != zoneUUID || v.ChangedAt.After(at) {
+			continue
+		}
+		if _, ok := latest[v.Record.UUID]; !ok {
+			order = append(order, v.Record.UUID)
+		}
+		latest[v.Record.UUID] = i
+	}
+
+	var records []Record
+	for _, uuid := range order {
+		record := s.data.RecordVersions[latest[uuid]].Record
+		if visible(record.DeletedAt.Valid, record.Staging) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) GetZoneHistory(ctx context.Context, uuid string) ([]ZoneVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := []ZoneVersion{}
+	for _, v := range s.data.ZoneVersions {
+		if v.Zone.UUID == uuid {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+func (s *MemoryStore) GetCommittedZonesAt(ctx context.Context, at time.Time) ([]Zone, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	latest := make(map[string]int)
+	var order []string
+	for i, v := range s.data.ZoneVersions {
+		if v.Zone.Staging || v.ChangedAt.After(at) {
+			continue
+		}
+		if _, ok := latest[v.Zone.UUID]; !ok {
+			order = append(order, v.Zone.UUID)
+		}
+		latest[v.Zone.UUID] = i
+	}
+
+	var zones []Zone
+	for _, uuid := range order {
+		zones = append(zones, s.data.ZoneVersions[latest[uuid]].Zone)
+	}
+	return zones, nil
+}
+
+func (s *MemoryStore) GetCommittedRecordsAt(ctx context.Context, at time.Time) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	latest := make(map[string]int)
+	var order []string
+	for i, v := range s.data.RecordVersions {
+		if v.Record.Staging || v.ChangedAt.After(at) {
+			continue
+		}
+		if _, ok := latest[v.Record.UUID]; !ok {
+			order = append(order, v.Record.UUID)
+		}
+		latest[v.Record.UUID] = i
+	}
+
+	var records []Record
+	for _, uuid := range order {
+		records = append(records, s.data.RecordVersions[latest[uuid]].Record)
+	}
+	return records, nil
+}
+
+// recordChanged keeps a version of the record at index i. Callers must hold
+// the write lock.
+func (s *MemoryStore) recordChanged(i int) {
+	record := s.data.Records[i]
+	record.Tags = nil
+	s.data.RecordVersions = append(s.data.RecordVersions, RecordVersion{
+		Version:   int64(len(s.data.RecordVersions)) + 1,
+		ChangedAt: time.Now(),
+		Record:    record,
+	})
+}
+
+// zoneChanged keeps a version of the zone at index i. Callers must hold the
+// write lock.
+func (s *MemoryStore) zoneChanged(i int) {
+	zone := s.data.Zones[i]
+	zone.Tags = nil
+	s.data.ZoneVersions = append(s.data.ZoneVersions, ZoneVersion{
+		Version:   int64(len(s.data.ZoneVersions)) + 1,
+		ChangedAt: time.Now(),
+		Zone:      zone,
+	})
+}
@@ -0,0 +1,27 @@
+package rdb
+
+import (
+	"context"
+	"time"
+)
+
+func (s *MemoryStore) LockPipeline(ctx context.Context, l *PipelineLock) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pipeline != nil {
+		*l = *s.pipeline
+		return nil, ErrPipelineLocked
+	}
+	l.Since = time.Now()
+	held := *l
+	s.pipeline = &held
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.pipeline == &held {
+			s.pipeline = nil
+		}
+	}, nil
+}
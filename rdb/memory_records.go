@@ -0,0 +1,251 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (s *MemoryStore) GetRecords(ctx context.Context, zoneUUID string) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.zoneIndex(zoneUUID) < 0 {
+		return nil, nil
+	}
+
+	var records []Record
+	for _, record := range s.data.Records {
+		if record.ZoneUUID != zoneUUID || (record.DeletedAt.Valid && !record.Staging) {
+			continue
+		}
+		record.Tags = s.zoneTags(zoneUUID)
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) GetAllRecords(ctx context.Context) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []Record
+	for _, record := range s.data.Records {
+		if record.DeletedAt.Valid && !record.Staging {
+			continue
+		}
+		record.Tags = s.zoneTags(record.ZoneUUID)
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) GetStagingRecords(ctx context.Context) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []Record
+	for _, record := range s.data.Records {
+		if !record.Staging {
+			continue
+		}
+		record.Tags = s.zoneTags(record.ZoneUUID)
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) FindRecord(ctx context.Context, r *Record) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.recordIndex(r.UUID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	record := s.data.Records[i]
+	if record.DeletedAt.Valid && !record.Staging {
+		return sql.ErrNoRows
+	}
+
+	*r = record
+	r.Tags = s.zoneTags(r.ZoneUUID)
+	return nil
+}
+
+func (s *MemoryStore) CreateRecord(ctx context.Context, r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.recordIndex(r.UUID) >= 0 {
+		return fmt.Errorf("record %s already exists", r.UUID)
+	}
+	if s.zoneIndex(r.ZoneUUID) < 0 {
+		return fmt.Errorf("zone %s does not exist", r.ZoneUUID)
+	}
+
+	timeNow := time.Now()
+	r.CreatedAt = timeNow
+	r.ModifiedAt = timeNow
+	r.Staging = true
+
+	record := *r
+	record.Tags = nil
+	s.data.Records = append(s.data.Records, record)
+	s.recordChanged(len(s.data.Records) - 1)
+	for _, tag := range r.Tags {
+		s.addTag(memoryTag{RecordUUID: r.UUID, Tag: Tag(tag)})
+	}
+
+	return s.save()
+}
+
+func (s *MemoryStore) UpdateRecord(ctx context.Context, r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.recordIndex(r.UUID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+
+	// check if record is new
+	if r.Staging && r.CreatedAt == r.ModifiedAt {
+		r.ModifiedAt = time.Now()
+		r.CreatedAt = r.ModifiedAt
+	} else {
+		r.ModifiedAt = time.Now()
+	}
+
+	record := &s.data.Records[i]
+	record.Type = r.Type
+	record.Host = r.Host
+	record.Content = r.Content
+	record.TTL = r.TTL
+	record.AddPTR = r.AddPTR
+	record.CreatedAt = r.CreatedAt
+	record.ModifiedAt = r.ModifiedAt
+	record.Staging = true
+	s.recordChanged(i)
+
+	s.removeTags(func(t memoryTag) bool { return t.RecordUUID == r.UUID })
+	for _, tag := range r.Tags {
+		s.addTag(memoryTag{RecordUUID: r.UUID, Tag: Tag(tag)})
+	}
+
+	return s.save()
+}
+
+func (s *MemoryStore) DeleteRecord(ctx context.Context, r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.recordIndex(r.UUID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+
+	s.data.Records[i].DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	s.data.Records[i].Staging = true
+	s.recordChanged(i)
+
+	return s.save()
+}
+
+func (s *MemoryStore) StageRecord(ctx context.Context, r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.recordIndex(r.UUID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+
+	r.ModifiedAt = time.Now()
+	record := &s.data.Records[i]
+	record.Type = r.Type
+	record.Host = r.Host
+	record.Content = r.Content
+	record.TTL = r.TTL
+	record.AddPTR = r.AddPTR
+	record.ModifiedAt = r.ModifiedAt
+	record.DeletedAt = r.DeletedAt
+	record.Staging = true
+	s.recordChanged(i)
+
+	return s.save()
+}
+
+func (s *MemoryStore) CommitRecords(ctx context.Context, zoneUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.data.Records {
+		if s.data.Records[i].Staging && (zoneUUID == "" || s.data.Records[i].ZoneUUID == zoneUUID) {
+			s.data.Records[i].Staging = false
+			s.recordChanged(i)
+		}
+	}
+
+	return s.save()
+}
+
+func (s *MemoryStore) DiscardRecords(ctx context.Context, zoneUUID, uuid string) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var discarded []Record
+	for i, record := range s.data.Records {
+		if !record.Staging || (zoneUUID != "" && record.ZoneUUID != zoneUUID) || (uuid != "" && record.UUID != uuid) {
+			continue
+		}
+
+		committed, found, versioned := s.committedRecord(record.UUID)
+		switch {
+		case found:
+			s.data.Records[i] = committed
+		case versioned:
+			// created in staging, delete it as committed
+			if !record.DeletedAt.Valid {
+				s.data.Records[i].DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			}
+			s.data.Records[i].Staging = false
+		default:
+			// staged before versions were kept, nothing to revert to
+			continue
+		}
+		s.recordChanged(i)
+
+		record.Tags = s.zoneTags(record.ZoneUUID)
+		discarded = append(discarded, record)
+	}
+
+	return discarded, s.save()
+}
+
+// committedRecord returns the last committed version of the record. versioned
+// is false when the snapshot predates versions and nothing is known.
+func (s *MemoryStore) committedRecord(uuid string) (record Record, found bool, versioned bool) {
+	for i := len(s.data.RecordVersions) - 1; i >= 0; i-- {
+		v := s.data.RecordVersions[i]
+		if v.Record.UUID != uuid {
+			continue
+		}
+		versioned = true
+		if !v.Record.Staging {
+			return v.Record, true, true
+		}
+	}
+	return Record{}, false, versioned
+}
+
+// recordIndex returns the position of the record in the snapshot, or -1.
+func (s *MemoryStore) recordIndex(uuid string) int {
+	for i, record := range s.data.Records {
+		if record.UUID == uuid {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,148 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+func (s *MemoryStore) GetScheduledApplies(ctx context.Context, status string) ([]ScheduledApply, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	applies := []ScheduledApply{}
+	for _, apply := range s.data.ScheduledApplies {
+		if status == "" || apply.Status == status {
+			applies = append(applies, apply)
+		}
+	}
+	sort.SliceStable(applies, func(i, j int) bool {
+		return applies[i].ApplyAt.Before(applies[j].ApplyAt)
+	})
+	return applies, nil
+}
+
+func (s *MemoryStore) FindScheduledApply(ctx context.Context, a *ScheduledApply) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if a.ID < 1 || a.ID > int64(len(s.data.ScheduledApplies)) {
+		return sql.ErrNoRows
+	}
+	*a = s.data.ScheduledApplies[a.ID-1]
+	return nil
+}
+
+func (s *MemoryStore) CreateScheduledApply(ctx context.Context, a *ScheduledApply) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a.ID = int64(len(s.data.ScheduledApplies)) + 1
+	a.Status = SchedulePending
+	a.CreatedAt = time.Now()
+	s.data.ScheduledApplies = append(s.data.ScheduledApplies, *a)
+
+	return s.save()
+}
+
+func (s *MemoryStore) UpdateScheduledApply(ctx context.Context, a *ScheduledApply) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.ID < 1 || a.ID > int64(len(s.data.ScheduledApplies)) {
+		return sql.ErrNoRows
+	}
+	apply := &s.data.ScheduledApplies[a.ID-1]
+	apply.Status = a.Status
+	apply.StartedAt = a.StartedAt
+	apply.FinishedAt = a.FinishedAt
+	apply.Error = a.Error
+	apply.ChangesetID = a.ChangesetID
+
+	return s.save()
+}
+
+func (s *MemoryStore) CancelScheduledApply(ctx context.Context, a *ScheduledApply) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.ID < 1 || a.ID > int64(len(s.data.ScheduledApplies)) || s.data.ScheduledApplies[a.ID-1].Status != SchedulePending {
+		return sql.ErrNoRows
+	}
+	apply := &s.data.ScheduledApplies[a.ID-1]
+	apply.Status = ScheduleCancelled
+	apply.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	*a = *apply
+
+	return s.save()
+}
+
+func (s *MemoryStore) ClaimScheduledApply(ctx context.Context, a *ScheduledApply, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := -1
+	for i, apply := range s.data.ScheduledApplies {
+		if apply.Status != SchedulePending || apply.ApplyAt.After(now) {
+			continue
+		}
+		if due < 0 || apply.ApplyAt.Before(s.data.ScheduledApplies[due].ApplyAt) {
+			due = i
+		}
+	}
+	if due < 0 {
+		return sql.ErrNoRows
+	}
+
+	apply := &s.data.ScheduledApplies[due]
+	apply.Status = ScheduleRunning
+	apply.StartedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	apply.Owner = a.Owner
+	apply.HeartbeatAt = apply.StartedAt
+	*a = *apply
+
+	return s.save()
+}
+
+func (s *MemoryStore) RenewScheduledApply(ctx context.Context, a *ScheduledApply) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.ID < 1 || a.ID > int64(len(s.data.ScheduledApplies)) {
+		return sql.ErrNoRows
+	}
+	apply := &s.data.ScheduledApplies[a.ID-1]
+	if apply.Status != ScheduleRunning || apply.Owner != a.Owner {
+		return sql.ErrNoRows
+	}
+	apply.HeartbeatAt = sql.NullTime{Time: time.Now(), Valid: true}
+	a.HeartbeatAt = apply.HeartbeatAt
+
+	return s.save()
+}
+
+func (s *MemoryStore) FailStaleScheduledApplies(ctx context.Context, owner string, lease time.Duration, reason string) ([]ScheduledApply, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failed := []ScheduledApply{}
+	for i, apply := range s.data.ScheduledApplies {
+		if apply.Status != ScheduleRunning {
+			continue
+		}
+		if (owner == "" || apply.Owner != owner) && apply.HeartbeatAt.Valid && time.Since(apply.HeartbeatAt.Time) <= lease {
+			continue
+		}
+		apply.Status = ScheduleFailed
+		apply.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		apply.Error = reason
+		s.data.ScheduledApplies[i] = apply
+		failed = append(failed, apply)
+	}
+	if len(failed) == 0 {
+		return failed, nil
+	}
+
+	return failed, s.save()
+}
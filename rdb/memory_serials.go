@@ -0,0 +1,36 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *MemoryStore) FindSerial(ctx context.Context, serial *Serial) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, existing := range s.data.Serials {
+		if existing.Zone == serial.Zone {
+			*serial = existing
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (s *MemoryStore) SaveSerial(ctx context.Context, serial *Serial) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	serial.ModifiedAt = time.Now()
+	for i, existing := range s.data.Serials {
+		if existing.Zone == serial.Zone {
+			s.data.Serials[i] = *serial
+			return s.save()
+		}
+	}
+	s.data.Serials = append(s.data.Serials, *serial)
+
+	return s.save()
+}
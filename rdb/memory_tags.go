@@ -0,0 +1,103 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (s *MemoryStore) GetRecordTags(ctx context.Context, recordUUID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recordTags(recordUUID), nil
+}
+
+func (s *MemoryStore) GetZoneTags(ctx context.Context, zoneUUID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.zoneTags(zoneUUID), nil
+}
+
+func (s *MemoryStore) CreateRecordTag(ctx context.Context, recordUUID string, t Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addTag(memoryTag{RecordUUID: recordUUID, Tag: t})
+	return s.save()
+}
+
+func (s *MemoryStore) CreateZoneTag(ctx context.Context, zoneUUID string, t Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addTag(memoryTag{ZoneUUID: zoneUUID, Tag: t})
+	return s.save()
+}
+
+func (s *MemoryStore) DeleteRecordTags(ctx context.Context, recordUUID string, t Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.recordTags(recordUUID)) == 0 {
+		return ErrTagNotFound
+	}
+	if s.removeTags(func(m memoryTag) bool { return m.RecordUUID == recordUUID && (t == "" || m.Tag == t) }) == 0 {
+		return sql.ErrNoRows
+	}
+	return s.save()
+}
+
+func (s *MemoryStore) DeleteZoneTags(ctx context.Context, zoneUUID string, t Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.zoneTags(zoneUUID)) == 0 {
+		return ErrTagNotFound
+	}
+	if s.removeTags(func(m memoryTag) bool { return m.ZoneUUID == zoneUUID && (t == "" || m.Tag == t) }) == 0 {
+		return sql.ErrNoRows
+	}
+	return s.save()
+}
+
+func (s *MemoryStore) recordTags(recordUUID string) []string {
+	tags := make([]string, 0)
+	for _, t := range s.data.Tags {
+		if t.RecordUUID == recordUUID {
+			tags = append(tags, t.Tag.String())
+		}
+	}
+	return tags
+}
+
+func (s *MemoryStore) zoneTags(zoneUUID string) []string {
+	tags := make([]string, 0)
+	for _, t := range s.data.Tags {
+		if t.ZoneUUID == zoneUUID {
+			tags = append(tags, t.Tag.String())
+		}
+	}
+	return tags
+}
+
+// addTag attaches a tag unless the same tag is already attached.
+func (s *MemoryStore) addTag(tag memoryTag) {
+	for _, t := range s.data.Tags {
+		if t == tag {
+			return
+		}
+	}
+	s.data.Tags = append(s.data.Tags, tag)
+}
+
+// removeTags drops every tag matching fn and returns how many were removed.
+func (s *MemoryStore) removeTags(fn func(memoryTag) bool) int {
+	kept := s.data.Tags[:0]
+	for _, t := range s.data.Tags {
+		if !fn(t) {
+			kept = append(kept, t)
+		}
+	}
+	removed := len(s.data.Tags) - len(kept)
+	s.data.Tags = kept
+	return removed
+}
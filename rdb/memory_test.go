@@ -0,0 +1,479 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useMemoryStore makes a new MemoryStore the active backend.
+func useMemoryStore(t *testing.T, path string) *MemoryStore {
+	t.Helper()
+	s, err := NewMemoryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Use(s)
+	return s
+}
+
+func testZone(name string) Zone {
+	return Zone{
+		UUID:        ZoneUUID(name),
+		Name:        name,
+		PrimaryNS:   "ns1." + name,
+		AdminEmail:  "hostmaster." + name,
+		Refresh:     7200,
+		Retry:       3600,
+		Expire:      1209600,
+		Minimum:     300,
+		TTL:         3600,
+		NameServers: NameServers{{Host: "ns1", Addresses: []string{"192.0.2.1"}}},
+	}
+}
+
+func zoneNames(zones []Zone) string {
+	var names []string
+	for _, zone := range zones {
+		names = append(names, zone.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestMemoryZones(t *testing.T) {
+	useMemoryStore(t, "")
+	ctx := context.Background()
+
+	zone := testZone("example.com")
+	zone.Tags = []string{"prod"}
+	if err := zone.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !zone.Staging || zone.CreatedAt.IsZero() {
+		t.Errorf("Create() = %+v, want it staged with its creation time", zone)
+	}
+	if err := zone.Create(ctx); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Create() of a duplicate error = %v, want already exists", err)
+	}
+	other := testZone("example.net")
+	if err := other.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	found := Zone{UUID: zone.UUID}
+	if err := found.Find(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if found.Name != "example.com" || len(found.NameServers) != 1 || strings.Join(found.Tags, ",") != "prod" {
+		t.Errorf("Find() = %+v, want example.com with its name server and tag", found)
+	}
+	if err := (&Zone{UUID: ZoneUUID("missing.com")}).Find(ctx); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Find() of a missing zone error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := (&Zone{UUID: zone.UUID}).Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	staged, _ := (&Zone{}).GetStaging(ctx)
+	if zoneNames(staged) != "example.net" {
+		t.Errorf("GetStaging() after committing example.com = %s, want example.net", zoneNames(staged))
+	}
+
+	// a staged change is reverted by discarding it, a zone never committed
+	// is deleted
+	changed := found
+	changed.TTL = 60
+	if err := changed.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	discarded, err := (&Zone{}).Discard(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zoneNames(discarded) != "example.com,example.net" {
+		t.Errorf("Discard() = %s, want example.com,example.net", zoneNames(discarded))
+	}
+	zones, _ := (&Zone{}).Get(ctx)
+	if len(zones) != 1 || zones[0].Name != "example.com" || zones[0].TTL != 3600 || zones[0].Staging {
+		t.Errorf("Get() after Discard() = %+v, want example.com committed with TTL 3600", zones)
+	}
+
+	// a deleted zone is listed while staged and gone once committed
+	if err := (&Zone{UUID: zone.UUID}).Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if zones, _ := (&Zone{}).Get(ctx); len(zones) != 1 || !zones[0].DeletedAt.Valid {
+		t.Errorf("Get() of a staged deletion = %+v, want the zone marked deleted", zones)
+	}
+	if err := (&Zone{UUID: zone.UUID}).Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if zones, _ := (&Zone{}).Get(ctx); len(zones) != 0 {
+		t.Errorf("Get() after committing the deletion = %+v, want none", zones)
+	}
+	if err := (&Zone{UUID: zone.UUID}).Find(ctx); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Find() of a deleted zone error = %v, want sql.ErrNoRows", err)
+	}
+
+	history, err := (&Zone{UUID: zone.UUID}).History(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) == 0 || history[len(history)-1].Zone.Staging || !history[len(history)-1].Zone.DeletedAt.Valid {
+		t.Errorf("History() = %+v, want it to end with the committed deletion", history)
+	}
+}
+
+func TestMemoryRecords(t *testing.T) {
+	useMemoryStore(t, "")
+	ctx := context.Background()
+
+	zone := testZone("example.com")
+	if err := zone.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Record{UUID: "orphan", ZoneUUID: ZoneUUID("missing.com"), Type: "A"}).Create(ctx); err == nil {
+		t.Error("Create() of a record of a missing zone succeeded")
+	}
+
+	www := Record{UUID: "www", ZoneUUID: zone.UUID, Type: "A", Host: "www", Content: "192.0.2.10", TTL: 300}
+	mail := Record{UUID: "mail", ZoneUUID: zone.UUID, Type: "MX", Host: "@", Content: "10 mail", TTL: 300}
+	for _, r := range []*Record{&www, &mail} {
+		if err := r.Create(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zone.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := (&Record{}).GetStaging(ctx); len(records) != 0 {
+		t.Errorf("GetStaging() after Commit() = %+v, want none", records)
+	}
+
+	changed := www
+	changed.Content = "192.0.2.20"
+	if err := changed.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Record{UUID: "mail"}).Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// discarding one record leaves the other staged
+	discarded, err := (&Record{UUID: "www"}).Discard(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discarded) != 1 || discarded[0].Content != "192.0.2.20" {
+		t.Errorf("Discard() = %+v, want the staged www record", discarded)
+	}
+	found := Record{UUID: "www"}
+	if err := found.Find(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if found.Content != "192.0.2.10" || found.Staging {
+		t.Errorf("Find() after Discard() = %+v, want the committed content", found)
+	}
+	staged, _ := (&Record{}).GetStaging(ctx)
+	if len(staged) != 1 || staged[0].UUID != "mail" || !staged[0].DeletedAt.Valid {
+		t.Errorf("GetStaging() = %+v, want the deletion of mail", staged)
+	}
+
+	if err := zone.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	records, err := (&Record{ZoneUUID: zone.UUID}).Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].UUID != "www" {
+		t.Errorf("Get() = %+v, want www only", records)
+	}
+	if records, _ := (&Record{ZoneUUID: ZoneUUID("missing.com")}).Get(ctx); records != nil {
+		t.Errorf("Get() of a missing zone = %+v, want none", records)
+	}
+}
+
+func TestMemoryStagingScope(t *testing.T) {
+	useMemoryStore(t, "")
+	ctx := context.Background()
+
+	committed := testZone("example.com")
+	other := testZone("example.net")
+	for _, z := range []*Zone{&committed, &other} {
+		if err := z.Create(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := (&Zone{}).Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	changed := committed
+	changed.TTL = 60
+	if err := changed.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	created := testZone("example.org")
+	if err := created.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// in the scope of example.net, the others are seen as committed
+	scoped := WithStagingScope(ctx, []string{other.UUID})
+	zones, err := (&Zone{}).Get(scoped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zoneNames(zones) != "example.com,example.net" {
+		t.Errorf("Get() in scope = %s, want example.com,example.net", zoneNames(zones))
+	}
+	for _, zone := range zones {
+		if zone.Name == "example.com" && (zone.TTL != 3600 || zone.Staging) {
+			t.Errorf("Get() in scope = %+v, want example.com as committed", zone)
+		}
+	}
+	if staged, _ := (&Zone{}).GetStaging(scoped); len(staged) != 0 {
+		t.Errorf("GetStaging() in scope = %s, want none", zoneNames(staged))
+	}
+
+	scoped = WithStagingScope(ctx, []string{created.UUID, committed.UUID})
+	if staged, _ := (&Zone{}).GetStaging(scoped); zoneNames(staged) != "example.com,example.org" {
+		t.Errorf("GetStaging() in scope = %s, want example.com,example.org", zoneNames(staged))
+	}
+	if uuids, ok := StagingScope(scoped); !ok || len(uuids) != 2 {
+		t.Errorf("StagingScope() = %v, %t, want the 2 zones", uuids, ok)
+	}
+	if _, ok := StagingScope(ctx); ok {
+		t.Error("StagingScope() of a context without scope is set")
+	}
+}
+
+func TestMemoryConfigs(t *testing.T) {
+	useMemoryStore(t, "")
+	ctx := context.Background()
+
+	for _, value := range []string{"10.1.1.1", "10.1.1.2"} {
+		if err := (&Config{ConfigKey: "servers", ConfigValue: value}).Create(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := (&Config{ConfigKey: "servers", ConfigValue: "10.1.1.1"}).Create(ctx); err == nil {
+		t.Error("Create() of a duplicate value succeeded")
+	}
+	if err := (&Config{ConfigKey: "servers", ConfigValue: "10.1.1.2"}).Update(ctx, "10.1.1.3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Config{ConfigKey: "servers", ConfigValue: "10.1.1.9"}).Update(ctx, "10.1.1.4"); err == nil {
+		t.Error("Update() of a missing value succeeded")
+	}
+	if err := (&Config{ConfigKey: "servers", ConfigValue: "10.1.1.1"}).DeleteValue(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err := (&Config{ConfigKey: "servers"}).Find(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 || configs[0].ConfigValue != "10.1.1.3" {
+		t.Errorf("Find() = %+v, want 10.1.1.3 only", configs)
+	}
+
+	if err := (&Config{ConfigKey: "servers"}).Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Config{ConfigKey: "servers"}).Delete(ctx); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Delete() of a deleted key error = %v, want sql.ErrNoRows", err)
+	}
+	if configs, _ := (&Config{}).Get(ctx); len(configs) != 0 {
+		t.Errorf("Get() after Delete() = %+v, want none", configs)
+	}
+}
+
+func TestMemoryZoneTags(t *testing.T) {
+	s := useMemoryStore(t, "")
+	ctx := context.Background()
+	zone := testZone("example.com")
+	if err := zone.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteZoneTags(ctx, zone.UUID, "prod"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("DeleteZoneTags() without tags error = %v, want ErrTagNotFound", err)
+	}
+	for _, tag := range []Tag{"prod", "prod", "edge"} {
+		if err := s.CreateZoneTag(ctx, zone.UUID, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tags, _ := s.GetZoneTags(ctx, zone.UUID); strings.Join(tags, ",") != "prod,edge" {
+		t.Errorf("GetZoneTags() = %v, want prod,edge once each", tags)
+	}
+	if err := s.DeleteZoneTags(ctx, zone.UUID, "internal"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteZoneTags() of a missing tag error = %v, want sql.ErrNoRows", err)
+	}
+	if err := s.DeleteZoneTags(ctx, zone.UUID, "prod"); err != nil {
+		t.Fatal(err)
+	}
+	if tags, _ := s.GetZoneTags(ctx, zone.UUID); strings.Join(tags, ",") != "edge" {
+		t.Errorf("GetZoneTags() = %v, want edge", tags)
+	}
+}
+
+func TestMemoryScheduleLease(t *testing.T) {
+	useMemoryStore(t, "")
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, at := range []time.Time{now.Add(-time.Minute), now.Add(-2 * time.Minute), now.Add(time.Hour)} {
+		if err := (&ScheduledApply{Kind: ScheduleKindStaging, ApplyAt: at}).Create(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the apply due first is claimed first, the one not due yet never is
+	first := ScheduledApply{Owner: "api-1"}
+	if err := first.ClaimDue(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	second := ScheduledApply{Owner: "api-2"}
+	if err := second.ClaimDue(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != 2 || second.ID != 1 || first.Status != ScheduleRunning || !first.HeartbeatAt.Valid {
+		t.Errorf("ClaimDue() = %+v and %+v, want 2 then 1 running", first, second)
+	}
+	if err := (&ScheduledApply{}).ClaimDue(ctx, now); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ClaimDue() with nothing due error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := (&ScheduledApply{ID: first.ID, Owner: "api-2"}).Renew(ctx); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Renew() by another owner error = %v, want sql.ErrNoRows", err)
+	}
+	if err := first.Renew(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted api-1 fails what it was running, the lease of api-2 holds
+	failed, err := (&ScheduledApply{Owner: "api-1", Error: "server restarted"}).FailStale(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].ID != first.ID || failed[0].Status != ScheduleFailed || failed[0].Error != "server restarted" {
+		t.Errorf("FailStale() = %+v, want apply %d failed", failed, first.ID)
+	}
+	if err := first.Renew(ctx); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Renew() of a failed apply error = %v, want sql.ErrNoRows", err)
+	}
+
+	// other servers fail api-2's apply once its lease has run out
+	if failed, _ := (&ScheduledApply{Error: "lease expired"}).FailStale(ctx, time.Minute); len(failed) != 0 {
+		t.Errorf("FailStale() within the lease = %+v, want none", failed)
+	}
+	time.Sleep(10 * time.Millisecond)
+	failed, err = (&ScheduledApply{Error: "lease expired"}).FailStale(ctx, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].ID != second.ID {
+		t.Errorf("FailStale() after the lease = %+v, want apply %d failed", failed, second.ID)
+	}
+}
+
+func TestMemoryDeployJobPending(t *testing.T) {
+	useMemoryStore(t, "")
+	ctx := context.Background()
+
+	queued := DeployJob{CreatedBy: "ops"}
+	if err := queued.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []string{DeployQueued, DeployRunning} {
+		if err := (&DeployJob{Status: status, CreatedBy: "ops"}).Create(ctx); !errors.Is(err, ErrDeployPending) {
+			t.Errorf("Create() of a %s job beside a queued one error = %v, want ErrDeployPending", status, err)
+		}
+	}
+
+	// the next deploy is queued once the pending one is done
+	claimed := DeployJob{Owner: "api-1"}
+	if err := claimed.ClaimQueued(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&DeployJob{CreatedBy: "ops"}).Create(ctx); !errors.Is(err, ErrDeployPending) {
+		t.Errorf("Create() beside a running job error = %v, want ErrDeployPending", err)
+	}
+	claimed.Status = DeploySucceeded
+	if err := claimed.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	next := DeployJob{CreatedBy: "ops"}
+	if err := next.Create(ctx); err != nil || next.ID != 2 {
+		t.Errorf("Create() after the deploy = %+v %v, want job 2 queued", next, err)
+	}
+}
+
+func TestMemorySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bind-api.json")
+	s := useMemoryStore(t, path)
+	ctx := context.Background()
+
+	zone := testZone("example.com")
+	zone.Tags = []string{"prod"}
+	if err := zone.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Record{UUID: "www", ZoneUUID: zone.UUID, Type: "A", Host: "www", Content: "192.0.2.10"}).Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := zone.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("snapshot directory holds %d files, want the snapshot only", len(entries))
+	}
+
+	useMemoryStore(t, path)
+	found := Zone{UUID: zone.UUID}
+	if err := found.Find(ctx); err != nil {
+		t.Fatalf("Find() after reloading error = %v", err)
+	}
+	if found.Staging || found.NameServers[0].Addresses[0] != "192.0.2.1" || strings.Join(found.Tags, ",") != "prod" {
+		t.Errorf("Find() after reloading = %+v, want the committed zone", found)
+	}
+	record := Record{UUID: "www"}
+	if err := record.Find(ctx); err != nil || record.Content != "192.0.2.10" {
+		t.Errorf("Find() after reloading = %+v, %v, want the www record", record, err)
+	}
+
+	// versions are kept, so a reloaded store still discards to them
+	changed := found
+	changed.TTL = 60
+	if err := changed.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Zone{}).Discard(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := found.Find(ctx); err != nil || found.TTL != 3600 {
+		t.Errorf("Find() after Discard() = %+v, %v, want TTL 3600", found, err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMemoryStore(path); err == nil {
+		t.Error("NewMemoryStore() of a corrupt snapshot succeeded")
+	}
+}
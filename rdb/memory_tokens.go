@@ -0,0 +1,87 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (s *MemoryStore) GetTokens(ctx context.Context) ([]Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]Token, len(s.data.Tokens))
+	copy(tokens, s.data.Tokens)
+	return tokens, nil
+}
+
+func (s *MemoryStore) FindToken(ctx context.Context, t *Token) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.data.Tokens {
+		if token.UUID == t.UUID {
+			*t = token
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (s *MemoryStore) FindTokenByHash(ctx context.Context, t *Token) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.data.Tokens {
+		if token.Hash == t.Hash {
+			*t = token
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (s *MemoryStore) CreateToken(ctx context.Context, t *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.data.Tokens {
+		if token.UUID == t.UUID || token.Hash == t.Hash {
+			return fmt.Errorf("token %s already exists", t.UUID)
+		}
+	}
+
+	t.CreatedAt = time.Now()
+	s.data.Tokens = append(s.data.Tokens, *t)
+
+	return s.save()
+}
+
+func (s *MemoryStore) RevokeToken(ctx context.Context, t *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, token := range s.data.Tokens {
+		if token.UUID == t.UUID && !token.RevokedAt.Valid {
+			s.data.Tokens[i].RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			*t = s.data.Tokens[i]
+			return s.save()
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (s *MemoryStore) TouchToken(ctx context.Context, t *Token, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, token := range s.data.Tokens {
+		if token.UUID == t.UUID {
+			s.data.Tokens[i].LastUsedAt = sql.NullTime{Time: at, Valid: true}
+			t.LastUsedAt = s.data.Tokens[i].LastUsedAt
+			return s.save()
+		}
+	}
+	return sql.ErrNoRows
+}
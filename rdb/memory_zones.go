@@ -0,0 +1,227 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (s *MemoryStore) GetZones(ctx context.Context) ([]Zone, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var zones []Zone
+	for _, zone := range s.data.Zones {
+		if zone.DeletedAt.Valid && !zone.Staging {
+			continue
+		}
+		zone.Tags = s.zoneTags(zone.UUID)
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+func (s *MemoryStore) GetStagingZones(ctx context.Context) ([]Zone, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var zones []Zone
+	for _, zone := range s.data.Zones {
+		if !zone.Staging {
+			continue
+		}
+		zone.Tags = s.zoneTags(zone.UUID)
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+func (s *MemoryStore) FindZone(ctx context.Context, z *Zone) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.zoneIndex(z.UUID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	zone := s.data.Zones[i]
+	if zone.DeletedAt.Valid && !zone.Staging {
+		return sql.ErrNoRows
+	}
+
+	*z = zone
+	z.Tags = s.zoneTags(z.UUID)
+	return nil
+}
+
+func (s *MemoryStore) CreateZone(ctx context.Context, z *Zone) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.zoneIndex(z.UUID) >= 0 {
+		return fmt.Errorf("zone %s already exists", z.UUID)
+	}
+
+	timeNow := time.Now()
+	z.CreatedAt = timeNow
+	z.ModifiedAt = timeNow
+	z.Staging = true
+
+	zone := *z
+	zone.Tags = nil
+	s.data.Zones = append(s.data.Zones, zone)
+	s.zoneChanged(len(s.data.Zones) - 1)
+	for _, tag := range z.Tags {
+		s.addTag(memoryTag{ZoneUUID: z.UUID, Tag: Tag(tag)})
+	}
+
+	return s.save()
+}
+
+func (s *MemoryStore) UpdateZone(ctx context.Context, z *Zone) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.zoneIndex(z.UUID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+
+	zone := &s.data.Zones[i]
+	zone.Name = z.Name
+	zone.PrimaryNS = z.PrimaryNS
+	zone.AdminEmail = z.AdminEmail
+	zone.Refresh = z.Refresh
+	zone.Retry = z.Retry
+	zone.Expire = z.Expire
+	zone.Minimum = z.Minimum
+	zone.TTL = z.TTL
+	zone.NameServers = z.NameServers
+	zone.Staging = true
+	s.zoneChanged(i)
+
+	s.removeTags(func(t memoryTag) bool { return t.ZoneUUID == z.UUID })
+	for _, tag := range z.Tags {
+		s.addTag(memoryTag{ZoneUUID: z.UUID, Tag: Tag(tag)})
+	}
+
+	return s.save()
+}
+
+func (s *MemoryStore) DeleteZone(ctx context.Context, z *Zone) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.zoneIndex(z.UUID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+
+	s.data.Zones[i].DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	s.data.Zones[i].Staging = true
+	s.zoneChanged(i)
+
+	return s.save()
+}
+
+func (s *MemoryStore) StageZone(ctx context.Context, z *Zone) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.zoneIndex(z.UUID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+
+	z.ModifiedAt = time.Now()
+	zone := &s.data.Zones[i]
+	zone.Name = z.Name
+	zone.PrimaryNS = z.PrimaryNS
+	zone.AdminEmail = z.AdminEmail
+	zone.Refresh = z.Refresh
+	zone.Retry = z.Retry
+	zone.Expire = z.Expire
+	zone.Minimum = z.Minimum
+	zone.TTL = z.TTL
+	zone.NameServers = z.NameServers
+	zone.ModifiedAt = z.ModifiedAt
+	zone.DeletedAt = z.DeletedAt
+	zone.Staging = true
+	s.zoneChanged(i)
+
+	return s.save()
+}
+
+func (s *MemoryStore) CommitZones(ctx context.Context, uuid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.data.Zones {
+		if s.data.Zones[i].Staging && (uuid == "" || s.data.Zones[i].UUID == uuid) {
+			s.data.Zones[i].Staging = false
+			s.zoneChanged(i)
+		}
+	}
+
+	return s.save()
+}
+
+func (s *MemoryStore) DiscardZones(ctx context.Context, uuid string) ([]Zone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var discarded []Zone
+	for i, zone := range s.data.Zones {
+		if !zone.Staging || (uuid != "" && zone.UUID != uuid) {
+			continue
+		}
+
+		committed, found, versioned := s.committedZone(zone.UUID)
+		switch {
+		case found:
+			s.data.Zones[i] = committed
+		case versioned:
+			// created in staging, delete it as committed
+			if !zone.DeletedAt.Valid {
+				s.data.Zones[i].DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			}
+			s.data.Zones[i].Staging = false
+		default:
+			// staged before versions were kept, nothing to revert to
+			continue
+		}
+		s.zoneChanged(i)
+
+		zone.Tags = s.zoneTags(zone.UUID)
+		discarded = append(discarded, zone)
+	}
+
+	return discarded, s.save()
+}
+
+// committedZone returns the last committed version of the zone. versioned is
+// false when the snapshot predates versions and nothing is known.
+func (s *MemoryStore) committedZone(uuid string) (zone Zone, found bool, versioned bool) {
+	for i := len(s.data.ZoneVersions) - 1; i >= 0; i-- {
+		v := s.data.ZoneVersions[i]
+		if v.Zone.UUID != uuid {
+			continue
+		}
+		versioned = true
+		if !v.Zone.Staging {
+			return v.Zone, true, true
+		}
+	}
+	return Zone{}, false, versioned
+}
+
+// zoneIndex returns the position of the zone in the snapshot, or -1.
+func (s *MemoryStore) zoneIndex(uuid string) int {
+	for i, zone := range s.data.Zones {
+		if zone.UUID == uuid {
+			return i
+		}
+	}
+	return -1
+}
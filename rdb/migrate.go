@@ -0,0 +1,145 @@
+package rdb
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var ErrMigrationsUnsupported = errors.New("storage backend does not use schema migrations")
+
+// Migration is one versioned schema change, read from migrations/NNNN_name.{up,down}.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator is implemented by stores that keep a versioned schema.
+type Migrator interface {
+	// MigrateUp applies every pending migration in order and returns the ones applied.
+	MigrateUp(ctx context.Context, migrations []Migration) ([]Migration, error)
+	// MigrateDown reverts the latest steps applied migrations and returns the ones reverted.
+	MigrateDown(ctx context.Context, migrations []Migration, steps int) ([]Migration, error)
+	// MigrationStatus lists every known migration with its applied state.
+	MigrationStatus(ctx context.Context, migrations []Migration) ([]MigrationStatus, error)
+}
+
+// MigrateUp applies all pending migrations to the active store.
+func MigrateUp(ctx context.Context) ([]Migration, error) {
+	m, ok := store.(Migrator)
+	if !ok {
+		return nil, ErrMigrationsUnsupported
+	}
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return m.MigrateUp(ctx, migrations)
+}
+
+// MigrateDown reverts the last steps migrations of the active store.
+func MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	m, ok := store.(Migrator)
+	if !ok {
+		return nil, ErrMigrationsUnsupported
+	}
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return m.MigrateDown(ctx, migrations, steps)
+}
+
+// GetMigrationStatus lists the migrations known to this build and whether they are applied.
+func GetMigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	m, ok := store.(Migrator)
+	if !ok {
+		return nil, ErrMigrationsUnsupported
+	}
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return m.MigrationStatus(ctx, migrations)
+}
+
+// loadMigrations reads the embedded migration files sorted by version.
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, migrationName, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file %s is not named NNNN_name.%s.sql", name, direction)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s has invalid version: %w", name, err)
+		}
+
+		body, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: migrationName}
+			byVersion[version] = m
+		} else if m.Name != migrationName {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, m.Name, migrationName)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
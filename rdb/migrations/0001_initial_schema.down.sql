@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS bind_dns.configs;
+DROP TABLE IF EXISTS bind_dns.tags;
+DROP TABLE IF EXISTS bind_dns.records;
+DROP TABLE IF EXISTS bind_dns.zones;
@@ -0,0 +1,63 @@
+-- Tables are created only when missing so that databases set up by hand
+-- before migrations existed can adopt this baseline without changes.
+CREATE SCHEMA IF NOT EXISTS bind_dns;
+
+CREATE TABLE IF NOT EXISTS bind_dns.zones (
+    uuid        UUID PRIMARY KEY,
+    name        TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    modified_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at  TIMESTAMPTZ,
+    staging     BOOLEAN NOT NULL DEFAULT TRUE,
+    primary_ns  TEXT NOT NULL DEFAULT '',
+    admin_email TEXT NOT NULL DEFAULT '',
+    refresh     INTEGER NOT NULL DEFAULT 0,
+    retry       INTEGER NOT NULL DEFAULT 0,
+    expire      BIGINT NOT NULL DEFAULT 0,
+    minimum     INTEGER NOT NULL DEFAULT 0,
+    ttl         INTEGER NOT NULL DEFAULT 3600
+);
+
+CREATE TABLE IF NOT EXISTS bind_dns.records (
+    uuid        UUID PRIMARY KEY,
+    type        TEXT NOT NULL,
+    host        TEXT NOT NULL,
+    content     TEXT NOT NULL,
+    ttl         INTEGER NOT NULL DEFAULT 3600,
+    add_ptr     BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    modified_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at  TIMESTAMPTZ,
+    zone_uuid   UUID NOT NULL REFERENCES bind_dns.zones (uuid),
+    staging     BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE INDEX IF NOT EXISTS records_zone_uuid_idx ON bind_dns.records (zone_uuid);
+
+CREATE TABLE IF NOT EXISTS bind_dns.tags (
+    id          BIGSERIAL PRIMARY KEY,
+    zone_uuid   UUID REFERENCES bind_dns.zones (uuid) ON DELETE CASCADE,
+    record_uuid UUID REFERENCES bind_dns.records (uuid) ON DELETE CASCADE,
+    tag         TEXT NOT NULL,
+    CHECK ((zone_uuid IS NULL) <> (record_uuid IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tags_zone_tag_idx ON bind_dns.tags (zone_uuid, tag) WHERE zone_uuid IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS tags_record_tag_idx ON bind_dns.tags (record_uuid, tag) WHERE record_uuid IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS bind_dns.configs (
+    id           BIGSERIAL PRIMARY KEY,
+    config_key   TEXT NOT NULL,
+    config_value TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    modified_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at   TIMESTAMPTZ,
+    staging      BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS configs_config_key_idx ON bind_dns.configs (config_key);
+
+-- ApplyStagingHandler and DeployConfig flip this between deployed and awaiting_deployment
+INSERT INTO bind_dns.configs (config_key, config_value)
+SELECT 'config_status', 'deployed'
+WHERE NOT EXISTS (SELECT 1 FROM bind_dns.configs WHERE config_key = 'config_status');
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS bind_dns.zone_serials;
@@ -0,0 +1,8 @@
+-- SOA serials are persisted so that they only change when a zone does.
+-- Keyed by name since generated reverse zones have no row in bind_dns.zones.
+CREATE TABLE bind_dns.zone_serials (
+    zone_name    TEXT PRIMARY KEY,
+    serial       BIGINT NOT NULL CHECK (serial >= 0 AND serial <= 4294967295),
+    content_hash TEXT NOT NULL,
+    modified_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
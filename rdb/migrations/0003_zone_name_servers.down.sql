@@ -0,0 +1 @@
+ALTER TABLE bind_dns.zones DROP COLUMN IF EXISTS name_servers;
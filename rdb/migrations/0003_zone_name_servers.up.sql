@@ -0,0 +1,5 @@
+-- Name servers and their glue used to be hard-coded in the zone template as
+-- "@ NS ns" and "ns A 10.224.8.59", existing zones keep rendering that way.
+ALTER TABLE bind_dns.zones ADD COLUMN name_servers JSONB NOT NULL DEFAULT '[]';
+
+UPDATE bind_dns.zones SET name_servers = '[{"host": "ns", "addresses": ["10.224.8.59"]}]';
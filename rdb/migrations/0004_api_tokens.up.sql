@@ -0,0 +1,12 @@
+-- API tokens authenticate requests as bearer tokens. Only a SHA-256 of the
+-- secret is stored, revoked tokens are kept for reference.
+CREATE TABLE bind_dns.api_tokens (
+    uuid         UUID PRIMARY KEY,
+    name         TEXT NOT NULL,
+    token_hash   TEXT NOT NULL UNIQUE,
+    admin        BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
@@ -0,0 +1,7 @@
+ALTER TABLE bind_dns.api_tokens ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE bind_dns.api_tokens SET admin = (role = 'admin');
+
+ALTER TABLE bind_dns.api_tokens
+    DROP COLUMN role,
+    DROP COLUMN scope;
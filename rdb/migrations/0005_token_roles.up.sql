@@ -0,0 +1,9 @@
+-- Tokens carry a role instead of the admin flag. Tokens created before roles
+-- existed could do everything but manage tokens, which is the operator role.
+ALTER TABLE bind_dns.api_tokens
+    ADD COLUMN role  TEXT NOT NULL DEFAULT 'viewer',
+    ADD COLUMN scope JSONB NOT NULL DEFAULT '{}';
+
+UPDATE bind_dns.api_tokens SET role = CASE WHEN admin THEN 'admin' ELSE 'operator' END;
+
+ALTER TABLE bind_dns.api_tokens DROP COLUMN admin;
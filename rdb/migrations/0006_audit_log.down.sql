@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS bind_dns.audit_log;
+DROP FUNCTION IF EXISTS bind_dns.audit_log_append_only();
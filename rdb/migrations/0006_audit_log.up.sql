@@ -0,0 +1,27 @@
+-- Every change made through the API, appended by the handlers. Rows can
+-- neither be changed nor removed, the trigger rejects it.
+CREATE TABLE bind_dns.audit_log (
+    id          BIGSERIAL PRIMARY KEY,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actor       TEXT NOT NULL,
+    actor_id    TEXT NOT NULL DEFAULT '',
+    action      TEXT NOT NULL,
+    object_uuid TEXT,
+    zone_uuid   TEXT,
+    before      JSONB,
+    after       JSONB
+);
+
+CREATE INDEX audit_log_zone_uuid_idx ON bind_dns.audit_log (zone_uuid, id);
+CREATE INDEX audit_log_actor_idx ON bind_dns.audit_log (actor, id);
+CREATE INDEX audit_log_created_at_idx ON bind_dns.audit_log (created_at);
+
+CREATE FUNCTION bind_dns.audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'bind_dns.audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON bind_dns.audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION bind_dns.audit_log_append_only();
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS record_version ON bind_dns.records;
+DROP TRIGGER IF EXISTS zone_version ON bind_dns.zones;
+DROP FUNCTION IF EXISTS bind_dns.record_version();
+DROP FUNCTION IF EXISTS bind_dns.zone_version();
+DROP TABLE IF EXISTS bind_dns.record_versions;
+DROP TABLE IF EXISTS bind_dns.zone_versions;
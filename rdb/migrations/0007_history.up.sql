@@ -0,0 +1,72 @@
+-- Every change of a zone or record row is kept as a version by triggers, so
+-- that no write path can skip it. Tags are not versioned.
+CREATE TABLE bind_dns.zone_versions (
+    version      BIGSERIAL PRIMARY KEY,
+    changed_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    uuid         UUID NOT NULL,
+    name         TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL,
+    modified_at  TIMESTAMPTZ NOT NULL,
+    deleted_at   TIMESTAMPTZ,
+    staging      BOOLEAN NOT NULL,
+    primary_ns   TEXT NOT NULL,
+    admin_email  TEXT NOT NULL,
+    refresh      INTEGER NOT NULL,
+    retry        INTEGER NOT NULL,
+    expire       BIGINT NOT NULL,
+    minimum      INTEGER NOT NULL,
+    ttl          INTEGER NOT NULL,
+    name_servers JSONB NOT NULL
+);
+
+CREATE INDEX zone_versions_uuid_idx ON bind_dns.zone_versions (uuid, version);
+
+CREATE TABLE bind_dns.record_versions (
+    version     BIGSERIAL PRIMARY KEY,
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    uuid        UUID NOT NULL,
+    type        TEXT NOT NULL,
+    host        TEXT NOT NULL,
+    content     TEXT NOT NULL,
+    ttl         INTEGER NOT NULL,
+    add_ptr     BOOLEAN NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    modified_at TIMESTAMPTZ NOT NULL,
+    deleted_at  TIMESTAMPTZ,
+    zone_uuid   UUID NOT NULL,
+    staging     BOOLEAN NOT NULL
+);
+
+CREATE INDEX record_versions_uuid_idx ON bind_dns.record_versions (uuid, version);
+CREATE INDEX record_versions_zone_uuid_idx ON bind_dns.record_versions (zone_uuid, changed_at);
+
+CREATE FUNCTION bind_dns.zone_version() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO bind_dns.zone_versions (uuid, name, created_at, modified_at, deleted_at, staging, primary_ns, admin_email, refresh, retry, expire, minimum, ttl, name_servers)
+    VALUES (NEW.uuid, NEW.name, NEW.created_at, NEW.modified_at, NEW.deleted_at, NEW.staging, NEW.primary_ns, NEW.admin_email, NEW.refresh, NEW.retry, NEW.expire, NEW.minimum, NEW.ttl, NEW.name_servers);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION bind_dns.record_version() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO bind_dns.record_versions (uuid, type, host, content, ttl, add_ptr, created_at, modified_at, deleted_at, zone_uuid, staging)
+    VALUES (NEW.uuid, NEW.type, NEW.host, NEW.content, NEW.ttl, NEW.add_ptr, NEW.created_at, NEW.modified_at, NEW.deleted_at, NEW.zone_uuid, NEW.staging);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER zone_version AFTER INSERT OR UPDATE ON bind_dns.zones
+    FOR EACH ROW EXECUTE FUNCTION bind_dns.zone_version();
+
+CREATE TRIGGER record_version AFTER INSERT OR UPDATE ON bind_dns.records
+    FOR EACH ROW EXECUTE FUNCTION bind_dns.record_version();
+
+-- The current rows are the first known versions, as of their last change
+INSERT INTO bind_dns.zone_versions (changed_at, uuid, name, created_at, modified_at, deleted_at, staging, primary_ns, admin_email, refresh, retry, expire, minimum, ttl, name_servers)
+SELECT COALESCE(deleted_at, modified_at), uuid, name, created_at, modified_at, deleted_at, staging, primary_ns, admin_email, refresh, retry, expire, minimum, ttl, name_servers
+FROM bind_dns.zones ORDER BY modified_at;
+
+INSERT INTO bind_dns.record_versions (changed_at, uuid, type, host, content, ttl, add_ptr, created_at, modified_at, deleted_at, zone_uuid, staging)
+SELECT COALESCE(deleted_at, modified_at), uuid, type, host, content, ttl, add_ptr, created_at, modified_at, deleted_at, zone_uuid, staging
+FROM bind_dns.records ORDER BY modified_at;
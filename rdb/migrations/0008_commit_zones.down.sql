@@ -0,0 +1,5 @@
+-- Committed zones cannot be told apart from ones that were staged, so only
+-- the reconstructed record versions are dropped.
+DELETE FROM bind_dns.record_versions v
+USING bind_dns.records r
+WHERE v.uuid = r.uuid AND v.staging = FALSE AND v.changed_at = r.created_at AND v.deleted_at IS NULL AND r.staging = TRUE;
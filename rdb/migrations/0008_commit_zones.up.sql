@@ -0,0 +1,15 @@
+-- Discarding staged changes reverts rows to their last committed version.
+--
+-- Zones were never taken out of staging on commit, so every zone as it is
+-- now is what was last applied. Committing them writes those versions.
+UPDATE bind_dns.zones SET staging = FALSE WHERE staging = TRUE;
+
+-- Records changed after their last commit have no committed version from
+-- before versions were kept. The closest known is the row without its
+-- pending deletion, so discarding never loses a record that was committed.
+INSERT INTO bind_dns.record_versions (changed_at, uuid, type, host, content, ttl, add_ptr, created_at, modified_at, deleted_at, zone_uuid, staging)
+SELECT created_at, uuid, type, host, content, ttl, add_ptr, created_at, modified_at, NULL, zone_uuid, FALSE
+FROM bind_dns.records r
+WHERE staging = TRUE
+  AND (created_at <> modified_at OR deleted_at IS NOT NULL)
+  AND NOT EXISTS (SELECT 1 FROM bind_dns.record_versions v WHERE v.uuid = r.uuid AND v.staging = FALSE);
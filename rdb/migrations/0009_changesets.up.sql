@@ -0,0 +1,11 @@
+-- Every apply of the staged changes, tied to the commit of the rendered
+-- output it pushed. The state of zones and records at a changeset is found
+-- in their versions as of applied_at.
+CREATE TABLE bind_dns.changesets (
+    id          BIGSERIAL PRIMARY KEY,
+    commit_hash TEXT NOT NULL,
+    applied_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actor       TEXT NOT NULL,
+    actor_id    TEXT NOT NULL DEFAULT '',
+    changes     JSONB
+);
@@ -0,0 +1,20 @@
+-- Named sets of changes that are reviewed and applied on their own. The
+-- changes stay out of the zones and records tables until the apply.
+CREATE TABLE bind_dns.change_requests (
+    id             BIGSERIAL PRIMARY KEY,
+    name           TEXT NOT NULL,
+    description    TEXT NOT NULL DEFAULT '',
+    owner          TEXT NOT NULL,
+    owner_id       TEXT NOT NULL DEFAULT '',
+    status         TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'approved', 'applied', 'closed')),
+    changes        JSONB,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    modified_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    approved_by    TEXT NOT NULL DEFAULT '',
+    approved_by_id TEXT NOT NULL DEFAULT '',
+    approved_at    TIMESTAMPTZ,
+    applied_at     TIMESTAMPTZ,
+    changeset_id   BIGINT REFERENCES bind_dns.changesets (id)
+);
+
+CREATE INDEX change_requests_status_idx ON bind_dns.change_requests (status, id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS bind_dns.scheduled_applies;
@@ -0,0 +1,19 @@
+-- Applies of the staged changes or of a change request that run at a given
+-- time, kept here so that they survive restarts.
+CREATE TABLE bind_dns.scheduled_applies (
+    id                BIGSERIAL PRIMARY KEY,
+    kind              TEXT NOT NULL CHECK (kind IN ('staging', 'change_request')),
+    change_request_id BIGINT REFERENCES bind_dns.change_requests (id),
+    apply_at          TIMESTAMPTZ NOT NULL,
+    deploy            BOOLEAN NOT NULL DEFAULT FALSE,
+    status            TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'succeeded', 'failed', 'cancelled')),
+    created_by        TEXT NOT NULL,
+    created_by_id     TEXT NOT NULL DEFAULT '',
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at        TIMESTAMPTZ,
+    finished_at       TIMESTAMPTZ,
+    error             TEXT NOT NULL DEFAULT '',
+    changeset_id      BIGINT REFERENCES bind_dns.changesets (id)
+);
+
+CREATE INDEX scheduled_applies_due_idx ON bind_dns.scheduled_applies (status, apply_at);
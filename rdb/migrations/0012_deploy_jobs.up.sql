@@ -0,0 +1,14 @@
+-- Deploys run in the background, with their status and output kept here.
+CREATE TABLE bind_dns.deploy_jobs (
+    id            BIGSERIAL PRIMARY KEY,
+    status        TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+    created_by    TEXT NOT NULL,
+    created_by_id TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at    TIMESTAMPTZ,
+    finished_at   TIMESTAMPTZ,
+    output        TEXT NOT NULL DEFAULT '',
+    error         TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX deploy_jobs_status_idx ON bind_dns.deploy_jobs (status, id);
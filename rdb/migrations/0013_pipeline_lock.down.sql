@@ -0,0 +1 @@
+DROP TABLE IF EXISTS bind_dns.pipeline_lock;
@@ -0,0 +1,10 @@
+-- Holder of the pipeline advisory lock, reported to callers that find the
+-- lock taken. A row left behind by a server that stopped is overwritten by
+-- the next holder.
+CREATE TABLE bind_dns.pipeline_lock (
+    id        INTEGER PRIMARY KEY CHECK (id = 1),
+    holder    TEXT NOT NULL,
+    holder_id TEXT NOT NULL DEFAULT '',
+    action    TEXT NOT NULL,
+    since     TIMESTAMPTZ NOT NULL
+);
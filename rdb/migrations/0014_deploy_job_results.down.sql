@@ -0,0 +1 @@
+ALTER TABLE bind_dns.deploy_jobs DROP COLUMN IF EXISTS results;
@@ -0,0 +1,2 @@
+-- Outcome of a deploy job on every server, as reported by the deploy driver.
+ALTER TABLE bind_dns.deploy_jobs ADD COLUMN results JSONB;
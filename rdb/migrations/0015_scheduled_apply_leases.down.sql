@@ -0,0 +1 @@
+ALTER TABLE bind_dns.scheduled_applies DROP COLUMN IF EXISTS owner, DROP COLUMN IF EXISTS heartbeat_at;
@@ -0,0 +1,5 @@
+-- API server running an apply and the last time it renewed its lease on it,
+-- so that only the applies of servers that stopped are failed.
+ALTER TABLE bind_dns.scheduled_applies
+    ADD COLUMN owner TEXT NOT NULL DEFAULT '',
+    ADD COLUMN heartbeat_at TIMESTAMPTZ;
@@ -0,0 +1,5 @@
+-- API server running a deploy job and the last time it renewed its lease on
+-- it, so that only the jobs of servers that stopped are failed.
+ALTER TABLE bind_dns.deploy_jobs
+    ADD COLUMN owner TEXT NOT NULL DEFAULT '',
+    ADD COLUMN heartbeat_at TIMESTAMPTZ;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS bind_dns.deploy_jobs_pending_idx;
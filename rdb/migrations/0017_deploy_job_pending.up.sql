@@ -0,0 +1,8 @@
+-- At most one deploy is queued or running at a time. Pending jobs beyond
+-- the oldest, left by concurrent requests, are failed first.
+UPDATE bind_dns.deploy_jobs
+SET status = 'failed', finished_at = NOW(), error = 'another deploy was already pending'
+WHERE status IN ('queued', 'running')
+  AND id > (SELECT MIN(id) FROM bind_dns.deploy_jobs WHERE status IN ('queued', 'running'));
+
+CREATE UNIQUE INDEX deploy_jobs_pending_idx ON bind_dns.deploy_jobs ((TRUE)) WHERE status IN ('queued', 'running');
@@ -0,0 +1,30 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrPipelineLocked is returned by PipelineLock.Lock while another caller
+// holds the lock.
+var ErrPipelineLocked = errors.New("the render, commit and deploy pipeline is locked")
+
+// PipelineLock guards the steps that share the output directory and its git
+// worktree, rendering, committing and deploying, so that only one apply,
+// rollback or deploy runs at a time across every API server.
+type PipelineLock struct {
+	Holder   string    // Name of the token or user holding the lock
+	HolderID string    // UUID of the token or OIDC subject
+	Action   string    // What the lock is held for, such as apply or deploy
+	Since    time.Time // Time the lock was taken, set on Lock
+}
+
+// Lock takes the pipeline lock for the holder and action of l, without
+// waiting, and returns the function that releases it.
+//
+// Returns ErrPipelineLocked with l set to the current holder when the lock
+// is taken.
+func (l *PipelineLock) Lock(ctx context.Context) (func(), error) {
+	return store.LockPipeline(ctx, l)
+}
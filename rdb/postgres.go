@@ -0,0 +1,29 @@
+package rdb
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresStore is the Store backed by the bind_dns schema in PostgreSQL.
+type postgresStore struct {
+	db *sql.DB
+}
+
+// newPostgresStore establishes a connection to the database
+func newPostgresStore(host string, port int, user, password, dbname string, sslmode string) (*postgresStore, error) {
+	dbinfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		host, port, user, password, dbname, sslmode)
+
+	postgres, err := sql.Open("postgres", dbinfo)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresStore{db: postgres}, nil
+}
+
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,82 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+func (s *postgresStore) AppendAudit(ctx context.Context, e *AuditEntry) error {
+	row := s.db.QueryRowContext(ctx, `INSERT INTO bind_dns.audit_log (actor, actor_id, action, object_uuid, zone_uuid, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`,
+		e.Actor, e.ActorID, e.Action, nullString(e.ObjectUUID), nullString(e.ZoneUUID), nullJSON(e.Before), nullJSON(e.After))
+	return row.Scan(&e.ID, &e.Time)
+}
+
+func (s *postgresStore) GetAudit(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.ZoneUUID != "" {
+		add("zone_uuid = $%d", f.ZoneUUID)
+	}
+	if f.Actor != "" {
+		add("(actor = $%[1]d OR actor_id = $%[1]d)", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= $%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("created_at < $%d", f.Until)
+	}
+
+	query := "SELECT id, created_at, actor, actor_id, action, object_uuid, zone_uuid, before, after FROM bind_dns.audit_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var object, zone sql.NullString
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.Time, &e.Actor, &e.ActorID, &e.Action, &object, &zone, &before, &after); err != nil {
+			return nil, err
+		}
+		e.ObjectUUID = object.String
+		e.ZoneUUID = zone.String
+		e.Before = before
+		e.After = after
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullJSON stores absent objects as SQL NULL rather than a JSON null.
+func nullJSON(b []byte) interface{} {
+	if len(b) == 0 || string(b) == "null" {
+		return nil
+	}
+	return string(b)
+}
@@ -0,0 +1,70 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const changeRequestColumns = "id, name, description, owner, owner_id, status, changes, created_at, modified_at, approved_by, approved_by_id, approved_at, applied_at, changeset_id"
+
+func scanChangeRequest(row interface{ Scan(...interface{}) error }, c *ChangeRequest) error {
+	var changes []byte
+	var changeset sql.NullInt64
+	if err := row.Scan(&c.ID, &c.Name, &c.Description, &c.Owner, &c.OwnerID, &c.Status, &changes, &c.CreatedAt, &c.ModifiedAt, &c.ApprovedBy, &c.ApprovedByID, &c.ApprovedAt, &c.AppliedAt, &changeset); err != nil {
+		return err
+	}
+	c.Changes = changes
+	c.ChangesetID = changeset.Int64
+	return nil
+}
+
+func (s *postgresStore) GetChangeRequests(ctx context.Context, status string) ([]ChangeRequest, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+changeRequestColumns+" FROM bind_dns.change_requests WHERE $1 = '' OR status = $1 ORDER BY id DESC", status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []ChangeRequest{}
+	for rows.Next() {
+		var c ChangeRequest
+		if err := scanChangeRequest(rows, &c); err != nil {
+			return nil, err
+		}
+		requests = append(requests, c)
+	}
+	return requests, rows.Err()
+}
+
+func (s *postgresStore) FindChangeRequest(ctx context.Context, c *ChangeRequest) error {
+	row := s.db.QueryRowContext(ctx, "SELECT "+changeRequestColumns+" FROM bind_dns.change_requests WHERE id = $1", c.ID)
+	return scanChangeRequest(row, c)
+}
+
+func (s *postgresStore) CreateChangeRequest(ctx context.Context, c *ChangeRequest) error {
+	c.Status = ChangeRequestOpen
+	c.CreatedAt = time.Now()
+	c.ModifiedAt = c.CreatedAt
+	row := s.db.QueryRowContext(ctx, "INSERT INTO bind_dns.change_requests (name, description, owner, owner_id, status, changes, created_at, modified_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $7) RETURNING id",
+		c.Name, c.Description, c.Owner, c.OwnerID, c.Status, nullJSON(c.Changes), c.CreatedAt)
+	return row.Scan(&c.ID)
+}
+
+func (s *postgresStore) UpdateChangeRequest(ctx context.Context, c *ChangeRequest) error {
+	c.ModifiedAt = time.Now()
+	result, err := s.db.ExecContext(ctx, `UPDATE bind_dns.change_requests SET name = $1, description = $2, status = $3, changes = $4, modified_at = $5,
+		approved_by = $6, approved_by_id = $7, approved_at = $8, applied_at = $9, changeset_id = $10 WHERE id = $11`,
+		c.Name, c.Description, c.Status, nullJSON(c.Changes), c.ModifiedAt, c.ApprovedBy, c.ApprovedByID, c.ApprovedAt, c.AppliedAt, sql.NullInt64{Int64: c.ChangesetID, Valid: c.ChangesetID != 0}, c.ID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
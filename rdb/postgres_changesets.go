@@ -0,0 +1,47 @@
+package rdb
+
+import (
+	"context"
+)
+
+const changesetColumns = "id, commit_hash, applied_at, actor, actor_id, changes"
+
+func scanChangeset(row interface{ Scan(...interface{}) error }, c *Changeset) error {
+	var changes []byte
+	if err := row.Scan(&c.ID, &c.CommitHash, &c.AppliedAt, &c.Actor, &c.ActorID, &changes); err != nil {
+		return err
+	}
+	c.Changes = changes
+	return nil
+}
+
+func (s *postgresStore) GetChangesets(ctx context.Context) ([]Changeset, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+changesetColumns+" FROM bind_dns.changesets ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changesets := []Changeset{}
+	for rows.Next() {
+		var c Changeset
+		if err := scanChangeset(rows, &c); err != nil {
+			return nil, err
+		}
+		changesets = append(changesets, c)
+	}
+	return changesets, rows.Err()
+}
+
+func (s *postgresStore) FindChangeset(ctx context.Context, c *Changeset) error {
+	row := s.db.QueryRowContext(ctx, "SELECT "+changesetColumns+" FROM bind_dns.changesets WHERE id = $1", c.ID)
+	return scanChangeset(row, c)
+}
+
+// CreateChangeset takes the time from the database, so that it is never
+// before the versions written by the commit.
+func (s *postgresStore) CreateChangeset(ctx context.Context, c *Changeset) error {
+	row := s.db.QueryRowContext(ctx, "INSERT INTO bind_dns.changesets (commit_hash, actor, actor_id, changes) VALUES ($1, $2, $3, $4) RETURNING id, applied_at",
+		c.CommitHash, c.Actor, c.ActorID, nullJSON(c.Changes))
+	return row.Scan(&c.ID, &c.AppliedAt)
+}
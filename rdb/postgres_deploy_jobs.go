@@ -0,0 +1,117 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const deployJobColumns = "id, status, created_by, created_by_id, created_at, started_at, finished_at, output, error, results, owner, heartbeat_at"
+
+func scanDeployJob(row interface{ Scan(...interface{}) error }, j *DeployJob) error {
+	var results []byte
+	if err := row.Scan(&j.ID, &j.Status, &j.CreatedBy, &j.CreatedByID, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.Output, &j.Error, &results, &j.Owner, &j.HeartbeatAt); err != nil {
+		return err
+	}
+	j.Results = results
+	return nil
+}
+
+func (s *postgresStore) GetDeployJobs(ctx context.Context, status string) ([]DeployJob, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+deployJobColumns+" FROM bind_dns.deploy_jobs WHERE $1 = '' OR status = $1 ORDER BY id DESC", status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []DeployJob{}
+	for rows.Next() {
+		var j DeployJob
+		if err := scanDeployJob(rows, &j); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *postgresStore) FindDeployJob(ctx context.Context, j *DeployJob) error {
+	row := s.db.QueryRowContext(ctx, "SELECT "+deployJobColumns+" FROM bind_dns.deploy_jobs WHERE id = $1", j.ID)
+	return scanDeployJob(row, j)
+}
+
+func (s *postgresStore) CreateDeployJob(ctx context.Context, j *DeployJob) error {
+	if j.Status == "" {
+		j.Status = DeployQueued
+	}
+	j.CreatedAt = time.Now()
+	if j.Status == DeployRunning {
+		j.HeartbeatAt = sql.NullTime{Time: j.CreatedAt, Valid: true}
+	}
+	// deploy_jobs_pending_idx lets a single job be queued or running, the
+	// insert of another one does nothing
+	row := s.db.QueryRowContext(ctx, "INSERT INTO bind_dns.deploy_jobs (status, created_by, created_by_id, created_at, started_at, owner, heartbeat_at) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING RETURNING id",
+		j.Status, j.CreatedBy, j.CreatedByID, j.CreatedAt, j.StartedAt, j.Owner, j.HeartbeatAt)
+	err := row.Scan(&j.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDeployPending
+	}
+	return err
+}
+
+func (s *postgresStore) UpdateDeployJob(ctx context.Context, j *DeployJob) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE bind_dns.deploy_jobs SET status = $1, started_at = $2, finished_at = $3, output = $4, error = $5, results = $6 WHERE id = $7",
+		j.Status, j.StartedAt, j.FinishedAt, j.Output, j.Error, nullJSON(j.Results), j.ID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimDeployJob skips rows locked by another server claiming them.
+func (s *postgresStore) ClaimDeployJob(ctx context.Context, j *DeployJob) error {
+	row := s.db.QueryRowContext(ctx, `UPDATE bind_dns.deploy_jobs SET status = $1, started_at = NOW(), owner = $3, heartbeat_at = NOW()
+		WHERE id = (
+			SELECT id FROM bind_dns.deploy_jobs
+			WHERE status = $2
+			ORDER BY id LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		) RETURNING `+deployJobColumns, DeployRunning, DeployQueued, j.Owner)
+	return scanDeployJob(row, j)
+}
+
+func (s *postgresStore) RenewDeployJob(ctx context.Context, j *DeployJob) error {
+	row := s.db.QueryRowContext(ctx, "UPDATE bind_dns.deploy_jobs SET heartbeat_at = NOW() WHERE id = $1 AND status = $2 AND owner = $3 RETURNING heartbeat_at",
+		j.ID, DeployRunning, j.Owner)
+	return row.Scan(&j.HeartbeatAt)
+}
+
+// FailStaleDeployJobs compares leases with the clock of the database, which
+// every server shares.
+func (s *postgresStore) FailStaleDeployJobs(ctx context.Context, owner string, lease time.Duration, reason string) ([]DeployJob, error) {
+	rows, err := s.db.QueryContext(ctx, `UPDATE bind_dns.deploy_jobs SET status = $1, finished_at = NOW(), error = $2
+		WHERE status = $3 AND (($4 <> '' AND owner = $4) OR heartbeat_at IS NULL OR heartbeat_at < NOW() - make_interval(secs => $5))
+		RETURNING `+deployJobColumns, DeployFailed, reason, DeployRunning, owner, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failed := []DeployJob{}
+	for rows.Next() {
+		var j DeployJob
+		if err := scanDeployJob(rows, &j); err != nil {
+			return nil, err
+		}
+		failed = append(failed, j)
+	}
+	return failed, rows.Err()
+}
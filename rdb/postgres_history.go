@@ -0,0 +1,118 @@
+package rdb
+
+import (
+	"context"
+	"time"
+)
+
+// Versions are written by the triggers of migration 0007.
+
+const recordVersionColumns = "version, changed_at, uuid, type, host, content, ttl, add_ptr, created_at, modified_at, deleted_at, zone_uuid, staging"
+
+func scanRecordVersion(row interface{ Scan(...interface{}) error }, v *RecordVersion) error {
+	r := &v.Record
+	return row.Scan(&v.Version, &v.ChangedAt, &r.UUID, &r.Type, &r.Host, &r.Content, &r.TTL, &r.AddPTR, &r.CreatedAt, &r.ModifiedAt, &r.DeletedAt, &r.ZoneUUID, &r.Staging)
+}
+
+func (s *postgresStore) GetRecordHistory(ctx context.Context, uuid string) ([]RecordVersion, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+recordVersionColumns+" FROM bind_dns.record_versions WHERE uuid::text = $1 ORDER BY changed_at, version", uuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []RecordVersion{}
+	for rows.Next() {
+		var v RecordVersion
+		if err := scanRecordVersion(rows, &v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *postgresStore) GetRecordsAsOf(ctx context.Context, zoneUUID string, at time.Time) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+recordVersionColumns+` FROM (
+			SELECT DISTINCT ON (uuid) * FROM bind_dns.record_versions
+			WHERE zone_uuid::text = $1 AND changed_at <= $2
+			ORDER BY uuid, changed_at DESC, version DESC
+		) AS v ORDER BY version`, zoneUUID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var v RecordVersion
+		if err := scanRecordVersion(rows, &v); err != nil {
+			return nil, err
+		}
+		if visible(v.Record.DeletedAt.Valid, v.Record.Staging) {
+			records = append(records, v.Record)
+		}
+	}
+	return records, rows.Err()
+}
+
+func (s *postgresStore) GetZoneHistory(ctx context.Context, uuid string) ([]ZoneVersion, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT version, changed_at, uuid, name, created_at, modified_at, deleted_at, staging, primary_ns, admin_email, refresh, retry, expire, minimum, ttl, name_servers FROM bind_dns.zone_versions WHERE uuid::text = $1 ORDER BY changed_at, version", uuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []ZoneVersion{}
+	for rows.Next() {
+		var v ZoneVersion
+		z := &v.Zone
+		if err := rows.Scan(&v.Version, &v.ChangedAt, &z.UUID, &z.Name, &z.CreatedAt, &z.ModifiedAt, &z.DeletedAt, &z.Staging, &z.PrimaryNS, &z.AdminEmail, &z.Refresh, &z.Retry, &z.Expire, &z.Minimum, &z.TTL, &z.NameServers); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *postgresStore) GetCommittedZonesAt(ctx context.Context, at time.Time) ([]Zone, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT ON (uuid) uuid, name, created_at, modified_at, deleted_at, staging, primary_ns, admin_email, refresh, retry, expire, minimum, ttl, name_servers
+		FROM bind_dns.zone_versions
+		WHERE staging = FALSE AND changed_at <= $1
+		ORDER BY uuid, changed_at DESC, version DESC`, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var zones []Zone
+	for rows.Next() {
+		var z Zone
+		if err := rows.Scan(&z.UUID, &z.Name, &z.CreatedAt, &z.ModifiedAt, &z.DeletedAt, &z.Staging, &z.PrimaryNS, &z.AdminEmail, &z.Refresh, &z.Retry, &z.Expire, &z.Minimum, &z.TTL, &z.NameServers); err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
+
+func (s *postgresStore) GetCommittedRecordsAt(ctx context.Context, at time.Time) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT ON (uuid) `+recordVersionColumns+`
+		FROM bind_dns.record_versions
+		WHERE staging = FALSE AND changed_at <= $1
+		ORDER BY uuid, changed_at DESC, version DESC`, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var v RecordVersion
+		if err := scanRecordVersion(rows, &v); err != nil {
+			return nil, err
+		}
+		records = append(records, v.Record)
+	}
+	return records, rows.Err()
+}
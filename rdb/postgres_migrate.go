@@ -0,0 +1,170 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migrationLockID is the advisory lock key held while migrating, so that
+// replicas starting at the same time do not apply the same migration twice.
+const migrationLockID = 7243013501
+
+func (s *postgresStore) MigrateUp(ctx context.Context, migrations []Migration) ([]Migration, error) {
+	conn, err := s.migrationConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseMigrationConn(conn)
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return done, err
+		}
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			tx.Rollback()
+			return done, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO bind_dns.schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)", m.Version, m.Name, time.Now()); err != nil {
+			tx.Rollback()
+			return done, err
+		}
+		if err := tx.Commit(); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+func (s *postgresStore) MigrateDown(ctx context.Context, migrations []Migration, steps int) ([]Migration, error) {
+	conn, err := s.migrationConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseMigrationConn(conn)
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return done, fmt.Errorf("migration %d_%s cannot be reverted", m.Version, m.Name)
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return done, err
+		}
+		if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+			tx.Rollback()
+			return done, fmt.Errorf("reverting migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM bind_dns.schema_migrations WHERE version = $1", m.Version); err != nil {
+			tx.Rollback()
+			return done, err
+		}
+		if err := tx.Commit(); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+func (s *postgresStore) MigrationStatus(ctx context.Context, migrations []Migration) ([]MigrationStatus, error) {
+	conn, err := s.migrationConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseMigrationConn(conn)
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = &at
+		}
+		status = append(status, st)
+	}
+	return status, nil
+}
+
+// migrationConn takes a dedicated connection holding the migration lock and
+// makes sure the bookkeeping table exists.
+func (s *postgresStore) migrationConn(ctx context.Context) (*sql.Conn, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	query := `CREATE SCHEMA IF NOT EXISTS bind_dns;
+CREATE TABLE IF NOT EXISTS bind_dns.schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL
+)`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		s.releaseMigrationConn(conn)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (s *postgresStore) releaseMigrationConn(conn *sql.Conn) {
+	conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+	conn.Close()
+}
+
+// appliedMigrations returns the applied migration versions with their apply time.
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM bind_dns.schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
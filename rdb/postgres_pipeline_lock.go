@@ -0,0 +1,54 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// pipelineLockID is the advisory lock key held while rendering, committing
+// or deploying. The holder is kept in bind_dns.pipeline_lock for callers
+// that find it taken, the advisory lock itself goes away with the session
+// of a server that stopped.
+const pipelineLockID = 7243013502
+
+func (s *postgresStore) LockPipeline(ctx context.Context, l *PipelineLock) (func(), error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", pipelineLockID).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked {
+		conn.Close()
+		row := s.db.QueryRowContext(ctx, "SELECT holder, holder_id, action, since FROM bind_dns.pipeline_lock WHERE id = 1")
+		if err := row.Scan(&l.Holder, &l.HolderID, &l.Action, &l.Since); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, ErrPipelineLocked
+	}
+
+	l.Since = time.Now()
+	_, err = conn.ExecContext(ctx, `INSERT INTO bind_dns.pipeline_lock (id, holder, holder_id, action, since) VALUES (1, $1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET holder = EXCLUDED.holder, holder_id = EXCLUDED.holder_id, action = EXCLUDED.action, since = EXCLUDED.since`,
+		l.Holder, l.HolderID, l.Action, l.Since)
+	if err != nil {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", pipelineLockID)
+		conn.Close()
+		return nil, err
+	}
+
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), "DELETE FROM bind_dns.pipeline_lock WHERE id = 1"); err != nil {
+			log.Printf("Unable to clear the pipeline lock holder: %v", err)
+		}
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", pipelineLockID)
+		conn.Close()
+	}, nil
+}
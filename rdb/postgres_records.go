@@ -0,0 +1,340 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (s *postgresStore) GetRecords(ctx context.Context, zoneUUID string) ([]Record, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT r.uuid, r.type, r.host, r.content, r.ttl, r.add_ptr, r.created_at, r.modified_at, r.deleted_at, r.staging FROM bind_dns.records AS r JOIN bind_dns.zones AS z ON r.zone_uuid = z.uuid WHERE z.uuid::text = $1 AND (r.deleted_at IS NULL OR r.staging = TRUE)", zoneUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		if err := rows.Scan(&record.UUID, &record.Type, &record.Host, &record.Content, &record.TTL, &record.AddPTR, &record.CreatedAt, &record.ModifiedAt, &record.DeletedAt, &record.Staging); err != nil {
+			return nil, fmt.Errorf("failed to scan record: %w", err)
+		}
+
+		record.Tags, err = s.GetZoneTags(ctx, zoneUUID)
+		record.ZoneUUID = zoneUUID
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tags: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *postgresStore) GetAllRecords(ctx context.Context) ([]Record, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT uuid, type, host, content, ttl, add_ptr, created_at, modified_at, deleted_at, zone_uuid, staging FROM bind_dns.records WHERE deleted_at IS NULL OR staging = TRUE")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		if err := rows.Scan(&record.UUID, &record.Type, &record.Host, &record.Content, &record.TTL, &record.AddPTR, &record.CreatedAt, &record.ModifiedAt, &record.DeletedAt, &record.ZoneUUID, &record.Staging); err != nil {
+			return nil, err
+		}
+
+		record.Tags, err = s.GetZoneTags(ctx, record.ZoneUUID)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *postgresStore) CreateRecord(ctx context.Context, r *Record) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO bind_dns.records (uuid, type, host, content, ttl, add_ptr, created_at, modified_at, zone_uuid, staging) VALUES ($1, $2, $3, $4, $5, $6, $7, $7, $8, TRUE)"
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	timeNow := time.Now()
+	r.CreatedAt = timeNow
+	r.ModifiedAt = timeNow
+	result, err := stmt.ExecContext(ctx, r.UUID, r.Type, r.Host, r.Content, r.TTL, r.AddPTR, timeNow, r.ZoneUUID)
+	if err != nil {
+		return err
+	}
+
+	// Check if any rows were inserted
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := replaceRecordTags(ctx, tx, r.UUID, r.Tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) FindRecord(ctx context.Context, r *Record) error {
+	query := "SELECT type, host, content, ttl, add_ptr, created_at, modified_at, deleted_at, zone_uuid, staging FROM bind_dns.records WHERE uuid::text = $1 AND (deleted_at IS NULL OR staging = TRUE)"
+	row := s.db.QueryRow(query, r.UUID)
+	err := row.Scan(&r.Type, &r.Host, &r.Content, &r.TTL, &r.AddPTR, &r.CreatedAt, &r.ModifiedAt, &r.DeletedAt, &r.ZoneUUID, &r.Staging)
+	if err != nil {
+		return err
+	}
+
+	r.Tags, err = s.GetZoneTags(ctx, r.ZoneUUID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) UpdateRecord(ctx context.Context, r *Record) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE bind_dns.records SET type = $1, host = $2, content = $3, ttl = $4, add_ptr = $5, created_at = $6, modified_at = $7, staging = TRUE WHERE uuid::text = $8"
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	// check if record is new
+	if r.Staging && r.CreatedAt == r.ModifiedAt {
+		r.ModifiedAt = time.Now()
+		r.CreatedAt = r.ModifiedAt
+	} else {
+		r.ModifiedAt = time.Now()
+	}
+
+	result, err := stmt.ExecContext(ctx, r.Type, r.Host, r.Content, r.TTL, r.AddPTR, r.CreatedAt, r.ModifiedAt, r.UUID)
+	if err != nil {
+		return err
+	}
+
+	// Log the output
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := replaceRecordTags(ctx, tx, r.UUID, r.Tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) DeleteRecord(ctx context.Context, r *Record) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE bind_dns.records SET deleted_at = $1, staging = TRUE WHERE uuid::text = $2"
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, time.Now(), r.UUID)
+	if err != nil {
+		return err
+	}
+
+	// Log the output
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) CommitRecords(ctx context.Context, zoneUUID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	//Check for any rows to commit
+	query := "SELECT COUNT(*) FROM bind_dns.records WHERE staging = TRUE AND ($1 = '' OR zone_uuid::text = $1)"
+	row := tx.QueryRowContext(ctx, query, zoneUUID)
+	var count int
+	err = row.Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	// Apply changes
+	query = "UPDATE bind_dns.records SET staging = FALSE WHERE staging = TRUE AND ($1 = '' OR zone_uuid::text = $1)"
+	result, err := tx.ExecContext(ctx, query, zoneUUID)
+	if err != nil {
+		return err
+	}
+
+	// Check for any rows affected
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != int64(count) {
+		return fmt.Errorf("expected %d rows affected, got %d", count, rowsAffected)
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) GetStagingRecords(ctx context.Context) ([]Record, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT uuid, type, host, content, ttl, add_ptr, created_at, modified_at, deleted_at, zone_uuid FROM bind_dns.records WHERE staging = TRUE"
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		err := rows.Scan(&record.UUID, &record.Type, &record.Host, &record.Content, &record.TTL, &record.AddPTR, &record.CreatedAt, &record.ModifiedAt, &record.DeletedAt, &record.ZoneUUID)
+		if err != nil {
+			return nil, err
+		}
+		record.Staging = true
+		record.Tags, err = s.GetZoneTags(ctx, record.ZoneUUID)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *postgresStore) DiscardRecords(ctx context.Context, zoneUUID, uuid string) ([]Record, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT uuid, type, host, content, ttl, add_ptr, created_at, modified_at, deleted_at, zone_uuid, staging FROM bind_dns.records WHERE staging = TRUE AND ($1 = '' OR zone_uuid::text = $1) AND ($2 = '' OR uuid::text = $2) FOR UPDATE"
+	rows, err := tx.QueryContext(ctx, query, zoneUUID, uuid)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for rows.Next() {
+		var record Record
+		if err := rows.Scan(&record.UUID, &record.Type, &record.Host, &record.Content, &record.TTL, &record.AddPTR, &record.CreatedAt, &record.ModifiedAt, &record.DeletedAt, &record.ZoneUUID, &record.Staging); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, record := range records {
+		// the last committed version is kept by the history triggers
+		var v RecordVersion
+		row := tx.QueryRowContext(ctx, "SELECT "+recordVersionColumns+" FROM bind_dns.record_versions WHERE uuid::text = $1 AND staging = FALSE ORDER BY changed_at DESC, version DESC LIMIT 1", record.UUID)
+		switch err := scanRecordVersion(row, &v); err {
+		case nil:
+			c := v.Record
+			_, err = tx.ExecContext(ctx, "UPDATE bind_dns.records SET type = $1, host = $2, content = $3, ttl = $4, add_ptr = $5, created_at = $6, modified_at = $7, deleted_at = $8, staging = FALSE WHERE uuid::text = $9",
+				c.Type, c.Host, c.Content, c.TTL, c.AddPTR, c.CreatedAt, c.ModifiedAt, c.DeletedAt, record.UUID)
+			if err != nil {
+				return nil, err
+			}
+		case sql.ErrNoRows:
+			// created in staging, delete it as committed
+			_, err = tx.ExecContext(ctx, "UPDATE bind_dns.records SET deleted_at = COALESCE(deleted_at, $1), staging = FALSE WHERE uuid::text = $2", time.Now(), record.UUID)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+
+		records[i].Tags, err = s.GetZoneTags(ctx, record.ZoneUUID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, tx.Commit()
+}
+
+func (s *postgresStore) StageRecord(ctx context.Context, r *Record) error {
+	r.ModifiedAt = time.Now()
+	result, err := s.db.ExecContext(ctx, "UPDATE bind_dns.records SET type = $1, host = $2, content = $3, ttl = $4, add_ptr = $5, modified_at = $6, deleted_at = $7, staging = TRUE WHERE uuid::text = $8",
+		r.Type, r.Host, r.Content, r.TTL, r.AddPTR, r.ModifiedAt, r.DeletedAt, r.UUID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
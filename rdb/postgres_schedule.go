@@ -0,0 +1,112 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const scheduledApplyColumns = "id, kind, change_request_id, apply_at, deploy, status, created_by, created_by_id, created_at, started_at, finished_at, error, changeset_id, owner, heartbeat_at"
+
+func scanScheduledApply(row interface{ Scan(...interface{}) error }, a *ScheduledApply) error {
+	var request, changeset sql.NullInt64
+	if err := row.Scan(&a.ID, &a.Kind, &request, &a.ApplyAt, &a.Deploy, &a.Status, &a.CreatedBy, &a.CreatedByID, &a.CreatedAt, &a.StartedAt, &a.FinishedAt, &a.Error, &changeset, &a.Owner, &a.HeartbeatAt); err != nil {
+		return err
+	}
+	a.ChangeRequestID = request.Int64
+	a.ChangesetID = changeset.Int64
+	return nil
+}
+
+func (s *postgresStore) GetScheduledApplies(ctx context.Context, status string) ([]ScheduledApply, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+scheduledApplyColumns+" FROM bind_dns.scheduled_applies WHERE $1 = '' OR status = $1 ORDER BY apply_at, id", status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applies := []ScheduledApply{}
+	for rows.Next() {
+		var a ScheduledApply
+		if err := scanScheduledApply(rows, &a); err != nil {
+			return nil, err
+		}
+		applies = append(applies, a)
+	}
+	return applies, rows.Err()
+}
+
+func (s *postgresStore) FindScheduledApply(ctx context.Context, a *ScheduledApply) error {
+	row := s.db.QueryRowContext(ctx, "SELECT "+scheduledApplyColumns+" FROM bind_dns.scheduled_applies WHERE id = $1", a.ID)
+	return scanScheduledApply(row, a)
+}
+
+func (s *postgresStore) CreateScheduledApply(ctx context.Context, a *ScheduledApply) error {
+	a.Status = SchedulePending
+	a.CreatedAt = time.Now()
+	row := s.db.QueryRowContext(ctx, "INSERT INTO bind_dns.scheduled_applies (kind, change_request_id, apply_at, deploy, status, created_by, created_by_id, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		a.Kind, sql.NullInt64{Int64: a.ChangeRequestID, Valid: a.ChangeRequestID != 0}, a.ApplyAt, a.Deploy, a.Status, a.CreatedBy, a.CreatedByID, a.CreatedAt)
+	return row.Scan(&a.ID)
+}
+
+func (s *postgresStore) UpdateScheduledApply(ctx context.Context, a *ScheduledApply) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE bind_dns.scheduled_applies SET status = $1, started_at = $2, finished_at = $3, error = $4, changeset_id = $5 WHERE id = $6",
+		a.Status, a.StartedAt, a.FinishedAt, a.Error, sql.NullInt64{Int64: a.ChangesetID, Valid: a.ChangesetID != 0}, a.ID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *postgresStore) CancelScheduledApply(ctx context.Context, a *ScheduledApply) error {
+	row := s.db.QueryRowContext(ctx, "UPDATE bind_dns.scheduled_applies SET status = $1, finished_at = NOW() WHERE id = $2 AND status = $3 RETURNING "+scheduledApplyColumns,
+		ScheduleCancelled, a.ID, SchedulePending)
+	return scanScheduledApply(row, a)
+}
+
+// ClaimScheduledApply skips rows locked by another server claiming them.
+func (s *postgresStore) ClaimScheduledApply(ctx context.Context, a *ScheduledApply, now time.Time) error {
+	row := s.db.QueryRowContext(ctx, `UPDATE bind_dns.scheduled_applies SET status = $1, started_at = NOW(), owner = $4, heartbeat_at = NOW()
+		WHERE id = (
+			SELECT id FROM bind_dns.scheduled_applies
+			WHERE status = $2 AND apply_at <= $3
+			ORDER BY apply_at, id LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		) RETURNING `+scheduledApplyColumns, ScheduleRunning, SchedulePending, now, a.Owner)
+	return scanScheduledApply(row, a)
+}
+
+func (s *postgresStore) RenewScheduledApply(ctx context.Context, a *ScheduledApply) error {
+	row := s.db.QueryRowContext(ctx, "UPDATE bind_dns.scheduled_applies SET heartbeat_at = NOW() WHERE id = $1 AND status = $2 AND owner = $3 RETURNING heartbeat_at",
+		a.ID, ScheduleRunning, a.Owner)
+	return row.Scan(&a.HeartbeatAt)
+}
+
+// FailStaleScheduledApplies compares leases with the clock of the database,
+// which every server shares.
+func (s *postgresStore) FailStaleScheduledApplies(ctx context.Context, owner string, lease time.Duration, reason string) ([]ScheduledApply, error) {
+	rows, err := s.db.QueryContext(ctx, `UPDATE bind_dns.scheduled_applies SET status = $1, finished_at = NOW(), error = $2
+		WHERE status = $3 AND (($4 <> '' AND owner = $4) OR heartbeat_at IS NULL OR heartbeat_at < NOW() - make_interval(secs => $5))
+		RETURNING `+scheduledApplyColumns, ScheduleFailed, reason, ScheduleRunning, owner, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failed := []ScheduledApply{}
+	for rows.Next() {
+		var a ScheduledApply
+		if err := scanScheduledApply(rows, &a); err != nil {
+			return nil, err
+		}
+		failed = append(failed, a)
+	}
+	return failed, rows.Err()
+}
@@ -0,0 +1,25 @@
+package rdb
+
+import (
+	"context"
+	"time"
+)
+
+func (s *postgresStore) FindSerial(ctx context.Context, serial *Serial) error {
+	row := s.db.QueryRowContext(ctx, "SELECT serial, content_hash, modified_at FROM bind_dns.zone_serials WHERE zone_name = $1", serial.Zone)
+
+	var value int64
+	if err := row.Scan(&value, &serial.Hash, &serial.ModifiedAt); err != nil {
+		return err
+	}
+	serial.Serial = uint32(value)
+	return nil
+}
+
+func (s *postgresStore) SaveSerial(ctx context.Context, serial *Serial) error {
+	serial.ModifiedAt = time.Now()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO bind_dns.zone_serials (zone_name, serial, content_hash, modified_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (zone_name) DO UPDATE SET serial = EXCLUDED.serial, content_hash = EXCLUDED.content_hash, modified_at = EXCLUDED.modified_at`,
+		serial.Zone, int64(serial.Serial), serial.Hash, serial.ModifiedAt)
+	return err
+}
@@ -0,0 +1,238 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (s *postgresStore) GetRecordTags(ctx context.Context, recordUUID string) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT tag FROM bind_dns.tags WHERE record_uuid::text = $1", recordUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0)
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(&tag)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag.String())
+	}
+
+	return tags, nil
+}
+
+func (s *postgresStore) GetZoneTags(ctx context.Context, zoneUUID string) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT tag FROM bind_dns.tags WHERE zone_uuid::text = $1", zoneUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0)
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(&tag)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag.String())
+	}
+
+	return tags, nil
+}
+
+func (s *postgresStore) CreateRecordTag(ctx context.Context, recordUUID string, t Tag) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO bind_dns.tags (record_uuid, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx, recordUUID, t.String())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStore) CreateZoneTag(ctx context.Context, zoneUUID string, t Tag) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO bind_dns.tags (zone_uuid, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx, zoneUUID, t.String())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStore) DeleteRecordTags(ctx context.Context, recordUUID string, t Tag) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// check if record exists
+	var count int
+	query := "SELECT COUNT(*) FROM bind_dns.tags WHERE record_uuid::text = $1"
+	err = tx.QueryRowContext(ctx, query, recordUUID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTagNotFound
+	}
+
+	var result sql.Result
+	if t.String() == "" {
+		// delete all tags
+		query := "DELETE FROM bind_dns.tags WHERE record_uuid::text = $1"
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		result, err = stmt.ExecContext(ctx, recordUUID)
+		if err != nil {
+			return err
+		}
+	} else {
+		// delete specific tag
+		query := "DELETE FROM bind_dns.tags WHERE record_uuid::text = $1 AND tag = $2"
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		result, err = stmt.ExecContext(ctx, recordUUID, t.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Log the output
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStore) DeleteZoneTags(ctx context.Context, zoneUUID string, t Tag) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// check if zone exists
+	var count int
+	query := "SELECT COUNT(*) FROM bind_dns.tags WHERE zone_uuid::text = $1"
+	err = tx.QueryRowContext(ctx, query, zoneUUID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTagNotFound
+	}
+
+	var result sql.Result
+	if t.String() == "" {
+		// delete all tags
+		query := "DELETE FROM bind_dns.tags WHERE zone_uuid::text = $1"
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		result, err = stmt.ExecContext(ctx, zoneUUID)
+		if err != nil {
+			return err
+		}
+	} else {
+		// delete specific tag
+		query := "DELETE FROM bind_dns.tags WHERE zone_uuid::text = $1 AND tag = $2"
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		result, err = stmt.ExecContext(ctx, zoneUUID, t.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Log the output
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// replaceZoneTags replaces the tags of the zone within tx, the transaction
+// writing the zone: a tag of a zone not committed yet fails its foreign key
+// from any other transaction.
+func replaceZoneTags(ctx context.Context, tx *sql.Tx, zoneUUID string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM bind_dns.tags WHERE zone_uuid = $1", zoneUUID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO bind_dns.tags (zone_uuid, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING", zoneUUID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceRecordTags replaces the tags of the record within tx, see
+// replaceZoneTags.
+func replaceRecordTags(ctx context.Context, tx *sql.Tx, recordUUID string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM bind_dns.tags WHERE record_uuid = $1", recordUUID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO bind_dns.tags (record_uuid, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING", recordUUID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
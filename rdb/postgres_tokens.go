@@ -0,0 +1,69 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const tokenColumns = "uuid, name, token_hash, role, scope, created_at, expires_at, last_used_at, revoked_at"
+
+func scanToken(row interface{ Scan(...interface{}) error }, t *Token) error {
+	return row.Scan(&t.UUID, &t.Name, &t.Hash, &t.Role, &t.Scope, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt, &t.RevokedAt)
+}
+
+func (s *postgresStore) GetTokens(ctx context.Context) ([]Token, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+tokenColumns+" FROM bind_dns.api_tokens ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []Token{}
+	for rows.Next() {
+		var token Token
+		if err := scanToken(rows, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *postgresStore) FindToken(ctx context.Context, t *Token) error {
+	row := s.db.QueryRowContext(ctx, "SELECT "+tokenColumns+" FROM bind_dns.api_tokens WHERE uuid = $1", t.UUID)
+	return scanToken(row, t)
+}
+
+func (s *postgresStore) FindTokenByHash(ctx context.Context, t *Token) error {
+	row := s.db.QueryRowContext(ctx, "SELECT "+tokenColumns+" FROM bind_dns.api_tokens WHERE token_hash = $1", t.Hash)
+	return scanToken(row, t)
+}
+
+func (s *postgresStore) CreateToken(ctx context.Context, t *Token) error {
+	t.CreatedAt = time.Now()
+	_, err := s.db.ExecContext(ctx, "INSERT INTO bind_dns.api_tokens (uuid, name, token_hash, role, scope, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		t.UUID, t.Name, t.Hash, t.Role, t.Scope, t.CreatedAt, t.ExpiresAt)
+	return err
+}
+
+func (s *postgresStore) RevokeToken(ctx context.Context, t *Token) error {
+	row := s.db.QueryRowContext(ctx, "UPDATE bind_dns.api_tokens SET revoked_at = $1 WHERE uuid = $2 AND revoked_at IS NULL RETURNING "+tokenColumns, time.Now(), t.UUID)
+	return scanToken(row, t)
+}
+
+func (s *postgresStore) TouchToken(ctx context.Context, t *Token, at time.Time) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE bind_dns.api_tokens SET last_used_at = $1 WHERE uuid = $2", at, t.UUID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	t.LastUsedAt = sql.NullTime{Time: at, Valid: true}
+	return nil
+}
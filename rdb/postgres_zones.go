@@ -0,0 +1,276 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *postgresStore) GetZones(ctx context.Context) ([]Zone, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT uuid, name, created_at, modified_at, deleted_at, primary_ns, admin_email, refresh, retry, expire, minimum, ttl, name_servers, staging FROM bind_dns.zones WHERE deleted_at IS NULL OR staging = TRUE")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var zones []Zone
+	for rows.Next() {
+		var zone Zone
+		err := rows.Scan(&zone.UUID, &zone.Name, &zone.CreatedAt, &zone.ModifiedAt, &zone.DeletedAt, &zone.PrimaryNS, &zone.AdminEmail, &zone.Refresh, &zone.Retry, &zone.Expire, &zone.Minimum, &zone.TTL, &zone.NameServers, &zone.Staging)
+		if err != nil {
+			return nil, err
+		}
+
+		// get tags
+		zone.Tags, err = s.GetZoneTags(ctx, zone.UUID)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+
+	return zones, nil
+}
+
+func (s *postgresStore) CreateZone(ctx context.Context, z *Zone) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO bind_dns.zones (uuid, name, created_at, modified_at, deleted_at, primary_ns, admin_email, refresh, retry, expire, minimum, ttl, name_servers, staging) VALUES ($1, $2, $3, $3, NULL, $4, $5, $6, $7, $8, $9, $10, $11, TRUE)"
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	timeNow := time.Now()
+	z.CreatedAt = timeNow
+	z.ModifiedAt = timeNow
+	_, err = stmt.ExecContext(ctx, z.UUID, z.Name, timeNow, z.PrimaryNS, z.AdminEmail, z.Refresh, z.Retry, z.Expire, z.Minimum, z.TTL, z.NameServers)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceZoneTags(ctx, tx, z.UUID, z.Tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) UpdateZone(ctx context.Context, z *Zone) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE bind_dns.zones SET name = $1, primary_ns = $2, admin_email = $3, refresh = $4, retry = $5, expire = $6, minimum = $7, ttl = $8, name_servers = $9, staging = TRUE WHERE uuid = $10"
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, z.Name, z.PrimaryNS, z.AdminEmail, z.Refresh, z.Retry, z.Expire, z.Minimum, z.TTL, z.NameServers, z.UUID)
+	if err != nil {
+		return err
+	}
+
+	// Log the output
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := replaceZoneTags(ctx, tx, z.UUID, z.Tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) DeleteZone(ctx context.Context, z *Zone) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE bind_dns.zones SET deleted_at = $1, staging = TRUE WHERE uuid = $2"
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, time.Now(), z.UUID)
+	if err != nil {
+		return err
+	}
+
+	// Log the output
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) FindZone(ctx context.Context, z *Zone) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT uuid, name, created_at, modified_at, deleted_at, primary_ns, admin_email, refresh, retry, expire, minimum, ttl, name_servers, staging FROM bind_dns.zones WHERE uuid = $1 AND (deleted_at IS NULL OR (deleted_at IS NOT NULL AND staging = TRUE))"
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowContext(ctx, z.UUID)
+	err = row.Scan(&z.UUID, &z.Name, &z.CreatedAt, &z.ModifiedAt, &z.DeletedAt, &z.PrimaryNS, &z.AdminEmail, &z.Refresh, &z.Retry, &z.Expire, &z.Minimum, &z.TTL, &z.NameServers, &z.Staging)
+	if err != nil {
+		return err
+	}
+
+	// get tags
+	z.Tags, err = s.GetZoneTags(ctx, z.UUID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *postgresStore) GetStagingZones(ctx context.Context) ([]Zone, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT uuid, name, created_at, modified_at, deleted_at, primary_ns, admin_email, refresh, retry, expire, minimum, ttl, name_servers, staging FROM bind_dns.zones WHERE staging = TRUE")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var zones []Zone
+	for rows.Next() {
+		var zone Zone
+		err := rows.Scan(&zone.UUID, &zone.Name, &zone.CreatedAt, &zone.ModifiedAt, &zone.DeletedAt, &zone.PrimaryNS, &zone.AdminEmail, &zone.Refresh, &zone.Retry, &zone.Expire, &zone.Minimum, &zone.TTL, &zone.NameServers, &zone.Staging)
+		if err != nil {
+			return nil, err
+		}
+
+		// get tags
+		zone.Tags, err = s.GetZoneTags(ctx, zone.UUID)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+
+	return zones, nil
+}
+
+func (s *postgresStore) CommitZones(ctx context.Context, uuid string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE bind_dns.zones SET staging = FALSE WHERE staging = TRUE AND ($1 = '' OR uuid::text = $1)", uuid)
+	return err
+}
+
+func (s *postgresStore) DiscardZones(ctx context.Context, uuid string) ([]Zone, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	const columns = "uuid, name, created_at, modified_at, deleted_at, primary_ns, admin_email, refresh, retry, expire, minimum, ttl, name_servers, staging"
+	rows, err := tx.QueryContext(ctx, "SELECT "+columns+" FROM bind_dns.zones WHERE staging = TRUE AND ($1 = '' OR uuid::text = $1) FOR UPDATE", uuid)
+	if err != nil {
+		return nil, err
+	}
+	var zones []Zone
+	for rows.Next() {
+		var zone Zone
+		if err := rows.Scan(&zone.UUID, &zone.Name, &zone.CreatedAt, &zone.ModifiedAt, &zone.DeletedAt, &zone.PrimaryNS, &zone.AdminEmail, &zone.Refresh, &zone.Retry, &zone.Expire, &zone.Minimum, &zone.TTL, &zone.NameServers, &zone.Staging); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, zone := range zones {
+		// the last committed version is kept by the history triggers
+		var c Zone
+		row := tx.QueryRowContext(ctx, "SELECT "+columns+" FROM bind_dns.zone_versions WHERE uuid::text = $1 AND staging = FALSE ORDER BY changed_at DESC, version DESC LIMIT 1", zone.UUID)
+		err := row.Scan(&c.UUID, &c.Name, &c.CreatedAt, &c.ModifiedAt, &c.DeletedAt, &c.PrimaryNS, &c.AdminEmail, &c.Refresh, &c.Retry, &c.Expire, &c.Minimum, &c.TTL, &c.NameServers, &c.Staging)
+		switch err {
+		case nil:
+			_, err = tx.ExecContext(ctx, "UPDATE bind_dns.zones SET name = $1, created_at = $2, modified_at = $3, deleted_at = $4, primary_ns = $5, admin_email = $6, refresh = $7, retry = $8, expire = $9, minimum = $10, ttl = $11, name_servers = $12, staging = FALSE WHERE uuid = $13",
+				c.Name, c.CreatedAt, c.ModifiedAt, c.DeletedAt, c.PrimaryNS, c.AdminEmail, c.Refresh, c.Retry, c.Expire, c.Minimum, c.TTL, c.NameServers, zone.UUID)
+			if err != nil {
+				return nil, err
+			}
+		case sql.ErrNoRows:
+			// created in staging, delete it as committed
+			_, err = tx.ExecContext(ctx, "UPDATE bind_dns.zones SET deleted_at = COALESCE(deleted_at, $1), staging = FALSE WHERE uuid = $2", time.Now(), zone.UUID)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+
+		zones[i].Tags, err = s.GetZoneTags(ctx, zone.UUID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return zones, tx.Commit()
+}
+
+func (s *postgresStore) StageZone(ctx context.Context, z *Zone) error {
+	z.ModifiedAt = time.Now()
+	result, err := s.db.ExecContext(ctx, "UPDATE bind_dns.zones SET name = $1, primary_ns = $2, admin_email = $3, refresh = $4, retry = $5, expire = $6, minimum = $7, ttl = $8, name_servers = $9, modified_at = $10, deleted_at = $11, staging = TRUE WHERE uuid = $12",
+		z.Name, z.PrimaryNS, z.AdminEmail, z.Refresh, z.Retry, z.Expire, z.Minimum, z.TTL, z.NameServers, z.ModifiedAt, z.DeletedAt, z.UUID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -1,46 +1,51 @@
 package rdb
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
-
-	_ "github.com/lib/pq"
 )
 
-var db *sql.DB
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
 
 type DBConfig struct {
+	Driver   string // Storage backend, DriverPostgres or DriverMemory
 	Host     string
 	Port     int
 	User     string
 	Password string
 	DBName   string
+	Path     string // Snapshot file for the memory backend, empty to keep nothing on disk
 }
 
 func Init(config DBConfig) {
-
-	// Connect to the database
-	if err := connect(config.Host, config.Port, config.User, config.Password, config.DBName, "disable"); err != nil {
-		log.Fatal(err)
-	}
-
-	// Test the connection
-	if err := db.Ping(); err != nil {
+	s, err := Open(config)
+	if err != nil {
 		log.Fatal(err)
 	}
-	log.Printf("Connected to the database successfully.\n")
+	Use(s)
+	log.Printf("Connected to the %s database successfully.\n", config.Driver)
 }
 
-// Connect establishes a connection to the database
-func connect(host string, port int, user, password, dbname string, sslmode string) error {
-	dbinfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslmode)
-
-	postgres, err := sql.Open("postgres", dbinfo)
-	if err != nil {
-		return err
+// Open creates the storage backend selected by config.Driver.
+func Open(config DBConfig) (Store, error) {
+	switch config.Driver {
+	case DriverPostgres, "":
+		s, err := newPostgresStore(config.Host, config.Port, config.User, config.Password, config.DBName, "disable")
+		if err != nil {
+			return nil, err
+		}
+
+		// Test the connection
+		if err := s.db.Ping(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case DriverMemory:
+		return NewMemoryStore(config.Path)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", config.Driver)
 	}
-	db = postgres
-	return nil
 }
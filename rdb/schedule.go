@@ -0,0 +1,98 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Scheduled apply statuses. Pending applies are claimed once they are due,
+// after which they succeed or fail. Pending applies can be cancelled.
+const (
+	ScheduleKindStaging       = "staging"
+	ScheduleKindChangeRequest = "change_request"
+
+	SchedulePending   = "pending"
+	ScheduleRunning   = "running"
+	ScheduleSucceeded = "succeeded"
+	ScheduleFailed    = "failed"
+	ScheduleCancelled = "cancelled"
+)
+
+// ScheduledApply is an apply of the staged changes, or of a change request,
+// that runs at a given time.
+type ScheduledApply struct {
+	ID              int64        // Sequence number
+	Kind            string       // ScheduleKindStaging or ScheduleKindChangeRequest
+	ChangeRequestID int64        // Change request to apply, for ScheduleKindChangeRequest
+	ApplyAt         time.Time    // When to apply
+	Deploy          bool         // Deploy the configuration after applying
+	Status          string       // One of the Schedule statuses
+	CreatedBy       string       // Name of the token or user that scheduled it
+	CreatedByID     string       // UUID of the token or OIDC subject
+	CreatedAt       time.Time    // Time it was scheduled
+	StartedAt       sql.NullTime // Time it was claimed
+	FinishedAt      sql.NullTime // Time it succeeded, failed or was cancelled
+	Error           string       // Why it failed
+	ChangesetID     int64        // Changeset created by the apply, 0 until then
+	Owner           string       // API server running it, set on claim
+	HeartbeatAt     sql.NullTime // Time its owner last renewed its lease on it
+}
+
+// Get retrieves the scheduled applies with the status of s, all of them
+// when it is empty, in the order they are due.
+func (s *ScheduledApply) Get(ctx context.Context) ([]ScheduledApply, error) {
+	return store.GetScheduledApplies(ctx, s.Status)
+}
+
+// Find retrieves the scheduled apply with the ID of s.
+//
+// Returns sql.ErrNoRows when there is no such scheduled apply.
+func (s *ScheduledApply) Find(ctx context.Context) error {
+	return store.FindScheduledApply(ctx, s)
+}
+
+// Create stores a new pending scheduled apply, setting its ID.
+func (s *ScheduledApply) Create(ctx context.Context) error {
+	return store.CreateScheduledApply(ctx, s)
+}
+
+// Update stores the status, times, error and changeset of s.
+//
+// Returns sql.ErrNoRows when there is no such scheduled apply.
+func (s *ScheduledApply) Update(ctx context.Context) error {
+	return store.UpdateScheduledApply(ctx, s)
+}
+
+// Cancel cancels the pending scheduled apply with the ID of s.
+//
+// Returns sql.ErrNoRows when there is no such apply or it is no longer
+// pending.
+func (s *ScheduledApply) Cancel(ctx context.Context) error {
+	return store.CancelScheduledApply(ctx, s)
+}
+
+// ClaimDue marks the earliest pending apply due at now as running, leased
+// to the owner of s, and returns it in s. Each apply is claimed once, even
+// with several API servers sharing the database.
+//
+// Returns sql.ErrNoRows when nothing is due.
+func (s *ScheduledApply) ClaimDue(ctx context.Context, now time.Time) error {
+	return store.ClaimScheduledApply(ctx, s, now)
+}
+
+// Renew renews the lease of the owner of s on the running apply with the
+// ID of s.
+//
+// Returns sql.ErrNoRows when the apply is no longer running or owned by it.
+func (s *ScheduledApply) Renew(ctx context.Context) error {
+	return store.RenewScheduledApply(ctx, s)
+}
+
+// FailStale marks failed with the error of s the running applies whose
+// lease was last renewed more than lease ago, and those of the owner of s
+// when it is set, as their server stopped running them. It returns the
+// applies it failed.
+func (s *ScheduledApply) FailStale(ctx context.Context, lease time.Duration) ([]ScheduledApply, error) {
+	return store.FailStaleScheduledApplies(ctx, s.Owner, lease, s.Error)
+}
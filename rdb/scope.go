@@ -0,0 +1,127 @@
+package rdb
+
+import "context"
+
+type stagingScopeKey struct{}
+
+// WithStagingScope returns a context through which Zone.Get, Zone.GetStaging,
+// Record.Get, Record.GetAll and Record.GetStaging only see the staged changes
+// of the zones of zoneUUIDs. Zones and records of other zones are seen as
+// they were last committed, so that a change request can be rendered and
+// applied without what others staged meanwhile.
+func WithStagingScope(ctx context.Context, zoneUUIDs []string) context.Context {
+	scope := make(map[string]bool, len(zoneUUIDs))
+	for _, zoneUUID := range zoneUUIDs {
+		scope[zoneUUID] = true
+	}
+	return context.WithValue(ctx, stagingScopeKey{}, scope)
+}
+
+// StagingScope returns the zones ctx limits staged changes to, and false
+// when it does not limit them.
+func StagingScope(ctx context.Context) ([]string, bool) {
+	scope, ok := ctx.Value(stagingScopeKey{}).(map[string]bool)
+	if !ok {
+		return nil, false
+	}
+	zoneUUIDs := make([]string, 0, len(scope))
+	for zoneUUID := range scope {
+		zoneUUIDs = append(zoneUUIDs, zoneUUID)
+	}
+	return zoneUUIDs, true
+}
+
+// scopeZones replaces the staged zones outside the staging scope of ctx by
+// their last committed version, leaving out those never committed or
+// deleted when committed.
+func scopeZones(ctx context.Context, zones []Zone) ([]Zone, error) {
+	scope, ok := ctx.Value(stagingScopeKey{}).(map[string]bool)
+	if !ok {
+		return zones, nil
+	}
+
+	scoped := zones[:0:0]
+	for _, zone := range zones {
+		if !zone.Staging || scope[zone.UUID] {
+			scoped = append(scoped, zone)
+			continue
+		}
+		versions, err := store.GetZoneHistory(ctx, zone.UUID)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(versions) - 1; i >= 0; i-- {
+			if committed := versions[i].Zone; !committed.Staging {
+				if !committed.DeletedAt.Valid {
+					committed.Tags = zone.Tags
+					scoped = append(scoped, committed)
+				}
+				break
+			}
+		}
+	}
+	return scoped, nil
+}
+
+// scopeRecords is scopeZones for records, which are in scope with their
+// zone.
+func scopeRecords(ctx context.Context, records []Record) ([]Record, error) {
+	scope, ok := ctx.Value(stagingScopeKey{}).(map[string]bool)
+	if !ok {
+		return records, nil
+	}
+
+	scoped := records[:0:0]
+	for _, record := range records {
+		if !record.Staging || scope[record.ZoneUUID] {
+			scoped = append(scoped, record)
+			continue
+		}
+		versions, err := store.GetRecordHistory(ctx, record.UUID)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(versions) - 1; i >= 0; i-- {
+			if committed := versions[i].Record; !committed.Staging {
+				if !committed.DeletedAt.Valid {
+					committed.Tags = record.Tags
+					scoped = append(scoped, committed)
+				}
+				break
+			}
+		}
+	}
+	return scoped, nil
+}
+
+// scopeStagingZones leaves out the staged zones outside the staging scope of
+// ctx.
+func scopeStagingZones(ctx context.Context, zones []Zone) []Zone {
+	scope, ok := ctx.Value(stagingScopeKey{}).(map[string]bool)
+	if !ok {
+		return zones
+	}
+	scoped := zones[:0:0]
+	for _, zone := range zones {
+		if scope[zone.UUID] {
+			scoped = append(scoped, zone)
+		}
+	}
+	return scoped
+}
+
+// scopeStagingRecords leaves out the staged records outside the staging
+// scope of ctx.
+func scopeStagingRecords(ctx context.Context, records []Record) []Record {
+	scope, ok := ctx.Value(stagingScopeKey{}).(map[string]bool)
+	if !ok {
+		return records
+	}
+	scoped := records[:0:0]
+	for _, record := range records {
+		if scope[record.ZoneUUID] {
+			scoped = append(scoped, record)
+		}
+	}
+	return scoped
+}
@@ -0,0 +1,30 @@
+package rdb
+
+import (
+	"context"
+	"time"
+)
+
+// Serial is the SOA serial of a rendered zone, along with a hash of the
+// content it was issued for, so it only changes when the zone does.
+//
+// Serials are keyed by zone name since generated reverse zones have no
+// zone row of their own.
+type Serial struct {
+	Zone       string    // Zone name
+	Serial     uint32    // Last issued SOA serial
+	Hash       string    // Hash of the zone content the serial was issued for
+	ModifiedAt time.Time // Time the serial was last issued
+}
+
+// Find retrieves the serial of the zone.
+//
+// Returns sql.ErrNoRows if the zone has never been rendered.
+func (s *Serial) Find(ctx context.Context) error {
+	return store.FindSerial(ctx, s)
+}
+
+// Save creates or replaces the serial of the zone.
+func (s *Serial) Save(ctx context.Context) error {
+	return store.SaveSerial(ctx, s)
+}
@@ -0,0 +1,193 @@
+package rdb
+
+import (
+	"context"
+	"time"
+)
+
+// Store is the storage backend behind the rdb types.
+//
+// The Zone, Record, Tag and Config methods delegate to the Store selected
+// with Init or Use, so handlers never talk to a database directly.
+type Store interface {
+	ZoneStore
+	RecordStore
+	TagStore
+	ConfigStore
+	SerialStore
+	TokenStore
+	AuditStore
+	HistoryStore
+	ChangesetStore
+	ChangeRequestStore
+	ScheduleStore
+	DeployJobStore
+	PipelineLocker
+
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// ZoneStore persists zones and their SOA data.
+type ZoneStore interface {
+	GetZones(ctx context.Context) ([]Zone, error)
+	GetStagingZones(ctx context.Context) ([]Zone, error)
+	FindZone(ctx context.Context, z *Zone) error
+	CreateZone(ctx context.Context, z *Zone) error
+	UpdateZone(ctx context.Context, z *Zone) error
+	DeleteZone(ctx context.Context, z *Zone) error
+	// CommitZones commits the staged zones, all of them when uuid is empty.
+	CommitZones(ctx context.Context, uuid string) error
+	// DiscardZones reverts the staged zones, all of them when uuid is empty.
+	DiscardZones(ctx context.Context, uuid string) ([]Zone, error)
+	// StageZone stages z as given, including its deletion time.
+	StageZone(ctx context.Context, z *Zone) error
+}
+
+// RecordStore persists the resource records of zones.
+type RecordStore interface {
+	GetRecords(ctx context.Context, zoneUUID string) ([]Record, error)
+	GetAllRecords(ctx context.Context) ([]Record, error)
+	GetStagingRecords(ctx context.Context) ([]Record, error)
+	FindRecord(ctx context.Context, r *Record) error
+	CreateRecord(ctx context.Context, r *Record) error
+	UpdateRecord(ctx context.Context, r *Record) error
+	DeleteRecord(ctx context.Context, r *Record) error
+	// CommitRecords commits the staged records of zoneUUID, all of them
+	// when it is empty.
+	CommitRecords(ctx context.Context, zoneUUID string) error
+	// DiscardRecords reverts the staged records of zoneUUID, or the single
+	// record uuid. Empty arguments do not limit the records discarded.
+	DiscardRecords(ctx context.Context, zoneUUID, uuid string) ([]Record, error)
+	// StageRecord stages r as given, including its deletion time.
+	StageRecord(ctx context.Context, r *Record) error
+}
+
+// TagStore persists the tags attached to zones and records.
+//
+// An empty tag passed to the delete methods removes every tag of the object.
+type TagStore interface {
+	GetRecordTags(ctx context.Context, recordUUID string) ([]string, error)
+	GetZoneTags(ctx context.Context, zoneUUID string) ([]string, error)
+	CreateRecordTag(ctx context.Context, recordUUID string, tag Tag) error
+	CreateZoneTag(ctx context.Context, zoneUUID string, tag Tag) error
+	DeleteRecordTags(ctx context.Context, recordUUID string, tag Tag) error
+	DeleteZoneTags(ctx context.Context, zoneUUID string, tag Tag) error
+}
+
+// ConfigStore persists key/value configuration entries.
+type ConfigStore interface {
+	GetConfigs(ctx context.Context) ([]Config, error)
+	FindConfigs(ctx context.Context, key string) ([]Config, error)
+	CreateConfig(ctx context.Context, c *Config) error
+	UpdateConfig(ctx context.Context, c *Config, value string) error
+	DeleteConfig(ctx context.Context, c *Config) error
+	DeleteConfigValue(ctx context.Context, c *Config) error
+}
+
+// SerialStore persists the SOA serial last rendered for each zone.
+type SerialStore interface {
+	// FindSerial returns sql.ErrNoRows when the zone was never rendered.
+	FindSerial(ctx context.Context, s *Serial) error
+	SaveSerial(ctx context.Context, s *Serial) error
+}
+
+// TokenStore persists API tokens.
+type TokenStore interface {
+	GetTokens(ctx context.Context) ([]Token, error)
+	// FindToken and FindTokenByHash return sql.ErrNoRows for unknown tokens.
+	FindToken(ctx context.Context, t *Token) error
+	FindTokenByHash(ctx context.Context, t *Token) error
+	CreateToken(ctx context.Context, t *Token) error
+	RevokeToken(ctx context.Context, t *Token) error
+	TouchToken(ctx context.Context, t *Token, at time.Time) error
+}
+
+// AuditStore persists the append-only audit log.
+type AuditStore interface {
+	AppendAudit(ctx context.Context, e *AuditEntry) error
+	GetAudit(ctx context.Context, f AuditFilter) ([]AuditEntry, error)
+}
+
+// HistoryStore retrieves earlier versions of zones and records. Stores keep
+// a version on every change without being asked to.
+type HistoryStore interface {
+	GetRecordHistory(ctx context.Context, uuid string) ([]RecordVersion, error)
+	GetRecordsAsOf(ctx context.Context, zoneUUID string, at time.Time) ([]Record, error)
+	GetZoneHistory(ctx context.Context, uuid string) ([]ZoneVersion, error)
+	// GetCommittedZonesAt and GetCommittedRecordsAt return the last committed
+	// version at time at of everything that existed then, deleted or not.
+	GetCommittedZonesAt(ctx context.Context, at time.Time) ([]Zone, error)
+	GetCommittedRecordsAt(ctx context.Context, at time.Time) ([]Record, error)
+}
+
+// ChangesetStore persists the changesets created by applying staged changes.
+type ChangesetStore interface {
+	GetChangesets(ctx context.Context) ([]Changeset, error)
+	// FindChangeset returns sql.ErrNoRows for unknown changesets.
+	FindChangeset(ctx context.Context, c *Changeset) error
+	CreateChangeset(ctx context.Context, c *Changeset) error
+}
+
+// ChangeRequestStore persists change requests.
+type ChangeRequestStore interface {
+	// GetChangeRequests returns every change request when status is empty.
+	GetChangeRequests(ctx context.Context, status string) ([]ChangeRequest, error)
+	// FindChangeRequest and UpdateChangeRequest return sql.ErrNoRows for
+	// unknown change requests.
+	FindChangeRequest(ctx context.Context, c *ChangeRequest) error
+	CreateChangeRequest(ctx context.Context, c *ChangeRequest) error
+	UpdateChangeRequest(ctx context.Context, c *ChangeRequest) error
+}
+
+// ScheduleStore persists scheduled applies.
+type ScheduleStore interface {
+	// GetScheduledApplies returns every scheduled apply when status is empty.
+	GetScheduledApplies(ctx context.Context, status string) ([]ScheduledApply, error)
+	// FindScheduledApply, UpdateScheduledApply, CancelScheduledApply,
+	// ClaimScheduledApply and RenewScheduledApply return sql.ErrNoRows when
+	// there is no such pending, due or owned apply.
+	FindScheduledApply(ctx context.Context, a *ScheduledApply) error
+	CreateScheduledApply(ctx context.Context, a *ScheduledApply) error
+	UpdateScheduledApply(ctx context.Context, a *ScheduledApply) error
+	CancelScheduledApply(ctx context.Context, a *ScheduledApply) error
+	ClaimScheduledApply(ctx context.Context, a *ScheduledApply, now time.Time) error
+	RenewScheduledApply(ctx context.Context, a *ScheduledApply) error
+	// FailStaleScheduledApplies fails the running applies of owner, when
+	// it is not empty, and those not renewed for lease.
+	FailStaleScheduledApplies(ctx context.Context, owner string, lease time.Duration, reason string) ([]ScheduledApply, error)
+}
+
+// DeployJobStore persists deploy jobs.
+type DeployJobStore interface {
+	// GetDeployJobs returns every deploy job when status is empty.
+	GetDeployJobs(ctx context.Context, status string) ([]DeployJob, error)
+	// FindDeployJob, UpdateDeployJob, ClaimDeployJob and RenewDeployJob
+	// return sql.ErrNoRows when there is no such, queued or owned job.
+	FindDeployJob(ctx context.Context, j *DeployJob) error
+	CreateDeployJob(ctx context.Context, j *DeployJob) error
+	UpdateDeployJob(ctx context.Context, j *DeployJob) error
+	ClaimDeployJob(ctx context.Context, j *DeployJob) error
+	RenewDeployJob(ctx context.Context, j *DeployJob) error
+	// FailStaleDeployJobs fails the running jobs of owner, when it is not
+	// empty, and those not renewed for lease.
+	FailStaleDeployJobs(ctx context.Context, owner string, lease time.Duration, reason string) ([]DeployJob, error)
+}
+
+// PipelineLocker holds the pipeline lock.
+type PipelineLocker interface {
+	// LockPipeline takes the lock without waiting, see PipelineLock.Lock.
+	LockPipeline(ctx context.Context, l *PipelineLock) (func(), error)
+}
+
+var store Store
+
+// Use replaces the active storage backend.
+func Use(s Store) {
+	store = s
+}
+
+// Close closes the active storage backend.
+func Close() error {
+	return store.Close()
+}
@@ -2,7 +2,6 @@ package rdb
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 )
 
@@ -15,149 +14,23 @@ func (t Tag) String() string {
 }
 
 func (t Tag) GetRecord(ctx context.Context, recordUUID string) ([]string, error) {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	rows, err := tx.QueryContext(ctx, "SELECT tag FROM bind_dns.tags WHERE record_uuid::text = $1", recordUUID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	tags := make([]string, 0)
-	for rows.Next() {
-		var tag Tag
-		err := rows.Scan(&tag)
-		if err != nil {
-			return nil, err
-		}
-		tags = append(tags, tag.String())
-	}
-
-	return tags, nil
+	return store.GetRecordTags(ctx, recordUUID)
 }
 
 func (t Tag) GetZone(ctx context.Context, zoneUUID string) ([]string, error) {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	rows, err := tx.QueryContext(ctx, "SELECT tag FROM bind_dns.tags WHERE zone_uuid::text = $1", zoneUUID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	tags := make([]string, 0)
-	for rows.Next() {
-		var tag Tag
-		err := rows.Scan(&tag)
-		if err != nil {
-			return nil, err
-		}
-		tags = append(tags, tag.String())
-	}
-
-	return tags, nil
+	return store.GetZoneTags(ctx, zoneUUID)
 }
 
 func (t Tag) CreateRecord(ctx context.Context, recordUUID string) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	query := "INSERT INTO bind_dns.tags (record_uuid, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING"
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		return err
-	}
-	_, err = stmt.ExecContext(ctx, recordUUID, t.String())
-	if err != nil {
-		return err
-	}
-	return tx.Commit()
+	return store.CreateRecordTag(ctx, recordUUID, t)
 }
 
 func (t Tag) CreateZone(ctx context.Context, zoneUUID string) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	query := "INSERT INTO bind_dns.tags (zone_uuid, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING"
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		return err
-	}
-	_, err = stmt.ExecContext(ctx, zoneUUID, t.String())
-	if err != nil {
-		return err
-	}
-	return tx.Commit()
+	return store.CreateZoneTag(ctx, zoneUUID, t)
 }
 
 func (t Tag) DeleteRecord(ctx context.Context, recordUUID string) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// check if record exists
-	var count int
-	query := "SELECT COUNT(*) FROM bind_dns.tags WHERE record_uuid::text = $1"
-	err = tx.QueryRowContext(ctx, query, recordUUID).Scan(&count)
-	if err != nil {
-		return err
-	}
-	if count == 0 {
-		return ErrTagNotFound
-	}
-
-	var result sql.Result
-	if t.String() == "" {
-		// delete all tags
-		query := "DELETE FROM bind_dns.tags WHERE record_uuid::text = $1"
-		stmt, err := tx.PrepareContext(ctx, query)
-		if err != nil {
-			return err
-		}
-		defer stmt.Close()
-		result, err = stmt.ExecContext(ctx, recordUUID)
-		if err != nil {
-			return err
-		}
-	} else {
-		// delete specific tag
-		query := "DELETE FROM bind_dns.tags WHERE record_uuid::text = $1 AND tag = $2"
-		stmt, err := tx.PrepareContext(ctx, query)
-		if err != nil {
-			return err
-		}
-		defer stmt.Close()
-		result, err = stmt.ExecContext(ctx, recordUUID, t.String())
-		if err != nil {
-			return err
-		}
-	}
-
-	// Log the output
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-	return tx.Commit()
+	return store.DeleteRecordTags(ctx, recordUUID, t)
 }
 
 // DeleteZone deletes either all tags or a specific tag for a given zone UUID.
@@ -169,58 +42,5 @@ func (t Tag) DeleteRecord(ctx context.Context, recordUUID string) error {
 // Returns:
 //   - error: An error if the deletion fails.
 func (t Tag) DeleteZone(ctx context.Context, zoneUUID string) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// check if zone exists
-	var count int
-	query := "SELECT COUNT(*) FROM bind_dns.tags WHERE zone_uuid::text = $1"
-	err = tx.QueryRowContext(ctx, query, zoneUUID).Scan(&count)
-	if err != nil {
-		return err
-	}
-	if count == 0 {
-		return ErrTagNotFound
-	}
-
-	var result sql.Result
-	if t.String() == "" {
-		// delete all tags
-		query := "DELETE FROM bind_dns.tags WHERE zone_uuid::text = $1"
-		stmt, err := tx.PrepareContext(ctx, query)
-		if err != nil {
-			return err
-		}
-		defer stmt.Close()
-		result, err = stmt.ExecContext(ctx, zoneUUID)
-		if err != nil {
-			return err
-		}
-	} else {
-		// delete specific tag
-		query := "DELETE FROM bind_dns.tags WHERE zone_uuid::text = $1 AND tag = $2"
-		stmt, err := tx.PrepareContext(ctx, query)
-		if err != nil {
-			return err
-		}
-		defer stmt.Close()
-		result, err = stmt.ExecContext(ctx, zoneUUID, t.String())
-		if err != nil {
-			return err
-		}
-	}
-
-	// Log the output
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-	return tx.Commit()
+	return store.DeleteZoneTags(ctx, zoneUUID, t)
 }
@@ -0,0 +1,108 @@
+package rdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Token is an API token. Only a hash of the secret is stored, the secret
+// itself is shown once when the token is created.
+type Token struct {
+	UUID       string       // Token UUID
+	Name       string       // Description of who or what uses the token
+	Hash       string       // SHA-256 of the secret, see HashToken
+	Role       string       // Role granted to the token, see package policy
+	Scope      TokenScope   // Zones an editor token is limited to
+	CreatedAt  time.Time    // Token creation time
+	ExpiresAt  sql.NullTime // Token expiry time, never when not valid
+	LastUsedAt sql.NullTime // Time the token last authenticated a request
+	RevokedAt  sql.NullTime // Token revocation time
+}
+
+// TokenScope limits the zones an editor token may change, stored as JSON.
+type TokenScope struct {
+	Zones []string `json:"zones,omitempty"` // Zone names or UUIDs
+	Tags  []string `json:"tags,omitempty"`  // Zone tags
+}
+
+// Value implements driver.Valuer.
+func (s TokenScope) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (s *TokenScope) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = TokenScope{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	}
+	return fmt.Errorf("cannot scan %T into TokenScope", src)
+}
+
+// HashToken returns the hash a token secret is stored and looked up by.
+//
+// Secrets are random and long enough that a plain SHA-256 is sufficient,
+// unlike passwords they cannot be guessed.
+func HashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Active reports whether the token can still authenticate requests at now.
+func (t *Token) Active(now time.Time) bool {
+	if t.RevokedAt.Valid {
+		return false
+	}
+	return !t.ExpiresAt.Valid || now.Before(t.ExpiresAt.Time)
+}
+
+// Get retrieves all tokens, including revoked and expired ones.
+func (t *Token) Get(ctx context.Context) ([]Token, error) {
+	return store.GetTokens(ctx)
+}
+
+// Find retrieves the token by its UUID.
+//
+// Returns sql.ErrNoRows if the token does not exist.
+func (t *Token) Find(ctx context.Context) error {
+	return store.FindToken(ctx, t)
+}
+
+// FindByHash retrieves the token by the hash of its secret.
+//
+// Returns sql.ErrNoRows if no token has that secret.
+func (t *Token) FindByHash(ctx context.Context) error {
+	return store.FindTokenByHash(ctx, t)
+}
+
+// Create stores a new token.
+func (t *Token) Create(ctx context.Context) error {
+	return store.CreateToken(ctx, t)
+}
+
+// Revoke marks the token as revoked, it stays listed for reference.
+//
+// Returns sql.ErrNoRows if the token does not exist or is already revoked.
+func (t *Token) Revoke(ctx context.Context) error {
+	return store.RevokeToken(ctx, t)
+}
+
+// Touch records that the token authenticated a request at the given time.
+func (t *Token) Touch(ctx context.Context, at time.Time) error {
+	return store.TouchToken(ctx, t, at)
+}
@@ -3,24 +3,74 @@ package rdb
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// ZoneNamespace is the namespace of the version 5 UUIDs identifying zones.
+var ZoneNamespace = uuid.Must(uuid.Parse("6ba7b810-9dad-11d1-80b4-00c04fd430c8"))
+
+// ZoneUUID returns the UUID of the zone called name.
+func ZoneUUID(name string) string {
+	return uuid.NewSHA1(ZoneNamespace, []byte(name)).String()
+}
+
 type Zone struct {
-	UUID       string       // Zone UUID
-	Name       string       // Zone name
-	CreatedAt  time.Time    // Zone creation time
-	ModifiedAt time.Time    // Zone modification time
-	DeletedAt  sql.NullTime // Zone deletion time
-	Staging    bool         // Zone staging status
-	PrimaryNS  string       // Zone primary NS
-	AdminEmail string       // Zone admin email
-	Refresh    uint16       // Zone refresh interval
-	Retry      uint16       // Zone retry interval
-	Expire     uint32       // Zone expire interval
-	Minimum    uint16       // Zone minimum TTL
-	TTL        uint16       // Zone TTL
-	Tags       []string     // Zone tags
+	UUID        string       // Zone UUID
+	Name        string       // Zone name
+	CreatedAt   time.Time    // Zone creation time
+	ModifiedAt  time.Time    // Zone modification time
+	DeletedAt   sql.NullTime // Zone deletion time
+	Staging     bool         // Zone staging status
+	PrimaryNS   string       // Zone primary NS
+	AdminEmail  string       // Zone admin email
+	Refresh     uint16       // Zone refresh interval
+	Retry       uint16       // Zone retry interval
+	Expire      uint32       // Zone expire interval
+	Minimum     uint16       // Zone minimum TTL
+	TTL         uint16       // Zone TTL
+	NameServers NameServers  // Zone name servers
+	Tags        []string     // Zone tags
+}
+
+// NameServer is a name server of a zone. Glue addresses are only rendered
+// for name servers within the zone.
+type NameServer struct {
+	Host      string   `json:"host"`                // Relative to the zone unless it ends with a dot
+	Addresses []string `json:"addresses,omitempty"` // Glue A and AAAA addresses
+}
+
+// NameServers is the NS set of a zone, stored as JSON.
+type NameServers []NameServer
+
+// Value implements driver.Valuer.
+func (ns NameServers) Value() (driver.Value, error) {
+	if ns == nil {
+		ns = NameServers{}
+	}
+	b, err := json.Marshal(ns)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (ns *NameServers) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*ns = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, ns)
+	case string:
+		return json.Unmarshal([]byte(v), ns)
+	}
+	return fmt.Errorf("cannot scan %T into NameServers", src)
 }
 
 // Get retrieves all zones from the database.
@@ -29,191 +79,68 @@ type Zone struct {
 //   - []Zone: A slice of Zone structs representing the retrieved zones.
 //   - error: An error if the retrieval fails.
 func (z *Zone) Get(ctx context.Context) ([]Zone, error) {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	rows, err := tx.QueryContext(ctx, "SELECT uuid, name, created_at, modified_at, deleted_at, primary_ns, admin_email, refresh, retry, expire, minimum, ttl,staging FROM bind_dns.zones WHERE deleted_at IS NULL OR staging = TRUE")
+	zones, err := store.GetZones(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var zones []Zone
-	for rows.Next() {
-		var zone Zone
-		err := rows.Scan(&zone.UUID, &zone.Name, &zone.CreatedAt, &zone.ModifiedAt, &zone.DeletedAt, &zone.PrimaryNS, &zone.AdminEmail, &zone.Refresh, &zone.Retry, &zone.Expire, &zone.Minimum, &zone.TTL, &zone.Staging)
-		if err != nil {
-			return nil, err
-		}
-		zones = append(zones, zone)
-
-		// get tags
-		zone.Tags, err = new(Tag).GetZone(ctx, zone.UUID)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return zones, nil
+	return scopeZones(ctx, zones)
 }
 
 // Create inserts a new zone into the database.
 //
 // Returns an error if the insertion fails.
 func (z *Zone) Create(ctx context.Context) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	query := "INSERT INTO bind_dns.zones (uuid, name, created_at, modified_at, deleted_at, primary_ns, admin_email, refresh, retry, expire, minimum, staging) VALUES ($1, $2, $3, $3, 0, $4, $5, $6, $7, $8, $9, TRUE)"
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	timeNow := time.Now()
-	z.CreatedAt = timeNow
-	z.ModifiedAt = timeNow
-	_, err = stmt.ExecContext(ctx, z.UUID, z.Name, timeNow, z.PrimaryNS, z.AdminEmail, z.Refresh, z.Retry, z.Expire, z.Minimum)
-	if err != nil {
-		return err
-	}
-
-	// add tags if any
-	if len(z.Tags) > 0 {
-		for _, tag := range z.Tags {
-			err := Tag(tag).CreateZone(ctx, z.UUID)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return tx.Commit()
+	return store.CreateZone(ctx, z)
 }
 
 // Update marks a zone as staging in the database.
 //
 // Returns an error if the update fails.
 func (z *Zone) Update(ctx context.Context) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	query := "UPDATE bind_dns.zones SET name = $1, primary_ns = $2, admin_email = $3, refresh = $4, retry = $5, expire = $6, minimum = $7, staging = TRUE WHERE uuid = $8"
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	result, err := stmt.ExecContext(ctx, z.Name, z.PrimaryNS, z.AdminEmail, z.Refresh, z.Retry, z.Expire, z.Minimum, z.UUID)
-	if err != nil {
-		return err
-	}
-
-	// Log the output
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-
-	// delete tags
-	err = Tag("").DeleteZone(ctx, z.UUID)
-	if err != nil && err != ErrTagNotFound {
-		return err
-	}
-
-	// add tags if any
-	if len(z.Tags) > 0 {
-		for _, tag := range z.Tags {
-			err := Tag(tag).CreateZone(ctx, z.UUID)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return tx.Commit()
+	return store.UpdateZone(ctx, z)
 }
 
 // Delete marks a zone as deleted in the database.
 //
 // Returns an error if the deletion fails.
 func (z *Zone) Delete(ctx context.Context) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	query := "UPDATE bind_dns.zones SET deleted_at = $1, staging = TRUE WHERE uuid = $2"
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+	return store.DeleteZone(ctx, z)
+}
 
-	result, err := stmt.ExecContext(ctx, time.Now(), z.UUID)
-	if err != nil {
-		return err
-	}
+// Stage stages the zone as given, restoring it when DeletedAt is not set.
+//
+// Returns sql.ErrNoRows if the zone does not exist.
+func (z *Zone) Stage(ctx context.Context) error {
+	return store.StageZone(ctx, z)
+}
 
-	// Log the output
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+// Commit commits the staged changes of the zone with the UUID of z and of
+// its records.
+//
+// Returns an error if the commit fails.
+func (z *Zone) Commit(ctx context.Context) error {
+	if err := store.CommitZones(ctx, z.UUID); err != nil {
 		return err
 	}
+	return store.CommitRecords(ctx, z.UUID)
+}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-
-	return tx.Commit()
+// Discard reverts staged zones to their last committed version. Zones that
+// were never committed are deleted. Only the zone of UUID is discarded when
+// it is set. Records are not touched, discard them first.
+//
+// Returns:
+//   - []Zone: The zones as they were staged before being discarded.
+//   - error: An error if the discard fails, in which case nothing changed.
+func (z *Zone) Discard(ctx context.Context) ([]Zone, error) {
+	return store.DiscardZones(ctx, z.UUID)
 }
 
 // Find retrieves a zone from the database.
 //
 // Returns an error if the retrieval fails.
 func (z *Zone) Find(ctx context.Context) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	query := "SELECT uuid, name, created_at, modified_at, deleted_at, primary_ns, admin_email, refresh, retry, expire, minimum, staging FROM bind_dns.zones WHERE uuid = $1 AND (deleted_at IS NULL OR (deleted_at IS NOT NULL AND staging = TRUE))"
-
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	row := stmt.QueryRowContext(ctx, z.UUID)
-	err = row.Scan(&z.UUID, &z.Name, &z.CreatedAt, &z.ModifiedAt, &z.DeletedAt, &z.PrimaryNS, &z.AdminEmail, &z.Refresh, &z.Retry, &z.Expire, &z.Minimum, &z.Staging)
-	if err != nil {
-		return err
-	}
-
-	// get tags
-	z.Tags, err = new(Tag).GetZone(ctx, z.UUID)
-	if err != nil {
-		return err
-	}
-	return nil
+	return store.FindZone(ctx, z)
 }
 
 // GetStaging retrieves all zones in staging from the database.
@@ -222,33 +149,9 @@ func (z *Zone) Find(ctx context.Context) error {
 //   - []Zone: A slice of Zone structs representing the retrieved zones.
 //   - error: An error if the retrieval fails.
 func (z *Zone) GetStaging(ctx context.Context) ([]Zone, error) {
-	tx, err := db.BeginTx(ctx, nil)
+	zones, err := store.GetStagingZones(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback()
-
-	rows, err := tx.QueryContext(ctx, "SELECT uuid, name, created_at, modified_at, deleted_at, primary_ns, admin_email, refresh, retry, expire, minimum, staging FROM bind_dns.zones WHERE staging = TRUE")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var zones []Zone
-	for rows.Next() {
-		var zone Zone
-		err := rows.Scan(&zone.UUID, &zone.Name, &zone.CreatedAt, &zone.ModifiedAt, &zone.DeletedAt, &zone.PrimaryNS, &zone.AdminEmail, &zone.Refresh, &zone.Retry, &zone.Expire, &zone.Minimum, &zone.Staging)
-		if err != nil {
-			return nil, err
-		}
-
-		// get tags
-		zone.Tags, err = new(Tag).GetZone(ctx, zone.UUID)
-		if err != nil {
-			return nil, err
-		}
-		zones = append(zones, zone)
-	}
-
-	return zones, nil
+	return scopeStagingZones(ctx, zones), nil
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"path/filepath"
 	"runtime"
 	"text/template"
@@ -16,6 +17,9 @@ func PreviewZoneRender(ctx context.Context) (map[string]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	if _, err := assignSerials(ctx, zones); err != nil {
+		return nil, err
+	}
 
 	zoneOutputs := make(map[string]string)
 
@@ -68,3 +72,40 @@ func PreviewZoneRender(ctx context.Context) (map[string]string, error) {
 
 	return zoneOutputs, nil
 }
+
+var ErrZoneNotFound = errors.New("zone not found")
+
+// PreviewZone renders the zone file of a single zone from its staged state,
+// exactly as RenderZonesTemplate would write it.
+func PreviewZone(ctx context.Context, name string) (string, error) {
+	zones, err := createZones(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, zone := range zones {
+		if zone.Name != name {
+			continue
+		}
+		single := []Zone{zone}
+		if _, err := assignSerials(ctx, single); err != nil {
+			return "", err
+		}
+
+		// Parse template
+		_, filePath, _, _ := runtime.Caller(0)
+		templatePath := filepath.Dir(filePath) + "/templates/bind-zone.tmpl"
+		t, err := template.New("bind-zone.tmpl").ParseFiles(templatePath)
+		if err != nil {
+			return "", errors.New("Failed to parse template: " + err.Error())
+		}
+
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, single[0]); err != nil {
+			return "", errors.New("Failed to render template: " + err.Error())
+		}
+		return buf.String(), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrZoneNotFound, name)
+}
@@ -4,16 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net"
+	"log"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"runtime"
-	"strconv"
+	"sort"
 	"strings"
 	"text/template"
-	"time"
 
 	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/DrC0ns0le/bind-api/reverse"
+	"github.com/DrC0ns0le/bind-api/validation"
 )
 
 const (
@@ -23,14 +25,12 @@ const (
 var (
 	// PTR errors
 	ErrUnsupportedRecordType = errors.New("Unsupported record type:")
-	ErrUnsupportedIPv4       = errors.New("Unsupported IPv4 address:")
-	ErrUnsupportedIPv6       = errors.New("Unsupported IPv6 address:")
 )
 
 type SOA struct {
 	PrimaryNS  string
 	AdminEmail string
-	Serial     uint64
+	Serial     uint32
 	Refresh    uint16
 	Retry      uint16
 	Expire     uint32
@@ -46,25 +46,64 @@ type Record struct {
 }
 
 type Zone struct {
-	Name    string
-	Records []Record
-	SOA     SOA
+	Name        string
+	NameServers []Record
+	Records     []Record
+	SOA         SOA
+
+	// generated reverse zones have no zone row yet
+	generated bool
+	ns        rdb.NameServers
 }
 
 func createZones(ctx context.Context) ([]Zone, error) {
 	var ZS []Zone
 	rDNS := make(map[string][]Record)
+	rSOA := make(map[string]rdb.Zone) // forward zone a generated reverse zone takes its SOA from
 
-	addReverseDNS := func(z rdb.Zone, r rdb.Record, rDNS *map[string][]Record, a string) {
-		if _, ok := (*rDNS)[a]; !ok {
-			(*rDNS)[a] = []Record{}
+	reverseZones, err := reverse.Zones(ctx)
+	if err != nil {
+		return ZS, err
+	}
+
+	addReverseDNS := func(z rdb.Zone, r rdb.Record, rDNS map[string][]Record) {
+		addr, err := netip.ParseAddr(r.Content)
+		if err != nil {
+			log.Printf("Skipping PTR for %s %s: %v", r.Host, r.Content, err)
+			return
+		}
+		rz, ok := reverse.Match(reverseZones, addr)
+		if !ok {
+			log.Printf("Skipping PTR for %s %s: no reverse zone configured for it", r.Host, r.Content)
+			return
+		}
+
+		target := z.Name + "."
+		if r.Host != "@" {
+			target = r.Host + "." + target
 		}
-		(*rDNS)[a] = append((*rDNS)[a], Record{
-			Type:    r.Type,
-			Host:    r.Host + "." + z.Name + ".",
-			Content: r.Content,
+		rDNS[rz.Name] = append(rDNS[rz.Name], Record{
+			Type:    "PTR",
+			Host:    rz.PTRName(addr),
+			Content: target,
 			TTL:     r.TTL,
 		})
+		if _, ok := rSOA[rz.Name]; !ok {
+			rSOA[rz.Name] = z
+		}
+
+		// RFC 2317: the parent zone points into the classless zone
+		if parent, ok := reverse.Parent(reverseZones, rz); ok {
+			if _, ok := rSOA[parent.Name]; !ok {
+				rSOA[parent.Name] = z
+			}
+			rDNS[parent.Name] = append(rDNS[parent.Name], Record{
+				Type:    "CNAME",
+				Host:    reverse.Name(addr),
+				Content: rz.PTRName(addr),
+				TTL:     r.TTL,
+			})
+		}
 	}
 
 	zs, err := (&rdb.Zone{}).Get(ctx)
@@ -73,6 +112,11 @@ func createZones(ctx context.Context) ([]Zone, error) {
 	}
 
 	for _, z := range zs {
+		// zones pending deletion are no longer rendered
+		if z.DeletedAt.Valid {
+			continue
+		}
+
 		rs, err := (&rdb.Record{ZoneUUID: z.UUID}).Get(ctx)
 		if err != nil {
 			return ZS, err
@@ -80,6 +124,10 @@ func createZones(ctx context.Context) ([]Zone, error) {
 
 		var RS []Record
 		for _, r := range rs {
+			if r.DeletedAt.Valid {
+				continue
+			}
+
 			RS = append(RS, Record{
 				Type: r.Type,
 				Host: r.Host,
@@ -93,44 +141,18 @@ func createZones(ctx context.Context) ([]Zone, error) {
 			})
 
 			// Create record for reverse lookup
-			if r.AddPTR {
-				if r.Type == "A" {
-					parts := strings.Split(r.Content, ".")
-					if parts[0] == "10" {
-						addReverseDNS(z, r, &rDNS, "10.in-addr.arpa")
-					} else if parts[0] == "192" && parts[1] == "168" {
-						addReverseDNS(z, r, &rDNS, "168.192.in-addr.arpa")
-					} else if parts[0] == "172" {
-						val, err := strconv.Atoi(parts[1])
-						if err != nil {
-							return nil, err
-						}
-						if val >= 16 && val <= 31 {
-							addReverseDNS(z, r, &rDNS, fmt.Sprintf("%s.172.in-addr.arpa", parts[1]))
-						} else {
-							return nil, fmt.Errorf("%w: %s not within RFC1918 range", ErrUnsupportedIPv4, r.Content)
-						}
-					} else {
-						return nil, fmt.Errorf("%w: %s", ErrUnsupportedIPv4, r.Content)
-					}
-				} else if r.Type == "AAAA" {
-					parts := strings.Split(r.Content, ":")
-					if parts[0] == "fdac" {
-						addReverseDNS(z, r, &rDNS, "d.f.ip6.arpa")
-					} else if strings.Split(parts[0], "")[0] == "2" {
-						addReverseDNS(z, r, &rDNS, "2.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.ip6.arpa")
-					}
-				}
+			if r.AddPTR && (r.Type == "A" || r.Type == "AAAA") {
+				addReverseDNS(z, r, rDNS)
 			}
 		}
 
 		Z := Zone{
-			Name:    z.Name,
-			Records: RS,
+			Name:        z.Name,
+			NameServers: nameServerRecords(z.Name, z.NameServers, z.TTL),
+			Records:     RS,
 			SOA: SOA{
 				PrimaryNS:  z.PrimaryNS,
 				AdminEmail: z.AdminEmail,
-				Serial:     uint64(time.Now().Unix()),
 				Refresh:    z.Refresh,
 				Retry:      z.Retry,
 				Expire:     z.Expire,
@@ -142,74 +164,137 @@ func createZones(ctx context.Context) ([]Zone, error) {
 		ZS = append(ZS, Z)
 	}
 
-	for arpaZone, rs := range rDNS {
-		var rRS []Record
-		for _, r := range rs {
-			var addr string
-			if r.Type == "A" {
-				// Generate PTR address for IPv4
-				addr = fmt.Sprintf("%s.in-addr.arpa.", reverseIPv4(r.Content))
-			} else if r.Type == "AAAA" {
-				// Generate PTR address for IPv6
-				addr = fmt.Sprintf("%s.ip6.arpa.", reverseIPv6(r.Content))
-			}
+	arpaZones := make([]string, 0, len(rDNS))
+	for arpaZone := range rDNS {
+		arpaZones = append(arpaZones, arpaZone)
+	}
+	sort.Strings(arpaZones)
 
-			rRS = append(rRS, Record{
-				Type:    "PTR",
-				Host:    addr,
-				Content: r.Host,
-				TTL:     r.TTL,
-			})
+	// index the zones with a row, generated records are merged into them
+	rows := make(map[string]int)
+	for i, z := range ZS {
+		rows[strings.ToLower(z.Name)] = i
+	}
+
+	for _, arpaZone := range arpaZones {
+		if i, ok := rows[arpaZone]; ok {
+			ZS[i].Records = mergeGenerated(ZS[i], rDNS[arpaZone])
+			continue
 		}
 
+		// reverse zones without a row take their SOA and name servers from
+		// the first forward zone that needed them, until a row is created
+		from := rSOA[arpaZone]
+		ns := qualifyNameServers(from)
 		ZS = append(ZS, Zone{
-			Name:    arpaZone,
-			Records: rRS,
+			Name:        arpaZone,
+			NameServers: nameServerRecords(arpaZone, ns, from.TTL),
+			Records:     rDNS[arpaZone],
 			SOA: SOA{
-				// PrimaryNS:  _bd.Configs.PrimaryNS,
-				// AdminEmail: _bd.Configs.AdminEmail,
-				// Refresh:    _bd.Configs.Refresh,
-				// Retry:      _bd.Configs.Retry,
-				// Expire:     _bd.Configs.Expire,
-				// Minimum:    _bd.Configs.Minimum,
-				// TTL:        _bd.Configs.TTL,
-
-				// Hardcoded for now
-				PrimaryNS:  "ns.arpa.leejacksonz.com",
-				AdminEmail: "admin.leejacksonz.com",
-				Serial:     uint64(time.Now().Unix()),
-				Refresh:    1800,
-				Retry:      1800,
-				Expire:     604800,
-				Minimum:    1800,
-				TTL:        3600,
+				PrimaryNS:  from.PrimaryNS,
+				AdminEmail: from.AdminEmail,
+				Refresh:    from.Refresh,
+				Retry:      from.Retry,
+				Expire:     from.Expire,
+				Minimum:    from.Minimum,
+				TTL:        from.TTL,
 			},
+			ns:        ns,
+			generated: true,
 		})
 	}
 
 	return ZS, nil
 }
 
-func reverseIPv4(s string) string {
-	parts := strings.Split(s, ".")
-	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
-		parts[i], parts[j] = parts[j], parts[i]
+// mergeGenerated adds generated records to a zone, except at names the zone
+// already holds records for: hand-written records take precedence.
+func mergeGenerated(zone Zone, generated []Record) []Record {
+	owners := make(map[string]bool)
+	for _, r := range zone.Records {
+		owners[validation.InZone(r.Host, zone.Name)] = true
+	}
+
+	records := zone.Records
+	for _, r := range generated {
+		if owners[validation.InZone(r.Host, zone.Name)] {
+			log.Printf("Skipping generated %s %s %s: overridden by a record in %s", r.Host, r.Type, r.Content, zone.Name)
+			continue
+		}
+		records = append(records, r)
 	}
-	return strings.Join(parts, ".")
+	return records
 }
 
-func reverseIPv6(s string) string {
-	ip := net.ParseIP(s)
-	var ipString strings.Builder
-	for _, octet := range ip {
-		ipString.WriteString(fmt.Sprintf("%02x", octet))
+// nameServerRecords returns the NS records of a zone, followed by the glue
+// of the name servers within it.
+func nameServerRecords(zone string, ns rdb.NameServers, ttl uint16) []Record {
+	var records []Record
+	for _, server := range ns {
+		records = append(records, Record{Type: "NS", Host: "@", Content: server.Host, TTL: ttl})
 	}
-	runes := []rune(ipString.String())
-	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
-		runes[i], runes[j] = runes[j], runes[i]
+	for _, server := range ns {
+		if !validation.IsInZone(server.Host, zone) {
+			continue
+		}
+		for _, a := range server.Addresses {
+			addr, err := netip.ParseAddr(a)
+			if err != nil {
+				continue
+			}
+			recordType := "A"
+			if addr.Is6() && !addr.Is4In6() {
+				recordType = "AAAA"
+			}
+			records = append(records, Record{Type: recordType, Host: server.Host, Content: addr.Unmap().String(), TTL: ttl})
+		}
+	}
+	return records
+}
+
+// qualifyNameServers returns the name servers of a zone as absolute names,
+// without glue, for use in another zone.
+func qualifyNameServers(z rdb.Zone) rdb.NameServers {
+	ns := make(rdb.NameServers, 0, len(z.NameServers))
+	for _, server := range z.NameServers {
+		ns = append(ns, rdb.NameServer{Host: validation.InZone(server.Host, z.Name) + "."})
+	}
+	return ns
+}
+
+// createReverseZones creates a row for every generated reverse zone, so that
+// its SOA and records can be managed like any other zone, and returns the
+// UUIDs of the rows it created. Zones whose row is only hidden by a staging
+// scope, see rdb.WithStagingScope, are left as they are.
+func createReverseZones(ctx context.Context, zones []Zone) ([]string, error) {
+	var created []string
+	for _, z := range zones {
+		if !z.generated {
+			continue
+		}
+		row := rdb.Zone{
+			UUID:        rdb.ZoneUUID(z.Name),
+			Name:        z.Name,
+			PrimaryNS:   z.SOA.PrimaryNS,
+			AdminEmail:  z.SOA.AdminEmail,
+			Refresh:     z.SOA.Refresh,
+			Retry:       z.SOA.Retry,
+			Expire:      z.SOA.Expire,
+			Minimum:     z.SOA.Minimum,
+			TTL:         z.SOA.TTL,
+			NameServers: z.ns,
+			Staging:     true,
+		}
+		if err := (&rdb.Zone{UUID: row.UUID}).Find(ctx); err == nil {
+			continue
+		}
+		if err := row.Create(ctx); err != nil {
+			return created, fmt.Errorf("unable to create reverse zone %s: %w", z.Name, err)
+		}
+		log.Printf("Created reverse zone %s", z.Name)
+		created = append(created, row.UUID)
 	}
-	// Join with dots
-	return strings.Join(strings.Split(string(runes), ""), ".")
+	return created, nil
 }
 
 // renderNamedZones renders the named zones based on the provided Zone slice.
@@ -336,27 +421,62 @@ func renderZone(zone Zone) (string, error) {
 	return path, nil
 }
 
-func RenderZonesTemplate(ctx context.Context) error {
+// Rendered is the outcome of RenderZonesTemplate. Nothing of it is stored
+// before Save, so that zones which fail verification leave no trace.
+type Rendered struct {
+	zones   []Zone
+	serials []rdb.Serial
+	created []string // UUIDs of the reverse zones Save created
+}
+
+// RenderZonesTemplate renders every zone into the output directory.
+func RenderZonesTemplate(ctx context.Context) (*Rendered, error) {
 
 	// Create all zones
 	zs, err := createZones(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Issue new serials for the zones that changed
+	serials, err := assignSerials(ctx, zs)
+	if err != nil {
+		return nil, err
 	}
 
 	// Render configs
 	_, err = renderNamedZones(zs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Render all zones
 	for _, z := range zs {
 		_, err := renderZone(z)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
+	return &Rendered{zones: zs, serials: serials}, nil
+}
+
+// Save gives the generated reverse zones a staged row of their own and
+// stores the serials issued to the zones that changed.
+func (r *Rendered) Save(ctx context.Context) error {
+	var err error
+	if r.created, err = createReverseZones(ctx, r.zones); err != nil {
+		return err
+	}
+	for _, serial := range r.serials {
+		if err := serial.Save(ctx); err != nil {
+			return fmt.Errorf("unable to save serial of %s: %w", serial.Zone, err)
+		}
+	}
 	return nil
 }
+
+// Created returns the UUIDs of the reverse zones Save staged a row for.
+func (r *Rendered) Created() []string {
+	return r.created
+}
@@ -0,0 +1,145 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/DrC0ns0le/bind-api/commit"
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/miekg/dns"
+)
+
+// SOA serial schemes
+const (
+	SerialDate    = "date"    // YYYYMMDDnn
+	SerialUnix    = "unix"    // Seconds since the epoch
+	SerialCounter = "counter" // Incremented by one on every change
+)
+
+var serialScheme = SerialDate
+
+// SetSerialScheme selects how new SOA serials are chosen.
+func SetSerialScheme(scheme string) error {
+	switch scheme {
+	case SerialDate, SerialUnix, SerialCounter:
+		serialScheme = scheme
+		return nil
+	}
+	return fmt.Errorf("unknown serial scheme %q, expected %s, %s or %s", scheme, SerialDate, SerialUnix, SerialCounter)
+}
+
+// assignSerials sets the SOA serial of every zone. A zone keeps its serial
+// until its content changes, then gets the next one of the selected scheme.
+//
+// The new serials are returned rather than stored, previews discard them
+// and applies store them once the rendered zones are verified.
+func assignSerials(ctx context.Context, zones []Zone) ([]rdb.Serial, error) {
+	var issued []rdb.Serial
+	now := time.Now()
+	for i := range zones {
+		hash, err := zoneHash(zones[i])
+		if err != nil {
+			return nil, err
+		}
+
+		serial := rdb.Serial{Zone: zones[i].Name}
+		err = serial.Find(ctx)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("unable to retrieve serial of %s: %w", zones[i].Name, err)
+		}
+		known := err == nil
+		if known && serial.Hash == hash {
+			zones[i].SOA.Serial = serial.Serial
+			continue
+		}
+
+		// zones rendered before serials were persisted continue from the
+		// serial they were last committed with
+		if !known {
+			serial.Serial, known = committedSerial(zones[i].Name)
+		}
+
+		serial.Serial = nextSerial(serial.Serial, known, now)
+		serial.Hash = hash
+		zones[i].SOA.Serial = serial.Serial
+		issued = append(issued, serial)
+	}
+	return issued, nil
+}
+
+// nextSerial returns the serial following prev in the selected scheme. The
+// result is always greater than prev in RFC 1982 serial number arithmetic.
+func nextSerial(prev uint32, known bool, now time.Time) uint32 {
+	var candidate uint32
+	switch serialScheme {
+	case SerialUnix:
+		candidate = uint32(now.Unix())
+	case SerialCounter:
+		candidate = prev + 1
+	default:
+		y, m, d := now.UTC().Date()
+		candidate = uint32(y)*1000000 + uint32(m)*10000 + uint32(d)*100
+	}
+
+	if !known || serialGreater(candidate, prev) {
+		return candidate
+	}
+	// several changes on one day, or a clock behind the last serial
+	return prev + 1
+}
+
+// serialGreater reports whether s1 is greater than s2 as defined by RFC 1982.
+func serialGreater(s1, s2 uint32) bool {
+	return s1 != s2 && s1-s2 < 1<<31
+}
+
+// zoneHash identifies the content of a zone, leaving out its serial and the
+// order records were retrieved in.
+func zoneHash(z Zone) (string, error) {
+	z.SOA.Serial = 0
+	z.Records = append([]Record(nil), z.Records...)
+	sort.Slice(z.Records, func(i, j int) bool {
+		a, b := z.Records[i], z.Records[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Content != b.Content {
+			return a.Content < b.Content
+		}
+		return a.TTL < b.TTL
+	})
+
+	b, err := json.Marshal(z)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// committedSerial reads the serial of a zone from its last committed file.
+func committedSerial(name string) (uint32, bool) {
+	text, err := commit.ReadCommitted(name + ".conf")
+	if err != nil {
+		return 0, false
+	}
+
+	zp := dns.NewZoneParser(bytes.NewReader(text), dns.Fqdn(name), name+".conf")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,334 @@
+// Package reverse maps addresses to the reverse zones their PTR records
+// belong in.
+//
+// Reverse zones are declared as networks in CIDR notation through the
+// reverse_network config key. IPv4 networks on an octet boundary map to a
+// single in-addr.arpa zone, wider ones are split into the zones of the next
+// octet boundary and networks smaller than a /24 get an RFC 2317 classless
+// zone. IPv6 networks must end on a nibble boundary.
+//
+// Zones named after a reverse network can also be created through the zones
+// API, which gives them their own SOA and lets them hold manual records.
+package reverse
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+// ConfigKey is the config key holding the declared reverse networks, one
+// CIDR per value.
+const ConfigKey = "reverse_network"
+
+// maxSplit limits how many zones a single IPv4 network may be split into.
+const maxSplit = 256
+
+// DefaultNetworks are used when no reverse network is configured and cover
+// the private ranges reverse zones were historically generated for.
+var DefaultNetworks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fd00::/8"}
+
+// Zone is a reverse zone and the network it is authoritative for.
+type Zone struct {
+	Name   string       `json:"name"`
+	Prefix netip.Prefix `json:"prefix"`
+}
+
+// Classless reports whether the zone is an RFC 2317 delegation of part of a /24.
+func (z Zone) Classless() bool {
+	return z.Prefix.Addr().Is4() && z.Prefix.Bits() > 24
+}
+
+// Contains reports whether addr belongs in the zone.
+func (z Zone) Contains(addr netip.Addr) bool {
+	return z.Prefix.Contains(addr.Unmap())
+}
+
+// PTRName returns the absolute owner name of the PTR record of addr in the
+// zone. For classless zones this is the last octet below the zone name,
+// everywhere else it is the regular reverse name.
+func (z Zone) PTRName(addr netip.Addr) string {
+	addr = addr.Unmap()
+	if z.Classless() {
+		return fmt.Sprintf("%d.%s.", addr.As4()[3], z.Name)
+	}
+	return Name(addr)
+}
+
+// Name returns the regular reverse lookup name of addr, with a trailing dot.
+func Name(addr netip.Addr) string {
+	addr = addr.Unmap()
+	if addr.Is4() {
+		a := addr.As4()
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", a[3], a[2], a[1], a[0])
+	}
+	return nibbles(addr, 128) + ".ip6.arpa."
+}
+
+// Parse returns the reverse zones covering the network cidr.
+func Parse(cidr string) ([]Zone, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid reverse network %q: %w", cidr, err)
+	}
+	if prefix.Addr().Zone() != "" {
+		return nil, fmt.Errorf("invalid reverse network %q: zoned addresses are not supported", cidr)
+	}
+	if prefix.Addr().Is4In6() {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	prefix = prefix.Masked()
+	bits := prefix.Bits()
+
+	if prefix.Addr().Is6() {
+		if bits == 0 || bits%4 != 0 {
+			return nil, fmt.Errorf("invalid reverse network %q: IPv6 networks must end on a nibble boundary", cidr)
+		}
+		return []Zone{{Name: nibbles(prefix.Addr(), bits) + ".ip6.arpa", Prefix: prefix}}, nil
+	}
+
+	switch {
+	case bits == 0 || bits == 32:
+		return nil, fmt.Errorf("invalid reverse network %q: IPv4 networks must be between /1 and /31", cidr)
+	case bits > 24:
+		// RFC 2317 names the zone after the range it covers, using a hyphen
+		// instead of a slash since the zone name is also its file name
+		a := prefix.Addr().As4()
+		last := int(a[3]) + 1<<(32-bits) - 1
+		return []Zone{{
+			Name:   fmt.Sprintf("%d-%d.%d.%d.%d.in-addr.arpa", a[3], last, a[2], a[1], a[0]),
+			Prefix: prefix,
+		}}, nil
+	case bits%8 == 0:
+		return []Zone{{Name: octets(prefix.Addr(), bits) + ".in-addr.arpa", Prefix: prefix}}, nil
+	}
+
+	// split the network on the next octet boundary
+	boundary := (bits/8 + 1) * 8
+	count := 1 << (boundary - bits)
+	if count > maxSplit {
+		return nil, fmt.Errorf("invalid reverse network %q: would be split into %d zones, at most %d are allowed", cidr, count, maxSplit)
+	}
+
+	zones := make([]Zone, 0, count)
+	addr := prefix.Addr()
+	for i := 0; i < count; i++ {
+		sub := netip.PrefixFrom(addr, boundary)
+		zones = append(zones, Zone{Name: octets(addr, boundary) + ".in-addr.arpa", Prefix: sub})
+
+		// advance to the next subnet
+		a := addr.As4()
+		v := uint32(a[0])<<24 | uint32(a[1])<<16 | uint32(a[2])<<8 | uint32(a[3])
+		v += 1 << (32 - boundary)
+		addr = netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	}
+	return zones, nil
+}
+
+// ParseAll returns the reverse zones of every network, most specific first.
+func ParseAll(cidrs []string) ([]Zone, error) {
+	var zones []Zone
+	for _, cidr := range cidrs {
+		zs, err := Parse(cidr)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zs...)
+	}
+
+	sort.SliceStable(zones, func(i, j int) bool {
+		return zones[i].Prefix.Bits() > zones[j].Prefix.Bits()
+	})
+	return zones, nil
+}
+
+// Zones returns the reverse zones PTR records may be placed in: the zones
+// managed through the API under in-addr.arpa or ip6.arpa, and the configured
+// networks, falling back to DefaultNetworks when none are configured.
+func Zones(ctx context.Context) ([]Zone, error) {
+	configs, err := (&rdb.Config{ConfigKey: ConfigKey}).Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var cidrs []string
+	for _, c := range configs {
+		if c.DeletedAt.Valid {
+			continue
+		}
+		cidrs = append(cidrs, c.ConfigValue)
+	}
+	if len(cidrs) == 0 {
+		cidrs = DefaultNetworks
+	}
+
+	zones, err := ParseAll(cidrs)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := (&rdb.Zone{}).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, z := range zones {
+		known[z.Name] = true
+	}
+	for _, row := range rows {
+		if row.DeletedAt.Valid {
+			continue
+		}
+		if z, ok := FromName(row.Name); ok && !known[z.Name] {
+			known[z.Name] = true
+			zones = append(zones, z)
+		}
+	}
+
+	sort.SliceStable(zones, func(i, j int) bool {
+		return zones[i].Prefix.Bits() > zones[j].Prefix.Bits()
+	})
+	return zones, nil
+}
+
+// FromName returns the reverse zone called name, recovering the network it
+// covers. It reports false when name is not a reverse zone, or a classless
+// zone not named the way Parse names them.
+func FromName(name string) (Zone, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	if rest, ok := strings.CutSuffix(name, ".ip6.arpa"); ok {
+		labels := strings.Split(rest, ".")
+		if len(labels) > 31 {
+			return Zone{}, false
+		}
+		var a [16]byte
+		for i, label := range labels {
+			v, err := strconv.ParseUint(label, 16, 4)
+			if err != nil || len(label) != 1 {
+				return Zone{}, false
+			}
+			// labels run from the least significant nibble
+			n := len(labels) - 1 - i
+			if n%2 == 0 {
+				a[n/2] |= byte(v) << 4
+			} else {
+				a[n/2] |= byte(v)
+			}
+		}
+		return Zone{Name: name, Prefix: netip.PrefixFrom(netip.AddrFrom16(a), 4*len(labels))}, true
+	}
+
+	rest, ok := strings.CutSuffix(name, ".in-addr.arpa")
+	if !ok {
+		return Zone{}, false
+	}
+	labels := strings.Split(rest, ".")
+	if len(labels) == 0 || len(labels) > 4 {
+		return Zone{}, false
+	}
+
+	// RFC 2317 classless zones start with the first-last range
+	first, last, classless := strings.Cut(labels[0], "-")
+	if classless && len(labels) != 4 {
+		return Zone{}, false
+	}
+	if !classless && len(labels) == 4 {
+		return Zone{}, false
+	}
+
+	var a [4]byte
+	for i, label := range labels[1:] {
+		v, err := strconv.ParseUint(label, 10, 8)
+		if err != nil || strconv.FormatUint(v, 10) != label {
+			return Zone{}, false
+		}
+		a[len(labels)-2-i] = byte(v)
+	}
+
+	if !classless {
+		v, err := strconv.ParseUint(first, 10, 8)
+		if err != nil || strconv.FormatUint(v, 10) != first {
+			return Zone{}, false
+		}
+		a[len(labels)-1] = byte(v)
+		return Zone{Name: name, Prefix: netip.PrefixFrom(netip.AddrFrom4(a), 8*len(labels))}, true
+	}
+
+	lo, err1 := strconv.ParseUint(first, 10, 8)
+	hi, err2 := strconv.ParseUint(last, 10, 8)
+	if err1 != nil || err2 != nil || hi <= lo {
+		return Zone{}, false
+	}
+	size := hi - lo + 1
+	if size&(size-1) != 0 || lo%size != 0 || size > 128 {
+		return Zone{}, false
+	}
+	a[3] = byte(lo)
+	bits := 32
+	for ; size > 1; size >>= 1 {
+		bits--
+	}
+	return Zone{Name: name, Prefix: netip.PrefixFrom(netip.AddrFrom4(a), bits)}, true
+}
+
+// IsReverse reports whether name lies under in-addr.arpa or ip6.arpa.
+func IsReverse(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	return strings.HasSuffix(name, ".in-addr.arpa") || strings.HasSuffix(name, ".ip6.arpa")
+}
+
+// Match returns the most specific zone containing addr. Zones must be ordered
+// as returned by ParseAll.
+func Match(zones []Zone, addr netip.Addr) (Zone, bool) {
+	addr = addr.Unmap()
+	for _, z := range zones {
+		if z.Contains(addr) {
+			return z, true
+		}
+	}
+	return Zone{}, false
+}
+
+// Parent returns the zone a classless zone is carved out of, if it is managed
+// as well. The parent needs CNAMEs pointing into the classless zone.
+func Parent(zones []Zone, z Zone) (Zone, bool) {
+	if !z.Classless() {
+		return Zone{}, false
+	}
+	for _, p := range zones {
+		if p.Prefix.Bits() <= 24 && p.Prefix.Contains(z.Prefix.Addr()) {
+			return p, true
+		}
+	}
+	return Zone{}, false
+}
+
+// octets returns the leading bits/8 octets of addr in reverse order.
+func octets(addr netip.Addr, bits int) string {
+	a := addr.As4()
+	parts := make([]string, 0, 4)
+	for i := bits/8 - 1; i >= 0; i-- {
+		parts = append(parts, strconv.Itoa(int(a[i])))
+	}
+	return strings.Join(parts, ".")
+}
+
+// nibbles returns the leading bits/4 nibbles of addr in reverse order.
+func nibbles(addr netip.Addr, bits int) string {
+	a := addr.As16()
+	parts := make([]string, 0, 32)
+	for i := bits/4 - 1; i >= 0; i-- {
+		b := a[i/2]
+		if i%2 == 0 {
+			b >>= 4
+		}
+		parts = append(parts, strconv.FormatUint(uint64(b&0xf), 16))
+	}
+	return strings.Join(parts, ".")
+}
@@ -5,48 +5,115 @@ import (
 
 	"github.com/DrC0ns0le/bind-api/handlers"
 	"github.com/DrC0ns0le/bind-api/middleware"
+	"github.com/DrC0ns0le/bind-api/policy"
 )
 
 func registerRoutes(mux *http.ServeMux) {
 	middlewareChain := func(handler func(http.ResponseWriter, *http.Request)) http.Handler {
-		return middleware.RESTMiddleware(middleware.LoggerMiddleware(middleware.CorsHandler(http.HandlerFunc(handler))))
+		return middleware.RESTMiddleware(middleware.LoggerMiddleware(middleware.CorsHandler(middleware.AuthMiddleware(http.HandlerFunc(handler)))))
 	}
 
+	// Routes require a role on top of authentication, editors are further
+	// limited to their zones by the handlers, see package policy
+	roleChain := func(role policy.Role) func(func(http.ResponseWriter, *http.Request)) http.Handler {
+		return func(handler func(http.ResponseWriter, *http.Request)) http.Handler {
+			return middlewareChain(middleware.Require(role)(http.HandlerFunc(handler)).ServeHTTP)
+		}
+	}
+	viewerChain := roleChain(policy.Viewer)
+	editorChain := roleChain(policy.Editor)
+	operatorChain := roleChain(policy.Operator)
+	adminChain := roleChain(policy.Admin)
+
 	// CRUD for zones
-	mux.Handle("GET /api/v1/zones", middlewareChain(handlers.GetZonesHandler))
-	mux.Handle("GET /api/v1/zones/{zone_uuid}", middlewareChain(handlers.GetZoneHandler))
-	mux.Handle("POST /api/v1/zones", middlewareChain(handlers.CreateZoneHandler))
+	mux.Handle("GET /api/v1/zones", viewerChain(handlers.GetZonesHandler))
+	mux.Handle("GET /api/v1/zones/{zone_uuid}", viewerChain(handlers.GetZoneHandler))
+	mux.Handle("POST /api/v1/zones", editorChain(handlers.CreateZoneHandler))
 	mux.Handle("OPTIONS /api/v1/zones", middleware.CorsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
-	mux.Handle("PUT /api/v1/zones/{zone_uuid}", middlewareChain(handlers.UpdateZoneHandler))
-	mux.Handle("PATCH /api/v1/zones/{zone_uuid}", middlewareChain(handlers.UpdateZoneHandler))
-	mux.Handle("DELETE /api/v1/zones/{zone_uuid}", middlewareChain(handlers.DeleteZoneHandler))
+	mux.Handle("PUT /api/v1/zones/{zone_uuid}", editorChain(handlers.UpdateZoneHandler))
+	mux.Handle("PATCH /api/v1/zones/{zone_uuid}", editorChain(handlers.UpdateZoneHandler))
+	mux.Handle("DELETE /api/v1/zones/{zone_uuid}", editorChain(handlers.DeleteZoneHandler))
+	mux.Handle("GET /api/v1/zones/{zone_uuid}/history", viewerChain(handlers.GetZoneHistoryHandler))
+
+	// Import zones
+	mux.Handle("POST /api/v1/zones/import", editorChain(handlers.ImportZoneHandler))
+	mux.Handle("POST /api/v1/zones/{zone_uuid}/import", editorChain(handlers.ImportZoneHandler))
+	mux.Handle("POST /api/v1/zones/transfer", editorChain(handlers.TransferZoneHandler))
+
+	// Export zones
+	mux.Handle("GET /api/v1/zones/{zone_uuid}/export", viewerChain(handlers.ExportZoneHandler))
+
+	// Lint zones
+	mux.Handle("GET /api/v1/zones/{zone_uuid}/lint", viewerChain(handlers.LintZoneHandler))
 
 	//CRUD for records
-	mux.Handle("GET /api/v1/zones/{zone_uuid}/records", middlewareChain(handlers.GetZoneRecordsHandler))
-	mux.Handle("GET /api/v1/zones/{zone_uuid}/records/{record_uuid}", middlewareChain(handlers.GetRecordHandler))
-	mux.Handle("POST /api/v1/zones/{zone_uuid}/records", middlewareChain(handlers.CreateRecordHandler))
-	mux.Handle("PUT /api/v1/zones/{zone_uuid}/records/{record_uuid}", middlewareChain(handlers.UpdateRecordHandler))
-	mux.Handle("PATCH /api/v1/zones/{zone_uuid}/records/{record_uuid}", middlewareChain(handlers.UpdateRecordHandler))
-	mux.Handle("DELETE /api/v1/zones/{zone_uuid}/records/{record_uuid}", middlewareChain(handlers.DeleteRecordHandler))
+	mux.Handle("GET /api/v1/zones/{zone_uuid}/records", viewerChain(handlers.GetZoneRecordsHandler))
+	mux.Handle("GET /api/v1/zones/{zone_uuid}/records/{record_uuid}", viewerChain(handlers.GetRecordHandler))
+	mux.Handle("GET /api/v1/zones/{zone_uuid}/records/{record_uuid}/history", viewerChain(handlers.GetRecordHistoryHandler))
+	mux.Handle("POST /api/v1/zones/{zone_uuid}/records", editorChain(handlers.CreateRecordHandler))
+	mux.Handle("PUT /api/v1/zones/{zone_uuid}/records/{record_uuid}", editorChain(handlers.UpdateRecordHandler))
+	mux.Handle("PATCH /api/v1/zones/{zone_uuid}/records/{record_uuid}", editorChain(handlers.UpdateRecordHandler))
+	mux.Handle("DELETE /api/v1/zones/{zone_uuid}/records/{record_uuid}", editorChain(handlers.DeleteRecordHandler))
 
 	//CRUD for configs
-	mux.Handle("GET /api/v1/configs", middlewareChain(handlers.GetConfigsHandler))
-	mux.Handle("GET /api/v1/configs/{config_key}", middlewareChain(handlers.GetConfigHandler))
-	mux.Handle("POST /api/v1/configs", middlewareChain(handlers.CreateConfigHandler))
-	mux.Handle("PUT /api/v1/configs", middlewareChain(handlers.UpdateConfigHandler))
-	mux.Handle("PATCH /api/v1/configs", middlewareChain(handlers.UpdateConfigHandler))
-	mux.Handle("DELETE /api/v1/configs", middlewareChain(handlers.DeleteConfigHandler))
+	mux.Handle("GET /api/v1/configs", viewerChain(handlers.GetConfigsHandler))
+	mux.Handle("GET /api/v1/configs/{config_key}", viewerChain(handlers.GetConfigHandler))
+	mux.Handle("POST /api/v1/configs", operatorChain(handlers.CreateConfigHandler))
+	mux.Handle("PUT /api/v1/configs", operatorChain(handlers.UpdateConfigHandler))
+	mux.Handle("PATCH /api/v1/configs", operatorChain(handlers.UpdateConfigHandler))
+	mux.Handle("DELETE /api/v1/configs", operatorChain(handlers.DeleteConfigHandler))
 
 	// Render Zones
-	mux.Handle("GET /api/v1/render", middlewareChain(handlers.GetRendersHandler))
+	mux.Handle("GET /api/v1/render", viewerChain(handlers.GetRendersHandler))
 
 	// Stage
-	mux.Handle("GET /api/v1/staging", middlewareChain(handlers.GetStagingHandler))
-	mux.Handle("POST /api/v1/staging", middlewareChain(handlers.ApplyStagingHandler))
+	mux.Handle("GET /api/v1/staging", viewerChain(handlers.GetStagingHandler))
+	mux.Handle("POST /api/v1/staging", operatorChain(handlers.ApplyStagingHandler))
+	mux.Handle("DELETE /api/v1/staging", operatorChain(handlers.DiscardStagingHandler))
+	mux.Handle("DELETE /api/v1/zones/{zone_uuid}/staging", editorChain(handlers.DiscardZoneStagingHandler))
+	mux.Handle("DELETE /api/v1/zones/{zone_uuid}/records/{record_uuid}/staging", editorChain(handlers.DiscardRecordStagingHandler))
+
+	// Changesets
+	mux.Handle("GET /api/v1/changesets", viewerChain(handlers.GetChangesetsHandler))
+	mux.Handle("GET /api/v1/changesets/{id}", viewerChain(handlers.GetChangesetHandler))
+	mux.Handle("POST /api/v1/changesets/{id}/rollback", operatorChain(handlers.RollbackChangesetHandler))
+
+	// Change requests
+	mux.Handle("GET /api/v1/change-requests", viewerChain(handlers.GetChangeRequestsHandler))
+	mux.Handle("GET /api/v1/change-requests/{id}", viewerChain(handlers.GetChangeRequestHandler))
+	mux.Handle("POST /api/v1/change-requests", editorChain(handlers.CreateChangeRequestHandler))
+	mux.Handle("DELETE /api/v1/change-requests/{id}", editorChain(handlers.CloseChangeRequestHandler))
+	mux.Handle("POST /api/v1/change-requests/{id}/changes", editorChain(handlers.AddChangeHandler))
+	mux.Handle("DELETE /api/v1/change-requests/{id}/changes/{index}", editorChain(handlers.RemoveChangeHandler))
+	mux.Handle("POST /api/v1/change-requests/{id}/approve", editorChain(handlers.ApproveChangeRequestHandler))
+	mux.Handle("POST /api/v1/change-requests/{id}/apply", operatorChain(handlers.ApplyChangeRequestHandler))
+
+	// Servers deployed to
+	mux.Handle("GET /api/v1/servers", viewerChain(handlers.GetServersHandler))
+	mux.Handle("GET /api/v1/servers/inventory", viewerChain(handlers.GetInventoryHandler))
+	mux.Handle("POST /api/v1/servers", operatorChain(handlers.CreateServerHandler))
+	mux.Handle("PUT /api/v1/servers/{host}", operatorChain(handlers.UpdateServerHandler))
+	mux.Handle("PATCH /api/v1/servers/{host}", operatorChain(handlers.UpdateServerHandler))
+	mux.Handle("DELETE /api/v1/servers/{host}", operatorChain(handlers.DeleteServerHandler))
+
+	// Scheduled applies
+	mux.Handle("GET /api/v1/schedule", viewerChain(handlers.GetScheduleHandler))
+	mux.Handle("DELETE /api/v1/schedule/{id}", operatorChain(handlers.CancelScheduledApplyHandler))
 
 	// Deploy
-	mux.Handle("GET /api/v1/deploy", middlewareChain(handlers.GetDeployHandler))
-	mux.Handle("POST /api/v1/deploy", middlewareChain(handlers.DeployHandler))
+	mux.Handle("GET /api/v1/deploy", viewerChain(handlers.GetDeployHandler))
+	mux.Handle("POST /api/v1/deploy", operatorChain(handlers.DeployHandler))
+	mux.Handle("GET /api/v1/deploy/jobs", viewerChain(handlers.GetDeployJobsHandler))
+	mux.Handle("GET /api/v1/deploy/jobs/{id}", viewerChain(handlers.GetDeployJobHandler))
+	mux.Handle("GET /api/v1/deploy/jobs/{id}/log", viewerChain(handlers.DeployJobLogHandler))
+
+	// Audit log
+	mux.Handle("GET /api/v1/audit", viewerChain(handlers.GetAuditHandler))
+
+	// API tokens
+	mux.Handle("GET /api/v1/tokens", adminChain(handlers.GetTokensHandler))
+	mux.Handle("POST /api/v1/tokens", adminChain(handlers.CreateTokenHandler))
+	mux.Handle("DELETE /api/v1/tokens/{token_uuid}", adminChain(handlers.RevokeTokenHandler))
 
 	// Health check
 	mux.Handle("GET /api/v1/health", middleware.CorsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+// NameServers validates the NS set of zone. Every host must be a valid name
+// and appear once, glue addresses must be valid and are only allowed for
+// name servers within the zone.
+//
+// Returns nil when the set is valid.
+func NameServers(zone string, ns []rdb.NameServer) Errors {
+	var errs Errors
+
+	if len(ns) == 0 {
+		errs.add("name_servers", "at least one name server is required")
+		return errs
+	}
+
+	seen := make(map[string]bool)
+	for i, server := range ns {
+		field := fmt.Sprintf("name_servers[%d]", i)
+
+		host := strings.TrimSpace(server.Host)
+		if host == "" || host == "@" {
+			errs.add(field+".host", "must be a name server host name")
+			continue
+		}
+		if err := checkHostname(host); err != nil {
+			errs.add(field+".host", "%s", err)
+			continue
+		}
+
+		name := InZone(host, zone)
+		if seen[name] {
+			errs.add(field+".host", "%s is listed more than once", host)
+		}
+		seen[name] = true
+
+		if len(server.Addresses) > 0 && !IsInZone(host, zone) {
+			errs.add(field+".addresses", "glue is only allowed for name servers within %s, %s is outside of it", zone, host)
+		}
+		for j, a := range server.Addresses {
+			if addr, err := netip.ParseAddr(strings.TrimSpace(a)); err != nil || addr.Zone() != "" {
+				errs.add(fmt.Sprintf("%s.addresses[%d]", field, j), "%q is not an IP address", a)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// InZone returns the absolute, lower case form of a host relative to zone,
+// without the trailing dot.
+func InZone(host, zone string) string {
+	switch {
+	case host == "@":
+		host = zone
+	case !strings.HasSuffix(host, "."):
+		host = host + "." + zone
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// IsInZone reports whether host, relative to zone, lies within it.
+func IsInZone(host, zone string) bool {
+	name := InZone(host, zone)
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
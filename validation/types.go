@@ -0,0 +1,283 @@
+package validation
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// contentValidators check the content of a record, keyed by record type.
+// Types without an entry are checked by parsing them as a zone file line.
+var contentValidators = map[string]func(fields []string, content string) error{
+	"A":     checkA,
+	"AAAA":  checkAAAA,
+	"CNAME": checkCNAME,
+	"NS":    checkTarget,
+	"PTR":   checkTarget,
+	"MX":    checkMX,
+	"TXT":   checkTXT,
+	"SRV":   checkSRV,
+	"CAA":   checkCAA,
+	"TLSA":  checkTLSA,
+	"SSHFP": checkSSHFP,
+}
+
+func checkContent(recordType, content string) error {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return fmt.Errorf("must not be empty")
+	}
+
+	if validator, ok := contentValidators[recordType]; ok {
+		return validator(strings.Fields(content), content)
+	}
+
+	if _, err := dns.NewRR(fmt.Sprintf("@ 3600 IN %s %s", recordType, content)); err != nil {
+		return fmt.Errorf("invalid %s content: %w", recordType, err)
+	}
+	return nil
+}
+
+func expectFields(fields []string, n int, format string) error {
+	if len(fields) != n {
+		return fmt.Errorf("expected %q, got %d fields", format, len(fields))
+	}
+	return nil
+}
+
+// checkUint parses a decimal number of at most max.
+func checkUint(name, s string, max uint64) (uint64, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || v > max {
+		return 0, fmt.Errorf("%s must be a number between 0 and %d, got %q", name, max, s)
+	}
+	return v, nil
+}
+
+func checkHex(name, s string, length int) error {
+	if _, err := hex.DecodeString(s); err != nil {
+		return fmt.Errorf("%s must be hexadecimal", name)
+	}
+	if length > 0 && len(s) != length {
+		return fmt.Errorf("%s must be %d hex digits long, got %d", name, length, len(s))
+	}
+	return nil
+}
+
+func checkA(fields []string, content string) error {
+	addr, err := netip.ParseAddr(content)
+	if err != nil || !addr.Is4() {
+		return fmt.Errorf("%q is not an IPv4 address", content)
+	}
+	return nil
+}
+
+func checkAAAA(fields []string, content string) error {
+	addr, err := netip.ParseAddr(content)
+	if err != nil || !addr.Is6() || addr.Zone() != "" {
+		return fmt.Errorf("%q is not an IPv6 address", content)
+	}
+	return nil
+}
+
+// checkCNAME requires a fully qualified target, as render appends the trailing dot.
+func checkCNAME(fields []string, content string) error {
+	if err := expectFields(fields, 1, "target"); err != nil {
+		return err
+	}
+	target := strings.TrimSuffix(content, ".")
+	if err := checkHostname(target); err != nil {
+		return err
+	}
+	if !strings.Contains(target, ".") {
+		return fmt.Errorf("CNAME target %q must be a fully qualified domain name", content)
+	}
+	return nil
+}
+
+func checkTarget(fields []string, content string) error {
+	if err := expectFields(fields, 1, "target"); err != nil {
+		return err
+	}
+	return checkHostname(content)
+}
+
+func checkMX(fields []string, content string) error {
+	if err := expectFields(fields, 2, "preference exchange"); err != nil {
+		return err
+	}
+	if _, err := checkUint("preference", fields[0], 65535); err != nil {
+		return err
+	}
+	return checkHostname(fields[1])
+}
+
+func checkTXT(fields []string, content string) error {
+	if _, err := dns.NewRR("@ 3600 IN TXT " + content); err != nil {
+		return fmt.Errorf("invalid TXT content: %w", err)
+	}
+	// the parser silently splits long strings, so measure them as written
+	for _, s := range characterStrings(content) {
+		if n := len(unescape(s)); n > 255 {
+			return fmt.Errorf("TXT strings are limited to 255 bytes, got one of %d bytes, split it into several quoted strings", n)
+		}
+	}
+	return nil
+}
+
+// characterStrings splits presentation format content into its quoted or
+// space separated strings, still escaped.
+func characterStrings(content string) []string {
+	var strs []string
+	for i := 0; i < len(content); {
+		switch c := content[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(content) && content[j] != '"' {
+				if content[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			strs = append(strs, content[i+1:min(j, len(content))])
+			i = j + 1
+		default:
+			j := i
+			for j < len(content) && content[j] != ' ' && content[j] != '\t' {
+				if content[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			strs = append(strs, content[i:min(j, len(content))])
+			i = j
+		}
+	}
+	return strs
+}
+
+func checkSRV(fields []string, content string) error {
+	if err := expectFields(fields, 4, "priority weight port target"); err != nil {
+		return err
+	}
+	for i, name := range []string{"priority", "weight", "port"} {
+		if _, err := checkUint(name, fields[i], 65535); err != nil {
+			return err
+		}
+	}
+	if fields[3] == "." {
+		return nil
+	}
+	return checkHostname(fields[3])
+}
+
+func checkCAA(fields []string, content string) error {
+	if len(fields) < 3 {
+		return fmt.Errorf("expected %q, got %d fields", `flags tag "value"`, len(fields))
+	}
+	if _, err := checkUint("flags", fields[0], 255); err != nil {
+		return err
+	}
+
+	tag := fields[1]
+	for _, c := range tag {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return fmt.Errorf("CAA tag %q must be alphanumeric", tag)
+		}
+	}
+	switch strings.ToLower(tag) {
+	case "issue", "issuewild", "iodef", "issuemail", "issuevmc", "contactemail", "contactphone":
+	default:
+		return fmt.Errorf("unknown CAA tag %q", tag)
+	}
+
+	if _, err := dns.NewRR("@ 3600 IN CAA " + content); err != nil {
+		return fmt.Errorf("invalid CAA content: %w", err)
+	}
+	return nil
+}
+
+func checkTLSA(fields []string, content string) error {
+	if len(fields) < 4 {
+		return fmt.Errorf("expected %q, got %d fields", "usage selector matching-type data", len(fields))
+	}
+	if _, err := checkUint("usage", fields[0], 3); err != nil {
+		return err
+	}
+	if _, err := checkUint("selector", fields[1], 1); err != nil {
+		return err
+	}
+	matching, err := checkUint("matching type", fields[2], 2)
+	if err != nil {
+		return err
+	}
+
+	// the certificate data may be split over several fields
+	data := strings.Join(fields[3:], "")
+	switch matching {
+	case 1:
+		return checkHex("SHA-256 data", data, 64)
+	case 2:
+		return checkHex("SHA-512 data", data, 128)
+	default:
+		return checkHex("certificate data", data, 0)
+	}
+}
+
+func checkSSHFP(fields []string, content string) error {
+	if err := expectFields(fields, 3, "algorithm type fingerprint"); err != nil {
+		return err
+	}
+	algorithm, err := checkUint("algorithm", fields[0], 255)
+	if err != nil {
+		return err
+	}
+	switch algorithm {
+	case 1, 2, 3, 4, 6:
+	default:
+		return fmt.Errorf("unknown SSHFP algorithm %d, expected 1 (RSA), 2 (DSA), 3 (ECDSA), 4 (Ed25519) or 6 (Ed448)", algorithm)
+	}
+
+	fpType, err := checkUint("fingerprint type", fields[1], 255)
+	if err != nil {
+		return err
+	}
+	switch fpType {
+	case 1:
+		return checkHex("SHA-1 fingerprint", fields[2], 40)
+	case 2:
+		return checkHex("SHA-256 fingerprint", fields[2], 64)
+	default:
+		return fmt.Errorf("unknown SSHFP fingerprint type %d, expected 1 (SHA-1) or 2 (SHA-256)", fpType)
+	}
+}
+
+// unescape resolves the \X and \DDD escapes of a presentation format string.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 < len(s) && isDigit(s[i+1]) && isDigit(s[i+2]) && isDigit(s[i+3]) {
+			v, _ := strconv.Atoi(s[i+1 : i+4])
+			b.WriteByte(byte(v))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i+1])
+		i++
+	}
+	return b.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
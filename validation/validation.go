@@ -0,0 +1,162 @@
+// Package validation checks records before they are staged, so that mistakes
+// surface in the API response instead of when BIND refuses to load the zone.
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/miekg/dns"
+)
+
+// TTL bounds enforced on records, adjustable through configuration.
+var (
+	MinTTL uint16 = 60
+	MaxTTL uint16 = 65535
+)
+
+// FieldError is a problem with one field of a record.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists every problem found in a record.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *Errors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// unmanagedTypes cannot be created as records, they are generated by BIND or the zone itself.
+var unmanagedTypes = map[string]string{
+	"SOA":        "SOA is set through the zone",
+	"RRSIG":      "DNSSEC records are generated by the signer",
+	"NSEC":       "DNSSEC records are generated by the signer",
+	"NSEC3":      "DNSSEC records are generated by the signer",
+	"NSEC3PARAM": "DNSSEC records are generated by the signer",
+}
+
+// Record validates the type, host, content and TTL of a record.
+//
+// Returns nil when the record is valid.
+func Record(r rdb.Record) Errors {
+	var errs Errors
+
+	recordType := strings.ToUpper(r.Type)
+	if _, ok := dns.StringToType[recordType]; !ok {
+		errs.add("type", "unknown record type %q", r.Type)
+	} else if reason, ok := unmanagedTypes[recordType]; ok {
+		errs.add("type", "%s records cannot be managed directly: %s", recordType, reason)
+	}
+
+	if err := checkHost(recordType, r.Host); err != nil {
+		errs.add("host", "%s", err)
+	}
+
+	if r.TTL < MinTTL || r.TTL > MaxTTL {
+		errs.add("ttl", "must be between %d and %d, got %d", MinTTL, MaxTTL, r.TTL)
+	}
+
+	if len(errs) == 0 || errs[0].Field != "type" {
+		if err := checkContent(recordType, r.Content); err != nil {
+			errs.add("content", "%s", err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// checkHost validates the owner name of a record, relative to its zone.
+func checkHost(recordType, host string) error {
+	if host == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	if host == "@" {
+		return nil
+	}
+
+	name := strings.TrimSuffix(host, ".")
+	if _, ok := dns.IsDomainName(name); !ok || len(name) > 253 {
+		return fmt.Errorf("%q is not a valid domain name", host)
+	}
+
+	labels := dns.SplitDomainName(name)
+	for i, label := range labels {
+		if label == "*" && i == 0 {
+			continue
+		}
+		if err := checkLabel(label); err != nil {
+			return fmt.Errorf("label %q %w", label, err)
+		}
+	}
+
+	// Service records live under _service._proto names
+	switch recordType {
+	case "SRV", "TLSA":
+		if len(labels) < 2 || !strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+			return fmt.Errorf("%s records must be owned by a _service._proto name, got %q", recordType, host)
+		}
+	}
+
+	return nil
+}
+
+// checkLabel accepts letters, digits, hyphens and underscores,
+// without a leading or trailing hyphen.
+func checkLabel(label string) error {
+	if len(label) == 0 || len(label) > 63 {
+		return fmt.Errorf("must be 1 to 63 characters long")
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return fmt.Errorf("must not start or end with a hyphen")
+	}
+	for _, c := range label {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return fmt.Errorf("contains invalid character %q", c)
+		}
+	}
+	return nil
+}
+
+// checkHostname validates a domain name used inside record content, which
+// may be relative to the zone or fully qualified.
+func checkHostname(name string) error {
+	if name == "@" {
+		return nil
+	}
+	if _, ok := dns.IsDomainName(name); !ok || len(strings.TrimSuffix(name, ".")) > 253 {
+		return fmt.Errorf("%q is not a valid domain name", name)
+	}
+	for _, label := range dns.SplitDomainName(name) {
+		if err := checkLabel(label); err != nil {
+			return fmt.Errorf("label %q of %q %w", label, name, err)
+		}
+	}
+	return nil
+}
+
+// Normalize brings a record into the form render expects: upper case type,
+// trimmed content and CNAME targets without the trailing dot render adds.
+func Normalize(r *rdb.Record) {
+	r.Type = strings.ToUpper(strings.TrimSpace(r.Type))
+	r.Host = strings.TrimSpace(r.Host)
+	r.Content = strings.TrimSpace(r.Content)
+	if r.Type == "CNAME" {
+		r.Content = strings.TrimSuffix(r.Content, ".")
+	}
+}
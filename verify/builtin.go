@@ -0,0 +1,260 @@
+package verify
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// zoneStatement is a zone declared in a named configuration.
+type zoneStatement struct {
+	Name string
+	Type string
+	File string
+}
+
+type confToken struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+// statement is a named.conf statement, optionally followed by a block.
+type statement struct {
+	words []confToken
+	block []statement
+}
+
+// parseConf checks the syntax of a named configuration and returns the zones
+// it declares.
+func parseConf(conf []byte) ([]zoneStatement, error) {
+	tokens, err := tokenizeConf(string(conf))
+	if err != nil {
+		return nil, err
+	}
+
+	stmts, pos, err := parseStatements(tokens, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	if pos != len(tokens) {
+		return nil, fmt.Errorf("line %d: unexpected %q", tokens[pos].line, tokens[pos].text)
+	}
+
+	var zones []zoneStatement
+	for _, s := range stmts {
+		if len(s.words) == 0 || s.words[0].text != "zone" {
+			continue
+		}
+		if len(s.words) < 2 {
+			return nil, fmt.Errorf("line %d: zone statement without a name", s.words[0].line)
+		}
+		z := zoneStatement{Name: s.words[1].text}
+		for _, opt := range s.block {
+			if len(opt.words) != 2 {
+				continue
+			}
+			switch opt.words[0].text {
+			case "type":
+				z.Type = opt.words[1].text
+			case "file":
+				z.File = opt.words[1].text
+			}
+		}
+		if z.Type == "" {
+			return nil, fmt.Errorf("line %d: zone %q has no type", s.words[0].line, z.Name)
+		}
+		if (z.Type == "master" || z.Type == "primary") && z.File == "" {
+			return nil, fmt.Errorf("line %d: zone %q has no file", s.words[0].line, z.Name)
+		}
+		zones = append(zones, z)
+	}
+
+	return zones, nil
+}
+
+// parseStatements reads statements up to the end of input, or up to the
+// closing brace when nested.
+func parseStatements(tokens []confToken, pos int, nested bool) ([]statement, int, error) {
+	var stmts []statement
+	for {
+		if pos >= len(tokens) {
+			if nested {
+				return nil, pos, fmt.Errorf("missing closing brace")
+			}
+			return stmts, pos, nil
+		}
+		if t := tokens[pos]; t.text == "}" && !t.quoted {
+			if !nested {
+				return nil, pos, fmt.Errorf("line %d: unexpected closing brace", t.line)
+			}
+			return stmts, pos, nil
+		}
+
+		var s statement
+		for {
+			if pos >= len(tokens) {
+				return nil, pos, fmt.Errorf("line %d: missing ';'", tokens[len(tokens)-1].line)
+			}
+			t := tokens[pos]
+			if t.quoted || (t.text != ";" && t.text != "{" && t.text != "}") {
+				s.words = append(s.words, t)
+				pos++
+				continue
+			}
+			if t.text == "}" {
+				return nil, pos, fmt.Errorf("line %d: missing ';' before closing brace", t.line)
+			}
+			if t.text == "{" {
+				block, next, err := parseStatements(tokens, pos+1, true)
+				if err != nil {
+					return nil, next, err
+				}
+				s.block = block
+				// skip the closing brace, a ';' must follow it
+				pos = next + 1
+				if pos >= len(tokens) || tokens[pos].text != ";" || tokens[pos].quoted {
+					return nil, pos, fmt.Errorf("line %d: missing ';' after closing brace", tokens[next].line)
+				}
+			}
+			pos++
+			break
+		}
+		stmts = append(stmts, s)
+	}
+}
+
+// tokenizeConf splits a named configuration into words, quoted strings and
+// punctuation, dropping comments.
+func tokenizeConf(conf string) ([]confToken, error) {
+	var tokens []confToken
+	line := 1
+	for i := 0; i < len(conf); {
+		c := conf[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(conf[i:], "//"):
+			for i < len(conf) && conf[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(conf[i:], "/*"):
+			end := strings.Index(conf[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(conf[i:i+2+end], "\n")
+			i += end + 4
+		case c == '{' || c == '}' || c == ';':
+			tokens = append(tokens, confToken{text: string(c), line: line})
+			i++
+		case c == '"':
+			end := strings.IndexByte(conf[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			tokens = append(tokens, confToken{text: conf[i+1 : i+1+end], quoted: true, line: line})
+			line += strings.Count(conf[i+1:i+1+end], "\n")
+			i += end + 2
+		default:
+			j := i
+			for j < len(conf) && !strings.ContainsRune(" \t\r\n{};\"#", rune(conf[j])) {
+				j++
+			}
+			tokens = append(tokens, confToken{text: conf[i:j], line: line})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// checkZoneFile loads a zone file and performs the checks named-checkzone
+// fails on: syntax, the apex SOA and NS records, CNAMEs alongside other
+// data and in-zone name servers without addresses.
+func checkZoneFile(path, zone string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer f.Close()
+
+	origin := dns.CanonicalName(zone)
+	zp := dns.NewZoneParser(f, origin, path)
+
+	var rrs []dns.RR
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return []string{err.Error()}
+	}
+
+	var problems []string
+	types := make(map[string]map[uint16]int)
+	var nameServers []string
+	for _, rr := range rrs {
+		h := rr.Header()
+		name := dns.CanonicalName(h.Name)
+		if !dns.IsSubDomain(origin, name) {
+			// named-checkzone ignores out-of-zone data as well
+			continue
+		}
+		if types[name] == nil {
+			types[name] = make(map[uint16]int)
+		}
+		types[name][h.Rrtype]++
+
+		if ns, ok := rr.(*dns.NS); ok && name == origin {
+			nameServers = append(nameServers, dns.CanonicalName(ns.Ns))
+		}
+	}
+
+	switch n := types[origin][dns.TypeSOA]; {
+	case n == 0:
+		problems = append(problems, fmt.Sprintf("zone %s: has no SOA record", zone))
+	case n > 1:
+		problems = append(problems, fmt.Sprintf("zone %s: multiple RRs of singleton type SOA", zone))
+	}
+	if types[origin][dns.TypeNS] == 0 {
+		problems = append(problems, fmt.Sprintf("zone %s: has no NS records", zone))
+	}
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		n := types[name][dns.TypeCNAME]
+		if n == 0 {
+			continue
+		}
+		if n > 1 {
+			problems = append(problems, fmt.Sprintf("%s: multiple RRs of singleton type CNAME", name))
+		}
+		for t := range types[name] {
+			if t != dns.TypeCNAME && t != dns.TypeRRSIG && t != dns.TypeNSEC {
+				problems = append(problems, fmt.Sprintf("%s: CNAME and other data", name))
+				break
+			}
+		}
+	}
+
+	for _, ns := range nameServers {
+		if !dns.IsSubDomain(origin, ns) {
+			continue
+		}
+		if types[ns][dns.TypeA] == 0 && types[ns][dns.TypeAAAA] == 0 {
+			problems = append(problems, fmt.Sprintf("zone %s: NS '%s' has no address records (A or AAAA)", zone, ns))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,145 @@
+// Package verify checks rendered output the way BIND will load it, before it
+// is committed. named-checkconf and named-checkzone are used when they are
+// installed, otherwise a built-in parser performs the essential checks.
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const namedConfZones = "named.conf.zones"
+
+// Checker commands, adjustable through configuration. An empty command or
+// one that is not found on PATH selects the built-in checks.
+var (
+	CheckConfCmd = "named-checkconf"
+	CheckZoneCmd = "named-checkzone"
+)
+
+const builtinChecker = "builtin"
+
+// Result is the outcome of checking one file.
+type Result struct {
+	File    string `json:"file"`
+	Zone    string `json:"zone,omitempty"`
+	Checker string `json:"checker"`
+	OK      bool   `json:"ok"`
+	Output  string `json:"output,omitempty"`
+}
+
+// Report is the outcome of checking a rendered output directory.
+type Report struct {
+	OK      bool     `json:"ok"`
+	Results []Result `json:"results"`
+}
+
+// Failed returns the results that did not pass.
+func (rep Report) Failed() []Result {
+	var failed []Result
+	for _, res := range rep.Results {
+		if !res.OK {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+func (rep *Report) add(res Result) {
+	rep.Results = append(rep.Results, res)
+	if !res.OK {
+		rep.OK = false
+	}
+}
+
+// Dir checks named.conf.zones in dir and every master zone it declares.
+//
+// Zone files are looked up in dir by base name, since the configuration refers
+// to their location on the BIND servers. An error is returned only when the
+// checks could not be run; failed checks are reported in the Report.
+func Dir(ctx context.Context, dir string) (Report, error) {
+	rep := Report{OK: true, Results: []Result{}}
+
+	confPath := filepath.Join(dir, namedConfZones)
+	conf, err := os.ReadFile(confPath)
+	if err != nil {
+		return rep, err
+	}
+
+	zones, err := parseConf(conf)
+	if checker, ok := lookPath(CheckConfCmd); ok {
+		output, runErr := run(ctx, checker, confPath)
+		rep.add(Result{File: namedConfZones, Checker: CheckConfCmd, OK: runErr == nil, Output: output})
+	} else {
+		res := Result{File: namedConfZones, Checker: builtinChecker, OK: err == nil}
+		if err != nil {
+			res.Output = err.Error()
+		}
+		rep.add(res)
+	}
+	if err != nil {
+		// without a parsable configuration the zones are unknown
+		return rep, nil
+	}
+
+	checker, useNamed := lookPath(CheckZoneCmd)
+	for _, z := range zones {
+		if z.Type != "master" && z.Type != "primary" {
+			continue
+		}
+
+		file := filepath.Base(z.File)
+		path := filepath.Join(dir, file)
+		if _, err := os.Stat(path); err != nil {
+			rep.add(Result{File: file, Zone: z.Name, Checker: builtinChecker, OK: false, Output: err.Error()})
+			continue
+		}
+
+		if useNamed {
+			output, err := run(ctx, checker, z.Name, path)
+			rep.add(Result{File: file, Zone: z.Name, Checker: CheckZoneCmd, OK: err == nil, Output: output})
+			continue
+		}
+
+		res := Result{File: file, Zone: z.Name, Checker: builtinChecker, OK: true}
+		if problems := checkZoneFile(path, z.Name); len(problems) > 0 {
+			res.OK = false
+			res.Output = strings.Join(problems, "\n")
+		}
+		rep.add(res)
+	}
+
+	return rep, nil
+}
+
+func lookPath(cmd string) (string, bool) {
+	if cmd == "" {
+		return "", false
+	}
+	path, err := exec.LookPath(cmd)
+	return path, err == nil
+}
+
+// run executes a checker, returning its combined output and an error when it
+// exits unsuccessfully.
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	output := strings.TrimSpace(out.String())
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		output = strings.TrimSpace(fmt.Sprintf("%s\n%s", output, err))
+	}
+	return output, err
+}
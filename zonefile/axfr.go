@@ -0,0 +1,109 @@
+package zonefile
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const transferTimeout = 30 * time.Second
+
+// TSIG holds the key used to sign a zone transfer.
+type TSIG struct {
+	Name      string `json:"name"`      // Key name
+	Algorithm string `json:"algorithm"` // Defaults to hmac-sha256
+	Secret    string `json:"secret"`    // Base64 encoded secret
+}
+
+// ParseTSIGKeys reads the TSIG keys zones may be transferred with from JSON
+// such as
+//
+//	{"transfer-key": {"algorithm": "hmac-sha256", "secret": "c2VjcmV0"}}
+//
+// Keys are named by their key in the object.
+func ParseTSIGKeys(b []byte) (map[string]TSIG, error) {
+	var keys map[string]TSIG
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("invalid TSIG keys: %w", err)
+	}
+	for name, key := range keys {
+		if _, err := base64.StdEncoding.DecodeString(key.Secret); err != nil || key.Secret == "" {
+			return nil, fmt.Errorf("TSIG key %q: secret must be base64 encoded", name)
+		}
+		key.Name = name
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+// Transfer fetches every record of zone from server by AXFR.
+//
+// The server may omit the port, 53 is used then. The closing SOA record that
+// ends an AXFR response is dropped, so the result can go straight to Convert.
+func Transfer(ctx context.Context, zone, server string, tsig *TSIG) ([]dns.RR, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	timeout := transferTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	t := &dns.Transfer{
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+	}
+
+	m := new(dns.Msg)
+	m.SetAxfr(dns.Fqdn(zone))
+
+	if tsig != nil && tsig.Name != "" {
+		name := dns.Fqdn(tsig.Name)
+		algorithm := dns.HmacSHA256
+		if tsig.Algorithm != "" {
+			algorithm = dns.Fqdn(tsig.Algorithm)
+		}
+		t.TsigSecret = map[string]string{name: tsig.Secret}
+		m.SetTsig(name, algorithm, 300, time.Now().Unix())
+	}
+
+	env, err := t.In(m, server)
+	if err != nil {
+		return nil, fmt.Errorf("zone transfer from %s failed: %w", server, err)
+	}
+
+	var rrs []dns.RR
+	for e := range env {
+		if e.Error != nil {
+			return nil, fmt.Errorf("zone transfer from %s failed: %w", server, e.Error)
+		}
+		rrs = append(rrs, e.RR...)
+
+		select {
+		case <-ctx.Done():
+			// let the transfer goroutine finish
+			go func() {
+				for range env {
+				}
+			}()
+			return nil, ctx.Err()
+		default:
+		}
+	}
+
+	if len(rrs) == 0 || rrs[0].Header().Rrtype != dns.TypeSOA {
+		return nil, fmt.Errorf("zone transfer from %s did not start with a SOA record", server)
+	}
+	if len(rrs) > 1 && rrs[len(rrs)-1].Header().Rrtype == dns.TypeSOA {
+		rrs = rrs[:len(rrs)-1]
+	}
+
+	return rrs, nil
+}
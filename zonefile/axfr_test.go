@@ -0,0 +1,151 @@
+package zonefile
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	testKey    = "transfer-key."
+	testSecret = "c2VjcmV0IHRyYW5zZmVyIGtleSBvZiB0aGUgdGVzdHM="
+)
+
+// serveAXFR starts a DNS server answering AXFR of example.com with
+// messages, each sent as a message of the transfer. Transfers must be signed
+// with the test key when requireTSIG is set.
+func serveAXFR(t *testing.T, requireTSIG bool, messages ...[]dns.RR) string {
+	t.Helper()
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		signed := r.IsTsig() != nil && w.TsigStatus() == nil
+		if r.Question[0].Qtype != dns.TypeAXFR || r.Question[0].Name != "example.com." || (requireTSIG && !signed) {
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeRefused)
+			w.WriteMsg(m)
+			return
+		}
+
+		ch := make(chan *dns.Envelope)
+		go func() {
+			for _, rrs := range messages {
+				ch <- &dns.Envelope{RR: rrs}
+			}
+			close(ch)
+		}()
+		new(dns.Transfer).Out(w, r, ch)
+		w.Close()
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	srv := &dns.Server{
+		Listener:          l,
+		Handler:           handler,
+		TsigSecret:        map[string]string{testKey: testSecret},
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+	return l.Addr().String()
+}
+
+func mustRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rr
+}
+
+func TestTransfer(t *testing.T) {
+	soa := mustRR(t, "example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 2024030101 7200 3600 1209600 300")
+	ns := mustRR(t, "example.com. 3600 IN NS ns1.example.com.")
+	www := mustRR(t, "www.example.com. 300 IN A 192.0.2.10")
+	mail := mustRR(t, "example.com. 300 IN MX 10 mail.example.com.")
+	zone := [][]dns.RR{{soa, ns}, {www, mail}, {soa}}
+
+	tests := []struct {
+		name        string
+		requireTSIG bool
+		messages    [][]dns.RR
+		zone        string
+		tsig        *TSIG
+		want        []dns.RR
+		err         string // part of the error, empty when transferred
+	}{
+		{name: "unsigned", messages: zone, zone: "example.com", want: []dns.RR{soa, ns, www, mail}},
+		{name: "signed", requireTSIG: true, messages: zone, zone: "example.com.", tsig: &TSIG{Name: "transfer-key", Secret: testSecret}, want: []dns.RR{soa, ns, www, mail}},
+		{name: "signed by hmac-sha512", requireTSIG: true, messages: zone, zone: "example.com", tsig: &TSIG{Name: testKey, Algorithm: "hmac-sha512", Secret: testSecret}, want: []dns.RR{soa, ns, www, mail}},
+		{name: "unused key", messages: zone, zone: "example.com", tsig: &TSIG{Name: testKey, Secret: testSecret}, want: []dns.RR{soa, ns, www, mail}},
+		{name: "key name only", requireTSIG: true, messages: zone, zone: "example.com", tsig: &TSIG{}, err: "zone transfer from"},
+		{name: "unsigned refused", requireTSIG: true, messages: zone, zone: "example.com", err: "zone transfer from"},
+		{name: "wrong secret", requireTSIG: true, messages: zone, zone: "example.com", tsig: &TSIG{Name: testKey, Secret: "d3Jvbmcgc2VjcmV0"}, err: "zone transfer from"},
+		{name: "unknown algorithm", requireTSIG: true, messages: zone, zone: "example.com", tsig: &TSIG{Name: testKey, Algorithm: "hmac-whirlpool", Secret: testSecret}, err: "zone transfer from"},
+		{name: "unknown zone", messages: zone, zone: "example.net", err: "zone transfer from"},
+		{name: "only the SOA", messages: [][]dns.RR{{soa}, {soa}}, zone: "example.com", want: []dns.RR{soa}},
+		{name: "no SOA first", messages: [][]dns.RR{{www, soa}}, zone: "example.com", err: "no SOA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveAXFR(t, tt.requireTSIG, tt.messages...)
+			rrs, err := Transfer(context.Background(), tt.zone, server, tt.tsig)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Transfer() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Transfer() error = %v", err)
+			}
+			if len(rrs) != len(tt.want) {
+				t.Fatalf("Transfer() = %v, want %v", rrs, tt.want)
+			}
+			for i := range rrs {
+				if !dns.IsDuplicate(rrs[i], tt.want[i]) {
+					t.Errorf("Transfer() record %d = %v, want %v", i, rrs[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTransferCanceled(t *testing.T) {
+	soa := mustRR(t, "example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300")
+	server := serveAXFR(t, false, []dns.RR{soa}, []dns.RR{soa})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Transfer(ctx, "example.com", server, nil); err != context.Canceled {
+		t.Fatalf("Transfer() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestParseTSIGKeys(t *testing.T) {
+	keys, err := ParseTSIGKeys([]byte(`{"transfer-key": {"algorithm": "hmac-sha512", "secret": "` + testSecret + `"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key := keys["transfer-key"]; key.Name != "transfer-key" || key.Algorithm != "hmac-sha512" || key.Secret != testSecret {
+		t.Errorf("ParseTSIGKeys() = %+v, want transfer-key named after its key", keys)
+	}
+
+	for _, b := range []string{
+		`["transfer-key"]`,
+		`{"transfer-key": {}}`,
+		`{"transfer-key": {"secret": "not base64!"}}`,
+	} {
+		if _, err := ParseTSIGKeys([]byte(b)); err == nil {
+			t.Errorf("ParseTSIGKeys(%s) succeeded", b)
+		}
+	}
+}
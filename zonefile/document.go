@@ -0,0 +1,65 @@
+package zonefile
+
+import (
+	"github.com/DrC0ns0le/bind-api/rdb"
+)
+
+// Document is the structured JSON and YAML export of a zone.
+type Document struct {
+	Name        string           `json:"name" yaml:"name"`
+	SOA         DocumentSOA      `json:"soa" yaml:"soa"`
+	NameServers rdb.NameServers  `json:"name_servers" yaml:"name_servers"`
+	Records     []DocumentRecord `json:"records" yaml:"records"`
+}
+
+type DocumentSOA struct {
+	PrimaryNS  string `json:"primary_ns" yaml:"primary_ns"`
+	AdminEmail string `json:"admin_email" yaml:"admin_email"`
+	Refresh    uint16 `json:"refresh" yaml:"refresh"`
+	Retry      uint16 `json:"retry" yaml:"retry"`
+	Expire     uint32 `json:"expire" yaml:"expire"`
+	Minimum    uint16 `json:"minimum" yaml:"minimum"`
+	TTL        uint16 `json:"ttl" yaml:"ttl"`
+}
+
+type DocumentRecord struct {
+	Host    string `json:"host" yaml:"host"`
+	Type    string `json:"type" yaml:"type"`
+	TTL     uint16 `json:"ttl" yaml:"ttl"`
+	Content string `json:"content" yaml:"content"`
+	AddPTR  bool   `json:"add_ptr,omitempty" yaml:"add_ptr,omitempty"`
+}
+
+// NewDocument builds the export of a zone from its rdb representation.
+// Records pending deletion are left out.
+func NewDocument(zone rdb.Zone, records []rdb.Record) Document {
+	doc := Document{
+		Name: zone.Name,
+		SOA: DocumentSOA{
+			PrimaryNS:  zone.PrimaryNS,
+			AdminEmail: zone.AdminEmail,
+			Refresh:    zone.Refresh,
+			Retry:      zone.Retry,
+			Expire:     zone.Expire,
+			Minimum:    zone.Minimum,
+			TTL:        zone.TTL,
+		},
+		NameServers: zone.NameServers,
+		Records:     []DocumentRecord{},
+	}
+
+	for _, r := range records {
+		if r.DeletedAt.Valid {
+			continue
+		}
+		doc.Records = append(doc.Records, DocumentRecord{
+			Host:    r.Host,
+			Type:    r.Type,
+			TTL:     r.TTL,
+			Content: r.Content,
+			AddPTR:  r.AddPTR,
+		})
+	}
+
+	return doc
+}
@@ -0,0 +1,179 @@
+// Package zonefile converts between RFC 1035 master files and the rdb types.
+package zonefile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"strings"
+
+	"github.com/DrC0ns0le/bind-api/rdb"
+	"github.com/miekg/dns"
+)
+
+var ErrNoOrigin = errors.New("zone origin unknown, no $ORIGIN or SOA record found")
+
+// Result is a parsed zone split into the SOA data and the records bind-api manages.
+type Result struct {
+	Origin      string          // Zone name without trailing dot
+	SOA         *rdb.Zone       // SOA fields of the zone, nil if the input had no SOA
+	NameServers rdb.NameServers // Apex NS set of the zone
+	Records     []rdb.Record    // Records to stage, without UUID or zone UUID
+	Skipped     []Skipped       // Records that cannot be managed by bind-api
+	Warnings    []string        // Values that were adjusted to fit
+}
+
+// Skipped is a record left out of a Result and why.
+type Skipped struct {
+	Record string `json:"record"`
+	Reason string `json:"reason"`
+}
+
+// Parse reads a master file. $INCLUDE directives are resolved against
+// includes, and rejected when includes is nil.
+//
+// Parameters:
+//   - r: the zone file contents.
+//   - origin: initial origin, may be empty when the file sets $ORIGIN.
+//   - file: name of the zone file, used in errors and to resolve relative $INCLUDE paths.
+//   - includes: files available to $INCLUDE.
+func Parse(r io.Reader, origin, file string, includes fs.FS) ([]dns.RR, error) {
+	zp := dns.NewZoneParser(r, origin, file)
+	if includes != nil {
+		zp.SetIncludeAllowed(true)
+		zp.SetIncludeFS(includes)
+	}
+
+	var rrs []dns.RR
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	return rrs, nil
+}
+
+// Convert maps resource records of a single zone onto rdb types.
+//
+// If origin is empty the owner of the first SOA record is used.
+func Convert(origin string, rrs []dns.RR) (Result, error) {
+	if origin == "" {
+		origin = Origin(rrs)
+		if origin == "" {
+			return Result{}, ErrNoOrigin
+		}
+	}
+	origin = dns.Fqdn(origin)
+
+	result := Result{Origin: strings.TrimSuffix(origin, ".")}
+	for _, rr := range rrs {
+		hdr := rr.Header()
+
+		if !dns.IsSubDomain(origin, hdr.Name) {
+			result.skip(rr, "owner is outside of zone "+origin)
+			continue
+		}
+
+		switch hdr.Rrtype {
+		case dns.TypeSOA:
+			if !equalNames(hdr.Name, origin) {
+				result.skip(rr, "SOA record is not at the zone apex")
+				continue
+			}
+			if result.SOA != nil {
+				result.skip(rr, "duplicate SOA record")
+				continue
+			}
+			result.SOA = result.zoneFromSOA(rr.(*dns.SOA))
+			continue
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3, dns.TypeNSEC3PARAM, dns.TypeDNSKEY:
+			result.skip(rr, "DNSSEC records are not managed by bind-api")
+			continue
+		case dns.TypeNS:
+			// the apex NS set belongs to the zone, address records of
+			// the name servers are imported as regular records
+			if equalNames(hdr.Name, origin) {
+				target := rr.(*dns.NS).Ns
+				host := target
+				if dns.IsSubDomain(origin, target) && !equalNames(target, origin) {
+					host = relativeName(target, origin)
+				}
+				result.NameServers = append(result.NameServers, rdb.NameServer{Host: host})
+				continue
+			}
+		}
+
+		record := rdb.Record{
+			Type:    dns.TypeToString[hdr.Rrtype],
+			Host:    relativeName(hdr.Name, origin),
+			Content: Content(rr),
+			TTL:     result.clamp16(hdr.Ttl, "TTL of "+hdr.Name+" "+dns.TypeToString[hdr.Rrtype]),
+		}
+
+		// render appends the trailing dot to CNAME targets
+		if hdr.Rrtype == dns.TypeCNAME {
+			record.Content = strings.TrimSuffix(record.Content, ".")
+		}
+
+		result.Records = append(result.Records, record)
+	}
+
+	return result, nil
+}
+
+// Origin returns the owner of the first SOA record of rrs without its
+// trailing dot, or an empty string when there is none.
+func Origin(rrs []dns.RR) string {
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeSOA {
+			return strings.TrimSuffix(rr.Header().Name, ".")
+		}
+	}
+	return ""
+}
+
+// Content returns the presentation format RDATA of rr.
+func Content(rr dns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+func (res *Result) zoneFromSOA(soa *dns.SOA) *rdb.Zone {
+	return &rdb.Zone{
+		Name:       res.Origin,
+		PrimaryNS:  strings.TrimSuffix(soa.Ns, "."),
+		AdminEmail: strings.TrimSuffix(soa.Mbox, "."),
+		Refresh:    res.clamp16(soa.Refresh, "SOA refresh"),
+		Retry:      res.clamp16(soa.Retry, "SOA retry"),
+		Expire:     soa.Expire,
+		Minimum:    res.clamp16(soa.Minttl, "SOA minimum"),
+		TTL:        res.clamp16(soa.Hdr.Ttl, "SOA TTL"),
+	}
+}
+
+func (res *Result) skip(rr dns.RR, reason string) {
+	res.Skipped = append(res.Skipped, Skipped{Record: rr.String(), Reason: reason})
+}
+
+// clamp16 fits v into the 16 bit fields used by rdb, recording a warning when it does not.
+func (res *Result) clamp16(v uint32, what string) uint16 {
+	if v > math.MaxUint16 {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("%s of %d reduced to %d", what, v, math.MaxUint16))
+		return math.MaxUint16
+	}
+	return uint16(v)
+}
+
+// relativeName returns name relative to origin, "@" for the apex.
+func relativeName(name, origin string) string {
+	if equalNames(name, origin) {
+		return "@"
+	}
+	return name[:len(name)-len(origin)-1]
+}
+
+func equalNames(a, b string) bool {
+	return strings.EqualFold(dns.Fqdn(a), dns.Fqdn(b))
+}